@@ -0,0 +1,24 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDoCacheClear(t *testing.T) {
+	p, _ := repositoryIndexPath()
+	if err := ioutil.WriteFile(p, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := newApp().Run([]string{"", "cache", "clear"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Errorf("index should be removed: %v", err)
+	}
+	// it is not an error if the index does not exist
+	if err := newApp().Run([]string{"", "cache", "clear"}); err != nil {
+		t.Errorf("error should be nil, but: %s", err)
+	}
+}
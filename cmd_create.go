@@ -6,13 +6,16 @@ import (
 	"os"
 
 	"github.com/urfave/cli/v2"
+	"github.com/x-motemen/ghq/cmdutil"
 )
 
 func doCreate(c *cli.Context) error {
 	var (
-		name = c.Args().First()
-		vcs  = c.String("vcs")
-		w    = c.App.Writer
+		name      = c.Args().First()
+		vcs       = c.String("vcs")
+		setOrigin = c.Bool("set-origin")
+		initial   = c.Bool("initial-commit")
+		w         = c.App.Writer
 	)
 	u, err := newURL(name, false, true)
 	if err != nil {
@@ -53,14 +56,38 @@ func doCreate(c *cli.Context) error {
 	if initFunc == nil {
 		return fmt.Errorf("failed to init: unsupported VCS")
 	}
+	if setOrigin && vcsBackend != GitBackend {
+		return usageErrorf("--set-origin is only supported for git repositories")
+	}
+	if initial && vcsBackend != GitBackend {
+		return usageErrorf("--initial-commit is only supported for git repositories")
+	}
 
+	_, err = os.Stat(p)
+	created := os.IsNotExist(err)
 	if err := os.MkdirAll(p, 0755); err != nil {
 		return err
 	}
 
-	if err := initFunc(p); err != nil {
+	if err := initFunc(p, false); err != nil {
+		if created {
+			// not to leave the empty directory looking like a repository
+			os.Remove(p)
+		}
 		return err
 	}
+	if setOrigin {
+		// the same URL as the one `ghq get` clones, so that a later push just works
+		if err := cmdutil.RunInDir(p, "git", "remote", "add", "origin", u.String()); err != nil {
+			return err
+		}
+	}
+	if initial {
+		// an empty commit not to depend on any file in the scaffold
+		if err := cmdutil.RunInDir(p, "git", "commit", "--allow-empty", "-m", "Initial commit"); err != nil {
+			return err
+		}
+	}
 	_, err = fmt.Fprintln(w, p)
 	return err
 }
@@ -47,6 +47,24 @@ func TestDoCreate(t *testing.T) {
 		input:   []string{"create", "motemen/ghqq"},
 		want:    []string{"git", "init"},
 		wantDir: filepath.Join(tmpd, "github.com/motemen/ghqq"),
+	}, {
+		name:    "with origin",
+		input:   []string{"create", "--set-origin", "https://github.com/motemen/ghq-origin.git"},
+		want:    []string{"git", "remote", "add", "origin", "https://github.com/motemen/ghq-origin.git"},
+		wantDir: filepath.Join(tmpd, "github.com/motemen/ghq-origin"),
+	}, {
+		name:    "with initial commit",
+		input:   []string{"create", "--set-origin", "--initial-commit", "motemen/ghq-initial"},
+		want:    []string{"git", "commit", "--allow-empty", "-m", "Initial commit"},
+		wantDir: filepath.Join(tmpd, "github.com/motemen/ghq-initial"),
+	}, {
+		name:   "initial commit for Mercurial",
+		input:  []string{"create", "--vcs=hg", "--initial-commit", "motemen/ghq-hg-initial"},
+		errStr: "--initial-commit is only supported for git repositories",
+	}, {
+		name:   "with origin for Mercurial",
+		input:  []string{"create", "--vcs=hg", "--set-origin", "motemen/ghq-hg-origin"},
+		errStr: "--set-origin is only supported for git repositories",
 	}, {
 		name:  "empty directory exists",
 		input: []string{"create", "motemen/ghqqq"},
@@ -87,7 +105,7 @@ func TestDoCreate(t *testing.T) {
 	}, {
 		name:   "unsupported VCS",
 		input:  []string{"create", "--vcs=svn", "motemen/ghq-svn"},
-		errStr: "unsupported VCS",
+		errStr: "Subversion init is not supported",
 	}, {
 		name:  "not permitted",
 		input: []string{"create", "motemen/ghq-notpermitted"},
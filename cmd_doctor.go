@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+
+	"github.com/urfave/cli/v2"
+)
+
+// vcsCommands are the commands run by the VCS backends, looked for on PATH
+// by `ghq doctor`
+var vcsCommands = map[*VCSBackend]string{
+	GitBackend:        "git",
+	GitsvnBackend:     "git",
+	SubversionBackend: "svn",
+	MercurialBackend:  "hg",
+	DarcsBackend:      "darcs",
+	FossilBackend:     "fossil",
+	BazaarBackend:     "bzr",
+	PijulBackend:      "pijul",
+}
+
+// lookPath is a variable to be replaced in the tests
+var lookPath = exec.LookPath
+
+// doctorReport collects the problems found by `ghq doctor`. Errors are fatal
+// misconfigurations making ghq fail, and warnings are not.
+type doctorReport struct {
+	w        io.Writer
+	errors   []string
+	warnings []string
+}
+
+func (r *doctorReport) errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *doctorReport) warnf(format string, args ...interface{}) {
+	r.warnings = append(r.warnings, fmt.Sprintf(format, args...))
+}
+
+func doDoctor(c *cli.Context) error {
+	r := &doctorReport{w: c.App.Writer}
+	if err := r.checkRoots(); err != nil {
+		return err
+	}
+	r.checkVCSCommands()
+	if _, err := getRootLayout(); err != nil {
+		r.errorf("%s", err)
+	}
+
+	if len(r.errors) == 0 && len(r.warnings) == 0 {
+		fmt.Fprintln(r.w, "No problems found")
+		return nil
+	}
+	fmt.Fprintln(r.w, "Problems:")
+	for _, e := range r.errors {
+		fmt.Fprintf(r.w, "  error: %s\n", e)
+	}
+	for _, w := range r.warnings {
+		fmt.Fprintf(r.w, "  warning: %s\n", w)
+	}
+	if len(r.errors) > 0 {
+		return fmt.Errorf("%d fatal problems found", len(r.errors))
+	}
+	return nil
+}
+
+// checkRoots prints the roots with their status and the number of the
+// repositories under them.
+func (r *doctorReport) checkRoots() error {
+	infos, err := localRepositoryRootInfos(true)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(r.w, "Roots:")
+	for _, info := range infos {
+		name := info.Path
+		if info.Primary {
+			name += " (primary)"
+		}
+		fi, err := os.Stat(info.Path)
+		switch {
+		case err != nil && os.IsNotExist(err):
+			fmt.Fprintf(r.w, "  %s: missing\n", name)
+			if info.Primary {
+				r.warnf("the primary root %s does not exist, which is created by the first clone", info.Path)
+			} else {
+				r.warnf("the root %s does not exist; Remove it from ghq.root if it is stale", info.Path)
+			}
+			continue
+		case err != nil:
+			fmt.Fprintf(r.w, "  %s: %s\n", name, err)
+			r.errorf("failed to read the root %s: %s", info.Path, err)
+			continue
+		case !fi.IsDir():
+			fmt.Fprintf(r.w, "  %s: not a directory\n", name)
+			r.errorf("the root %s is not a directory", info.Path)
+			continue
+		}
+		fmt.Fprintf(r.w, "  %s: %d repositories\n", name, *info.RepoCount)
+		// the same check as walking the roots, which skips them
+		if fi.Mode()&0444 == 0 {
+			r.warnf("the root %s is not readable, so the repositories under it are not listed", info.Path)
+		}
+		if !info.Writable {
+			if info.Primary {
+				r.errorf("the primary root %s is not writable, so repositories cannot be cloned", info.Path)
+			} else {
+				r.warnf("the root %s is not writable", info.Path)
+			}
+		}
+	}
+	return nil
+}
+
+// checkVCSCommands prints the paths of the commands of the VCSs
+func (r *doctorReport) checkVCSCommands() {
+	seen := map[string]bool{}
+	var names []string
+	for _, cmd := range vcsCommands {
+		if !seen[cmd] {
+			seen[cmd] = true
+			names = append(names, cmd)
+		}
+	}
+	sort.Strings(names)
+	fmt.Fprintln(r.w, "VCS commands:")
+	for _, name := range names {
+		p, err := lookPath(name)
+		if err != nil {
+			fmt.Fprintf(r.w, "  %s: not found\n", name)
+			if name == "git" {
+				r.errorf("git is not found on PATH, which is required for most of the repositories")
+			}
+			continue
+		}
+		fmt.Fprintf(r.w, "  %s: %s\n", name, p)
+	}
+}
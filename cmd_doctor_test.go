@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDoDoctor(t *testing.T) {
+	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
+	defer func(orig func(string) (string, error)) { lookPath = orig }(lookPath)
+	tmpd := newTempDir(t)
+	defer os.RemoveAll(tmpd)
+	os.MkdirAll(filepath.Join(tmpd, "github.com", "motemen", "ghq", ".git"), 0755)
+	unknown := filepath.Join(tmpd, "unknown-ghq-dummy")
+	file := filepath.Join(tmpd, "file")
+	ioutil.WriteFile(file, []byte("dummy\n"), 0644)
+
+	lookPath = func(name string) (string, error) {
+		if name == "git" || name == "hg" {
+			return "/usr/bin/" + name, nil
+		}
+		return "", errors.New("not found")
+	}
+
+	testCases := []struct {
+		name    string
+		roots   []string
+		git     bool
+		expect  []string
+		wantErr bool
+	}{{
+		name:  "healthy",
+		roots: []string{tmpd},
+		git:   true,
+		expect: []string{
+			tmpd + " (primary): 1 repositories\n",
+			"  git: /usr/bin/git\n  hg: /usr/bin/hg\n",
+			"  svn: not found\n",
+			"No problems found\n",
+		},
+	}, {
+		name:  "missing root",
+		roots: []string{tmpd, unknown},
+		git:   true,
+		expect: []string{
+			unknown + ": missing\n",
+			"warning: the root " + unknown + " does not exist",
+		},
+	}, {
+		name:  "file as root",
+		roots: []string{tmpd, file},
+		git:   true,
+		expect: []string{
+			file + ": not a directory\n",
+			"error: the root " + file + " is not a directory",
+		},
+		wantErr: true,
+	}, {
+		name:  "git not found",
+		roots: []string{tmpd},
+		expect: []string{
+			"  git: not found\n",
+			"error: git is not found on PATH",
+		},
+		wantErr: true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_localRepositoryRoots = tc.roots
+			if !tc.git {
+				orig := lookPath
+				defer func() { lookPath = orig }()
+				lookPath = func(string) (string, error) { return "", errors.New("not found") }
+			}
+			var err error
+			out, _, _ := capture(func() {
+				err = newApp().Run([]string{"", "doctor"})
+			})
+			for _, e := range tc.expect {
+				if !strings.Contains(out, e) {
+					t.Errorf("output should contain %q, but:\n%s", e, out)
+				}
+			}
+			if (err != nil) != tc.wantErr {
+				t.Errorf("error: %v, wantErr: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
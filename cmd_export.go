@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/urfave/cli/v2"
+	"github.com/x-motemen/ghq/logger"
+)
+
+// doExport prints the manifest of the local repositories consumed by
+// `ghq import`: the remote URL and the VCS separated by a tab per line.
+func doExport(c *cli.Context) error {
+	var (
+		w     = c.App.Writer
+		lines []string
+		mu    sync.Mutex
+	)
+	if err := walkAllLocalRepositories(func(repo *LocalRepository) {
+		u, err := exportURL(repo)
+		if err != nil {
+			logger.Log("warning", fmt.Sprintf("skip %s: %s", repo.FullPath, err))
+			return
+		}
+		vcs, _ := repo.VCS()
+		line := u
+		if name := vcsName(vcs); name != "" {
+			line += "\t" + name
+		}
+		mu.Lock()
+		lines = append(lines, line)
+		mu.Unlock()
+	}); err != nil {
+		return err
+	}
+	sort.Strings(lines)
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+	return nil
+}
+
+// exportURL returns the URL to clone the repository from. The remote read by
+// RemoteURL comes first, then the one of Remotes in the order of the names,
+// and the URL made from the path, e.g. https://github.com/x-motemen/ghq, is
+// used for the repositories without remotes.
+func exportURL(repo *LocalRepository) (string, error) {
+	if u, err := repo.RemoteURL(); err == nil && u != "" {
+		return u, nil
+	}
+	if remotes, err := repo.Remotes(); err == nil && len(remotes) > 0 {
+		names := make([]string, 0, len(remotes))
+		for name := range remotes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return remotes[names[0]], nil
+	}
+	if repo.Host() == "" {
+		return "", fmt.Errorf("no remote found")
+	}
+	return "https://" + filepath.ToSlash(repo.RelPath), nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/x-motemen/ghq/cmdutil"
+)
+
+func TestDoExport(t *testing.T) {
+	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
+	tmproot := newTempDir(t)
+	defer os.RemoveAll(tmproot)
+	_localRepositoryRoots = []string{tmproot}
+
+	gitRepo := filepath.Join(tmproot, "github.com", "x-motemen", "ghq")
+	hgRepo := filepath.Join(tmproot, "example.com", "hg", "repo")
+	localRepo := filepath.Join(tmproot, "example.com", "local", "repo")
+	for _, d := range []string{filepath.Join(gitRepo, ".git"), filepath.Join(hgRepo, ".hg"), filepath.Join(localRepo, ".git")} {
+		os.MkdirAll(d, 0755)
+	}
+
+	defer func(orig func(cmd *exec.Cmd) error) {
+		cmdutil.CommandRunner = orig
+	}(cmdutil.CommandRunner)
+	cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+		switch cmd.Dir + " " + strings.Join(cmd.Args, " ") {
+		case gitRepo + " git remote", gitRepo + " git remote -v":
+			fmt.Fprint(cmd.Stdout, "origin\n")
+		case gitRepo + " git remote get-url origin":
+			fmt.Fprint(cmd.Stdout, "git@github.com:x-motemen/ghq.git\n")
+		case hgRepo + " hg paths default":
+			return errors.New("not found!")
+		case hgRepo + " hg paths":
+			fmt.Fprint(cmd.Stdout, "upstream = https://example.com/hg/upstream\n")
+		}
+		return nil
+	}
+
+	out, _, _ := capture(func() {
+		if err := newApp().Run([]string{"", "export"}); err != nil {
+			t.Errorf("error should be nil but: %s", err)
+		}
+	})
+	expect := "git@github.com:x-motemen/ghq.git\tgit\n" +
+		"https://example.com/hg/upstream\thg\n" +
+		"https://example.com/local/repo\tgit\n"
+	if out != expect {
+		t.Errorf("got: %q, expect: %q", out, expect)
+	}
+}
@@ -2,74 +2,371 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"os"
-	"os/exec"
+	"path"
 	"path/filepath"
-	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
+	"github.com/Songmu/gitconfig"
 	"github.com/mattn/go-isatty"
 	"github.com/urfave/cli/v2"
-	"github.com/x-motemen/ghq/cmdutil"
 	"github.com/x-motemen/ghq/logger"
 	"golang.org/x/sync/errgroup"
 )
 
-func doGet(c *cli.Context) error {
+func doGet(c *cli.Context) (err error) {
 	var (
-		args     = c.Args().Slice()
-		andLook  = c.Bool("look")
-		parallel = c.Bool("parallel")
+		args        = c.Args().Slice()
+		andLook     = c.Bool("look")
+		parallel    = c.Value("parallel").(parallelValue)
+		printTarget = c.Bool("print-target")
+		printPath   = c.Bool("print-path")
+		w           = c.App.Writer
 	)
+	if printPath && (andLook || printTarget) {
+		return usageErrorf("--print-path cannot be specified with --look or --print-target")
+	}
+	if c.Bool("dry-run") && (andLook || printPath) {
+		return usageErrorf("--dry-run cannot be specified with --look or --print-path")
+	}
+	onExists := c.String("on-exists")
+	switch onExists {
+	case "":
+		onExists = onExistsSkip
+		if c.Bool("update") {
+			onExists = onExistsUpdate
+		}
+	case onExistsSkip, onExistsUpdate, onExistsReplace, onExistsError:
+	default:
+		return usageErrorf("invalid value of --on-exists: %q", onExists)
+	}
 	g := &getter{
-		update:    c.Bool("update"),
+		w:         w,
+		onExists:  onExists,
 		shallow:   c.Bool("shallow"),
 		ssh:       c.Bool("p"),
 		vcs:       c.String("vcs"),
 		silent:    c.Bool("silent"),
 		branch:    c.String("branch"),
 		recursive: !c.Bool("no-recursive"),
+		lfs:       c.Bool("lfs"),
+		mirrorTo:  c.String("mirror-to"),
+		username:  c.String("username"),
+		root:      c.String("root"),
+		strict:    c.Bool("strict"),
+		dryRun:    c.Bool("dry-run"),
+
+		verifyClean: c.Bool("verify-clean-after"),
+
+		gc:           c.Bool("gc"),
+		gcAggressive: c.Bool("gc-aggressive"),
+		noGC:         c.Bool("no-gc"),
+		noHardlinks:  c.Bool("no-hardlinks"),
+		insecure:     c.Bool("insecure"),
+		unshallow:    c.Bool("unshallow"),
+		prune:        c.Bool("prune"),
+		mirror:       c.Bool("mirror"),
+		dissociate:   c.Bool("dissociate"),
+
+		alsoFilterSubmodules: c.Bool("also-filter-submodules"),
+		pr:                   c.Int("pr"),
+		submoduleJobs:        c.Int("submodule-parallel"),
+		retry:                c.Int("retry"),
+
+		ref:       c.String("ref"),
+		preferRef: c.String("prefer"),
+		reference: c.String("reference"),
+
+		lfsInclude: c.String("lfs-include"),
+		lfsExclude: c.String("lfs-exclude"),
+
+		timeout:             c.Duration("timeout"),
+		timeoutPerRepo:      c.Duration("clone-timeout-per-repo"),
+		skipIfFetchedWithin: c.Duration("skip-if-fetched-within"),
+	}
+	tmpl, err := gitconfig.Get("ghq.clone.remoteNameTemplate")
+	if err != nil && !gitconfig.IsNotFound(err) {
+		return err
+	}
+	g.remoteNameTemplate = tmpl
+	if g.autoDeepen, err = gitconfig.Bool("ghq.update.autoDeepen"); err != nil && !gitconfig.IsNotFound(err) {
+		return err
+	}
+	if !g.unshallow {
+		if g.unshallow, err = gitconfig.Bool("ghq.update.unshallow"); err != nil && !gitconfig.IsNotFound(err) {
+			return err
+		}
+	}
+	if !c.IsSet("retry") {
+		if g.retry, err = gitconfig.Int("ghq.get.retry"); err != nil && !gitconfig.IsNotFound(err) {
+			return err
+		}
+	}
+	if g.retry < 0 {
+		return usageErrorf("invalid number of retries: %d", g.retry)
+	}
+	if g.prune && g.onExists != onExistsUpdate {
+		return usageErrorf("--prune requires --update")
+	}
+	if g.skipIfFetchedWithin != 0 && g.onExists != onExistsUpdate {
+		return usageErrorf("--skip-if-fetched-within requires --update")
+	}
+	if g.skipIfFetchedWithin < 0 {
+		return usageErrorf("invalid duration of --skip-if-fetched-within: %s", g.skipIfFetchedWithin)
+	}
+	if c.IsSet("depth") {
+		depth := c.Int("depth")
+		if depth < 0 {
+			return usageErrorf("invalid depth: %d", depth)
+		}
+		// --depth=0 clones the full history even with --shallow
+		g.shallow, g.depth = depth > 0, depth
+	}
+	if g.shallow && c.Bool("unshallow") {
+		return usageErrorf("--shallow and --unshallow cannot be specified at the same time")
+	}
+	if g.mirror {
+		for _, name := range []string{"branch", "ref", "shallow", "depth", "sparse", "pr"} {
+			if c.IsSet(name) {
+				return usageErrorf("--mirror cannot be specified with --%s", name)
+			}
+		}
+	}
+	if g.dissociate && g.reference == "" {
+		return usageErrorf("--dissociate requires --reference")
+	}
+	if g.reference != "" {
+		if g.reference, err = gitReferenceRepository(g.reference); err != nil {
+			return err
+		}
+		if g.shallow {
+			logger.Log("warning", "--shallow with --reference saves little since a shallow clone has few objects to borrow")
+		}
+	}
+	if g.alsoFilterSubmodules && !g.recursive {
+		return usageErrorf("--also-filter-submodules cannot be specified with --no-recursive")
+	}
+	if g.submoduleJobs < 0 || (c.IsSet("submodule-parallel") && g.submoduleJobs == 0) {
+		return usageErrorf("invalid number of jobs for --submodule-parallel: %d", g.submoduleJobs)
+	}
+	if g.submoduleJobs > 0 && !g.recursive {
+		return usageErrorf("--submodule-parallel cannot be specified with --no-recursive")
+	}
+	switch g.preferRef {
+	case "", preferRefTag, preferRefBranch:
+	default:
+		return usageErrorf("invalid value of --prefer: %q", g.preferRef)
+	}
+	if g.preferRef != "" && g.ref == "" {
+		return usageErrorf("--prefer requires --ref")
 	}
-	if parallel {
-		// force silent in parallel import
+	if g.ref != "" && g.branch != "" {
+		return usageErrorf("--ref cannot be specified with --branch")
+	}
+	if g.pr < 0 {
+		return usageErrorf("invalid pull request number: %d", g.pr)
+	}
+	if g.pr > 0 && len(args) != 1 {
+		return usageErrorf("--pr requires exactly one repository")
+	}
+	if g.insecure {
+		logger.Log("warning", "--insecure is specified. The TLS certificates of the servers are NOT verified, "+
+			"which makes the connections vulnerable to man-in-the-middle attacks")
+	}
+	if g.setRemotes, err = parseRemoteSettings(c.StringSlice("set-remote")); err != nil {
+		return err
+	}
+	if g.env, err = parseEnvSettings(c.StringSlice("env")); err != nil {
+		return err
+	}
+	if g.sparse, err = parseSparseDirs(c.StringSlice("sparse")); err != nil {
+		return err
+	}
+	if g.urlRewrites, err = loadURLRewrites(); err != nil {
+		return err
+	}
+	if g.hooksDir, err = hooksDir(c.String("hooks-dir")); err != nil {
+		return err
+	}
+	if bundle := c.String("bundle"); bundle != "" {
+		if len(args) != 1 {
+			return usageErrorf("--bundle requires exactly one repository")
+		}
+		if g.bundle, err = filepath.Abs(bundle); err != nil {
+			return err
+		}
+		if _, err := os.Stat(g.bundle); err != nil {
+			return usageErrorf("invalid bundle: %w", err)
+		}
+	}
+	if g.mirrorTo != "" {
+		if err := validateRemoteURL(g.mirrorTo); err != nil {
+			return err
+		}
+	}
+	notifier := &batchNotifier{title: "ghq get", enabled: c.Bool("notify")}
+	defer func() { notifier.finish(err) }()
+
+	sem := make(chan struct{}, defaultConcurrency)
+	if parallel.enabled {
+		// force silent in parallel import not to interleave the outputs
 		g.silent = true
+
+		limiter, err := newConcurrencyLimiter()
+		if err != nil {
+			return err
+		}
+		g.limiter = limiter
+		workers := limiter.max()
+		if parallel.workers > 0 {
+			workers = parallel.workers
+		}
+		sem = make(chan struct{}, workers)
+	}
+
+	var prog *progress
+	if c.Bool("silent") {
+		prog = &progress{w: os.Stderr, total: len(args)}
 	}
 
 	var (
 		firstArg string
 		scr      scanner
+		manifest *manifestScanner
 	)
-	if len(args) > 0 {
+	if fromFile := c.String("from-file"); fromFile != "" {
+		if len(args) > 0 {
+			return usageErrorf("--from-file cannot be specified with the repositories in the arguments")
+		}
+		f, err := os.Open(fromFile)
+		if err != nil {
+			return usageErrorf("failed to read --from-file: %w", err)
+		}
+		defer f.Close()
+		manifest = &manifestScanner{name: fromFile, scanner: bufio.NewScanner(f)}
+		scr = manifest
+	} else if len(args) > 0 {
 		scr = &sliceScanner{slice: args}
 	} else {
 		fd := os.Stdin.Fd()
 		if isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd) {
-			return fmt.Errorf("no target args specified. see `ghq get -h` for more details")
+			return usageErrorf("no target args specified. see `ghq get -h` for more details")
 		}
 		scr = bufio.NewScanner(os.Stdin)
 	}
+	var (
+		ctx       = context.Background()
+		succeeded int32
+		timedOut  int32
+		failed    []string
+		failedErr error
+		failedMu  sync.Mutex
+		printMu   sync.Mutex
+		// the error of the only repository in the arguments is returned as is
+		single = len(args) == 1
+	)
+	if g.timeout > 0 {
+		// the deadline of the whole batch, which kills the running VCS
+		// commands and leaves the rest not started
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+	}
+	// fail records the failure reported at the end not to stop the others
+	fail := func(target string, err error) {
+		if !single {
+			logger.Logf("error", "failed to get %q: %s", target, err)
+		}
+		if isTimeout(err) {
+			atomic.AddInt32(&timedOut, 1)
+		}
+		failedMu.Lock()
+		defer failedMu.Unlock()
+		failed = append(failed, target)
+		failedErr = err
+	}
+	// printRepoPath prints the path of the repository got for --print-path
+	printRepoPath := func(target string) error {
+		if !printPath {
+			return nil
+		}
+		repo, err := g.localRepository(target)
+		if err != nil {
+			return err
+		}
+		printMu.Lock()
+		defer printMu.Unlock()
+		fmt.Fprintln(w, repo.FullPath)
+		return nil
+	}
+	// getRepo gets the repository, reporting the progress for --silent
+	getRepo := func(target string) error {
+		if prog == nil {
+			return g.get(ctx, target)
+		}
+		rg := *g
+		rg.progress = &repoProgress{}
+		err := rg.get(ctx, target)
+		prog.report(target, rg.progress, err)
+		return err
+	}
 	eg := &errgroup.Group{}
-	sem := make(chan struct{}, 6)
 	for scr.Scan() {
 		target := scr.Text()
 		if firstArg == "" {
 			firstArg = target
 		}
-		if parallel {
+		if manifest != nil {
+			// an invalid line is reported not to stop the others
+			_, ref := splitVCSPrefix(target)
+			if _, err := newURL(ref, g.ssh, false); err != nil {
+				logger.Logf("error", "%s:%d: %s", manifest.name, manifest.line, err)
+				failedMu.Lock()
+				failed = append(failed, target)
+				failedMu.Unlock()
+				continue
+			}
+		}
+		if printTarget {
+			p, err := g.targetPath(target)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(w, p)
+			continue
+		}
+		if parallel.enabled {
 			sem <- struct{}{}
 			eg.Go(func() error {
 				defer func() { <-sem }()
-				if err := g.get(target); err != nil {
-					logger.Logf("error", "failed to get %q: %s", target, err)
+				err := getRepo(target)
+				if err == nil {
+					atomic.AddInt32(&succeeded, 1)
+					notifier.succeed()
+					err = printRepoPath(target)
+				}
+				if err != nil {
+					fail(target, err)
 				}
 				return nil
 			})
 		} else {
-			if err := g.get(target); err != nil {
-				return fmt.Errorf("failed to get %q: %w", target, err)
+			err := getRepo(target)
+			if err == nil {
+				succeeded++
+				notifier.succeed()
+				err = printRepoPath(target)
+			}
+			if err != nil {
+				fail(target, err)
 			}
 		}
 	}
@@ -79,12 +376,112 @@ func doGet(c *cli.Context) error {
 	if err := eg.Wait(); err != nil {
 		return err
 	}
-	if andLook && firstArg != "" {
-		return look(firstArg)
+	if single && failedErr != nil {
+		return fmt.Errorf("failed to get %q: %w", failed[0], failedErr)
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return partialFailureErrorf(int(succeeded), "failed to get %d repositories (%d timed out): %s",
+			len(failed), timedOut, strings.Join(failed, ", "))
+	}
+	if andLook && firstArg != "" && !printTarget {
+		// look into the repository just got rather than the ones matching the name
+		repo, err := g.localRepository(firstArg)
+		if err != nil {
+			return err
+		}
+		// the batch has finished before the shell
+		notifier.finish(nil)
+		return lookInto(repo)
 	}
 	return nil
 }
 
+// parallelValue is the value of --parallel. It can be used as a boolean flag
+// (-P) or with the number of the workers (--parallel=N).
+type parallelValue struct {
+	enabled bool
+	workers int // 0 means the default
+}
+
+func (v *parallelValue) Set(s string) error {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 1 {
+			return fmt.Errorf("invalid number of workers: %d", n)
+		}
+		*v = parallelValue{enabled: true, workers: n}
+		return nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("invalid value: %q", s)
+	}
+	*v = parallelValue{enabled: b}
+	return nil
+}
+
+func (v *parallelValue) String() string {
+	if v.workers > 0 {
+		return strconv.Itoa(v.workers)
+	}
+	return strconv.FormatBool(v.enabled)
+}
+
+func (v *parallelValue) Get() interface{} {
+	return *v
+}
+
+// IsBoolFlag makes it possible to omit the value
+func (v *parallelValue) IsBoolFlag() bool {
+	return true
+}
+
+// parallelFlag is the cli.Flag of --parallel. Unlike cli.GenericFlag, the
+// value is allocated for each parsing.
+type parallelFlag struct {
+	Name    string
+	Aliases []string
+	Usage   string
+}
+
+func (f *parallelFlag) Apply(set *flag.FlagSet) error {
+	v := &parallelValue{}
+	for _, name := range f.Names() {
+		set.Var(v, name, f.Usage)
+	}
+	return nil
+}
+
+func (f *parallelFlag) Names() []string {
+	return append([]string{f.Name}, f.Aliases...)
+}
+
+func (f *parallelFlag) IsSet() bool {
+	return false
+}
+
+func (f *parallelFlag) String() string {
+	return cli.FlagStringer(f)
+}
+
+// TakesValue reports false for the help since the value is optional
+func (f *parallelFlag) TakesValue() bool {
+	return false
+}
+
+func (f *parallelFlag) GetUsage() string {
+	return f.Usage
+}
+
+func (f *parallelFlag) GetValue() string {
+	return ""
+}
+
+func isTimeout(err error) bool {
+	var te *timeoutError
+	return errors.As(err, &te)
+}
+
 type sliceScanner struct {
 	slice []string
 	index int
@@ -103,71 +500,75 @@ func (s *sliceScanner) Err() error {
 	return nil
 }
 
+// manifestScanner scans the repositories listed in a file for --from-file,
+// one per line. Blank lines and comments beginning with "#" are skipped.
+type manifestScanner struct {
+	name    string
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (s *manifestScanner) Scan() bool {
+	for s.scanner.Scan() {
+		s.line++
+		if t := s.Text(); t != "" && !strings.HasPrefix(t, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *manifestScanner) Text() string {
+	return strings.TrimSpace(s.scanner.Text())
+}
+
+func (s *manifestScanner) Err() error {
+	return s.scanner.Err()
+}
+
 type scanner interface {
 	Scan() bool
 	Text() string
 	Err() error
 }
 
-func detectShell() string {
-	shell := os.Getenv("SHELL")
-	if shell != "" {
-		return shell
-	}
-	if runtime.GOOS == "windows" {
-		return os.Getenv("COMSPEC")
+// parseSparseDirs normalizes the directories of --sparse into the slash
+// separated paths relative to the repository root.
+func parseSparseDirs(dirs []string) ([]string, error) {
+	var ret []string
+	for _, d := range dirs {
+		dir := strings.Trim(path.Clean(filepath.ToSlash(d)), "/")
+		if dir == "" || dir == "." || dir == ".." || strings.HasPrefix(dir, "../") {
+			return nil, usageErrorf("invalid directory for --sparse: %q", d)
+		}
+		ret = append(ret, dir)
 	}
-	return "/bin/sh"
+	return ret, nil
 }
 
-func look(name string) error {
-	var (
-		reposFound []*LocalRepository
-		mu         sync.Mutex
-	)
-	if err := walkAllLocalRepositories(func(repo *LocalRepository) {
-		if repo.Matches(name) {
-			mu.Lock()
-			reposFound = append(reposFound, repo)
-			mu.Unlock()
+// hooksDir returns the absolute path of the shared git hooks directory given
+// by --hooks-dir or ghq.hooksPath, since a relative core.hooksPath would be
+// resolved in each repository.
+func hooksDir(dir string) (string, error) {
+	if dir == "" {
+		var err error
+		if dir, err = gitconfig.Path("ghq.hooksPath"); err != nil && !gitconfig.IsNotFound(err) {
+			return "", err
 		}
-	}); err != nil {
-		return err
-	}
-
-	if len(reposFound) == 0 {
-		if url, err := newURL(name, false, false); err == nil {
-			repo, err := LocalRepositoryFromURL(url)
-			if err != nil {
-				return err
-			}
-			_, err = os.Stat(repo.FullPath)
-
-			// if the directory exists
-			if err == nil {
-				reposFound = append(reposFound, repo)
-			}
+		if dir == "" {
+			return "", nil
 		}
 	}
-
-	switch len(reposFound) {
-	case 0:
-		return fmt.Errorf("No repository found")
-	case 1:
-		repo := reposFound[0]
-		cmd := exec.Command(detectShell())
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		cmd.Dir = repo.FullPath
-		cmd.Env = append(os.Environ(), "GHQ_LOOK="+filepath.ToSlash(repo.RelPath))
-		return cmdutil.RunCommand(cmd, true)
-	default:
-		b := &strings.Builder{}
-		b.WriteString("More than one repositories are found; Try more precise name\n")
-		for _, repo := range reposFound {
-			b.WriteString(fmt.Sprintf("       - %s\n", strings.Join(repo.PathParts, "/")))
-		}
-		return errors.New(b.String())
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return "", usageErrorf("invalid hooks directory: %w", err)
+	}
+	if !fi.IsDir() {
+		return "", usageErrorf("invalid hooks directory: %s is not a directory", dir)
 	}
+	return dir, nil
 }
@@ -3,12 +3,14 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Songmu/gitconfig"
 	"github.com/x-motemen/ghq/cmdutil"
@@ -76,6 +78,60 @@ func TestCommandGet(t *testing.T) {
 				t.Errorf("got: %s, expect: %s", updateArgs.local, localDir)
 			}
 		},
+	}, {
+		name: "--skip-if-fetched-within",
+		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
+			localDir := filepath.Join(tmpRoot, "github.com", "motemen", "ghq-test-repo")
+			os.MkdirAll(filepath.Join(localDir, ".git"), 0755)
+			fetchHead := filepath.Join(localDir, ".git", "FETCH_HEAD")
+			ioutil.WriteFile(fetchHead, nil, 0644)
+
+			if err := app.Run([]string{"", "get", "--skip-if-fetched-within", "1h", "motemen/ghq-test-repo"}); err == nil {
+				t.Errorf("error should be occurred without -u")
+			}
+
+			app.Run([]string{"", "get", "-u", "--skip-if-fetched-within", "1h", "motemen/ghq-test-repo"})
+			if updateArgs.local != "" {
+				t.Errorf("repository fetched recently should not be updated")
+			}
+
+			old := time.Now().Add(-2 * time.Hour)
+			os.Chtimes(fetchHead, old, old)
+			app.Run([]string{"", "get", "-u", "--skip-if-fetched-within", "1h", "motemen/ghq-test-repo"})
+			if updateArgs.local != localDir {
+				t.Errorf("got: %s, expect: %s", updateArgs.local, localDir)
+			}
+		},
+	}, {
+		name: "already cloned with --on-exists=error",
+		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
+			localDir := filepath.Join(tmpRoot, "github.com", "motemen", "ghq-test-repo")
+			os.MkdirAll(filepath.Join(localDir, ".git"), 0755)
+
+			err := app.Run([]string{"", "get", "-u", "--on-exists=error", "motemen/ghq-test-repo"})
+
+			if err == nil {
+				t.Errorf("error should not be nil")
+			}
+			if updateArgs.local != "" {
+				t.Errorf("repository should not be updated")
+			}
+		},
+	}, {
+		name: "already cloned with --on-exists=replace",
+		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
+			localDir := filepath.Join(tmpRoot, "github.com", "motemen", "ghq-test-repo")
+			os.MkdirAll(filepath.Join(localDir, ".git"), 0755)
+
+			app.Run([]string{"", "get", "--on-exists=replace", "motemen/ghq-test-repo"})
+
+			if filepath.ToSlash(cloneArgs.local) != filepath.ToSlash(localDir) {
+				t.Errorf("got: %s, expect: %s", filepath.ToSlash(cloneArgs.local), filepath.ToSlash(localDir))
+			}
+			if _, err := os.Stat(filepath.Join(localDir, ".git")); !os.IsNotExist(err) {
+				t.Errorf("existing repository should be removed")
+			}
+		},
 	}, {
 		name: "shallow",
 		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
@@ -94,6 +150,24 @@ func TestCommandGet(t *testing.T) {
 				t.Errorf("cloneArgs.shallow should be true")
 			}
 		},
+	}, {
+		name: "depth",
+		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
+			app.Run([]string{"", "get", "--depth", "10", "motemen/ghq-test-repo"})
+
+			if !cloneArgs.shallow || cloneArgs.depth != 10 {
+				t.Errorf("got: shallow=%t, depth=%d, expect: shallow=true, depth=10", cloneArgs.shallow, cloneArgs.depth)
+			}
+		},
+	}, {
+		name: "depth 0 overrides shallow",
+		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
+			app.Run([]string{"", "get", "--shallow", "--depth", "0", "motemen/ghq-test-repo"})
+
+			if cloneArgs.shallow || cloneArgs.depth != 0 {
+				t.Errorf("got: shallow=%t, depth=%d, expect: full clone", cloneArgs.shallow, cloneArgs.depth)
+			}
+		},
 	}, {
 		name: "dot slash ./",
 		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
@@ -166,6 +240,179 @@ func TestCommandGet(t *testing.T) {
 				t.Errorf("cloneArgs.recursive should be false")
 			}
 		},
+	}, {
+		name: "with --clone-timeout-per-repo option",
+		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
+			app.Run([]string{"", "get", "--clone-timeout-per-repo", "1m", "motemen/ghq-test-repo"})
+
+			if !cloneArgs.deadline {
+				t.Errorf("cloneArgs should have a deadline")
+			}
+		},
+	}, {
+		name: "print target",
+		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
+			out, _, _ := capture(func() {
+				newApp().Run([]string{"", "get", "--print-target", "motemen/ghq-test-repo"})
+			})
+
+			localDir := filepath.Join(tmpRoot, "github.com", "motemen", "ghq-test-repo")
+			if strings.TrimSpace(out) != localDir {
+				t.Errorf("got: %s, expect: %s", out, localDir)
+			}
+			if cloneArgs.remote != nil {
+				t.Errorf("repository should not be cloned")
+			}
+		},
+	}, {
+		name: "git only options for other vcs",
+		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
+			err := app.Run([]string{"", "get", "--vcs", "hg", "--lfs-include", "*.png", "motemen/ghq-test-repo"})
+
+			expect := "--lfs-include only supported for git repositories"
+			if err == nil || !strings.Contains(err.Error(), expect) {
+				t.Errorf("error should contain %q, but: %v", expect, err)
+			}
+		},
+	}, {
+		name: "--no-hardlinks for remote repository",
+		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
+			err := app.Run([]string{"", "get", "--no-hardlinks", "motemen/ghq-test-repo"})
+
+			expect := "--no-hardlinks is only available for cloning from a local repository"
+			if err == nil || !strings.Contains(err.Error(), expect) {
+				t.Errorf("error should contain %q, but: %v", expect, err)
+			}
+		},
+	}, {
+		name: "--also-filter-submodules with --no-recursive",
+		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
+			err := app.Run([]string{"", "get", "--also-filter-submodules", "--no-recursive", "motemen/ghq-test-repo"})
+
+			expect := "--also-filter-submodules cannot be specified with --no-recursive"
+			if err == nil || !strings.Contains(err.Error(), expect) {
+				t.Errorf("error should contain %q, but: %v", expect, err)
+			}
+		},
+	}, {
+		name: "--ref",
+		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
+			for _, tc := range []struct {
+				args   []string
+				expect string
+			}{
+				{[]string{"--prefer", "tag"}, "--prefer requires --ref"},
+				{[]string{"--ref", "v1", "--prefer", "commit"}, `invalid value of --prefer: "commit"`},
+				{[]string{"--ref", "v1", "--branch", "main"}, "--ref cannot be specified with --branch"},
+			} {
+				err := app.Run(append(append([]string{"", "get"}, tc.args...), "motemen/ghq-test-repo"))
+				if err == nil || err.Error() != tc.expect {
+					t.Errorf("error should be %q, but: %v", tc.expect, err)
+				}
+			}
+		},
+	}, {
+		name: "invalid submodule and shallow options",
+		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
+			for _, tc := range []struct {
+				args   []string
+				expect string
+			}{
+				{[]string{"--submodule-parallel", "0"}, "invalid number of jobs for --submodule-parallel: 0"},
+				{[]string{"--submodule-parallel", "4", "--no-recursive"}, "--submodule-parallel cannot be specified with --no-recursive"},
+				{[]string{"--shallow", "--unshallow"}, "--shallow and --unshallow cannot be specified at the same time"},
+			} {
+				err := app.Run(append(append([]string{"", "get"}, tc.args...), "motemen/ghq-test-repo"))
+				if err == nil || err.Error() != tc.expect {
+					t.Errorf("error should be %q, but: %v", tc.expect, err)
+				}
+			}
+		},
+	}, {
+		name: "--pr with multiple repositories",
+		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
+			err := app.Run([]string{"", "get", "--pr", "1", "motemen/ghq-test-repo", "motemen/ghq"})
+
+			expect := "--pr requires exactly one repository"
+			if err == nil || !strings.Contains(err.Error(), expect) {
+				t.Errorf("error should contain %q, but: %v", expect, err)
+			}
+		},
+	}, {
+		name: "--pr for unsupported forge",
+		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
+			err := app.Run([]string{"", "get", "--pr", "1", "https://bitbucket.org/motemen/ghq-test-repo"})
+
+			expect := "--pr is not supported for bitbucket.org"
+			if err == nil || !strings.Contains(err.Error(), expect) {
+				t.Errorf("error should contain %q, but: %v", expect, err)
+			}
+			if cloneArgs.remote != nil {
+				t.Errorf("should not be cloned: %v", cloneArgs.remote)
+			}
+		},
+	}, {
+		name: "ghq.clone.remoteNameTemplate",
+		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
+			defer gitconfig.WithConfig(t, `
+[ghq "clone"]
+  remoteNameTemplate = "{host}"
+`)()
+			app.Run([]string{"", "get", "motemen/ghq-test-repo"})
+
+			expect := "github"
+			if cloneArgs.origin != expect {
+				t.Errorf("got: %s, expect: %s", cloneArgs.origin, expect)
+			}
+		},
+	}, {
+		name: "--notify",
+		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
+			defer func(orig func(string, string)) { notify = orig }(notify)
+			var notified []string
+			notify = func(title, message string) {
+				notified = append(notified, message)
+			}
+			app.Run([]string{"", "get", "--notify", "motemen/ghq-test-repo", "motemen/ghq"})
+
+			expect := []string{"Got 2 repositories"}
+			if !reflect.DeepEqual(notified, expect) {
+				t.Errorf("got: %v, expect: %v", notified, expect)
+			}
+		},
+	}, {
+		name: "ghq.rewriteURL",
+		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
+			defer gitconfig.WithConfig(t, `
+[ghq]
+  rewriteURL = "https://github.com/ https://git.example.com/mirror/github.com/"
+`)()
+			buf := &bytes.Buffer{}
+			dryApp := newApp()
+			dryApp.Writer = buf
+			dryApp.Run([]string{"", "get", "--dry-run", "motemen/ghq-test-repo"})
+			out := buf.String()
+			expect := "https://git.example.com/mirror/github.com/motemen/ghq-test-repo"
+			localDir := filepath.Join(tmpRoot, "github.com", "motemen", "ghq-test-repo")
+			if !strings.Contains(out, "url: "+expect+"\n") {
+				t.Errorf("got: %q, expect to contain: %q", out, expect)
+			}
+			if expectCmd := "command: git clone " + expect + " " + localDir + "\n"; !strings.Contains(out, expectCmd) {
+				t.Errorf("got: %q, expect to contain: %q", out, expectCmd)
+			}
+			if cloneArgs.remote != nil {
+				t.Errorf("nothing should be cloned with --dry-run, but: %s", cloneArgs.remote)
+			}
+
+			app.Run([]string{"", "get", "motemen/ghq-test-repo"})
+
+			if cloneArgs.remote.String() != expect {
+				t.Errorf("got: %s, expect: %s", cloneArgs.remote, expect)
+			}
+			if filepath.ToSlash(cloneArgs.local) != filepath.ToSlash(localDir) {
+				t.Errorf("got: %s, expect: %s", filepath.ToSlash(cloneArgs.local), filepath.ToSlash(localDir))
+			}
+		},
 	}, {
 		name: "ghq.<url>.root",
 		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
@@ -182,6 +429,28 @@ func TestCommandGet(t *testing.T) {
 				t.Errorf("got: %s, expect: %s", filepath.ToSlash(cloneArgs.local), filepath.ToSlash(localDir))
 			}
 		},
+	}, {
+		name: "--root for non-primary root",
+		scenario: func(t *testing.T, tmpRoot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
+			tmpd := newTempDir(t)
+			defer os.RemoveAll(tmpd)
+			_localRepositoryRoots = []string{tmpRoot, tmpd}
+			if err := app.Run([]string{"", "get", "--root", tmpd, "motemen/ghq-test-repo"}); err != nil {
+				t.Fatal(err)
+			}
+
+			localDir := filepath.Join(tmpd, "github.com", "motemen", "ghq-test-repo")
+			if filepath.ToSlash(cloneArgs.local) != filepath.ToSlash(localDir) {
+				t.Errorf("got: %s, expect: %s", filepath.ToSlash(cloneArgs.local), filepath.ToSlash(localDir))
+			}
+
+			unknown := filepath.Join(tmpd, "unknown")
+			err := app.Run([]string{"", "get", "--root", unknown, "motemen/ghq-test-repo"})
+			expect := unknown + " is not one of the roots"
+			if err == nil || !strings.Contains(err.Error(), expect) {
+				t.Errorf("error should contain %q, but: %v", expect, err)
+			}
+		},
 	}}
 
 	for _, tc := range testCases {
@@ -224,13 +493,28 @@ func TestLook(t *testing.T) {
 			t.Errorf("lastCmd.Env[len(lastCmd.Env)-1]: got: %s, expect: %s", gotEnv, expectEnv)
 		}
 
-		err = look("github.com/motemen/_unknown")
+		out, _, _ := capture(func() {
+			err = newApp().Run([]string{"", "get", "--print-path", "https://github.com/motemen/ghq", "motemen/gobump"})
+		})
+		if err != nil {
+			t.Errorf("error should be nil, but: %s", err)
+		}
+		expectOut := dir + "\n" + filepath.Join(tmproot, "github.com", "motemen", "gobump") + "\n"
+		if out != expectOut {
+			t.Errorf("got: %q, expect: %q", out, expectOut)
+		}
+		err = newApp().Run([]string{"", "get", "--print-path", "--look", "https://github.com/motemen/ghq"})
+		if err == nil {
+			t.Errorf("error should be occurred for --print-path with --look")
+		}
+
+		err = look("github.com/motemen/_unknown", false)
 		expect := "No repository found"
 		if !strings.HasPrefix(fmt.Sprintf("%s", err), expect) {
 			t.Errorf("error should has prefix %q, but: %s", expect, err)
 		}
 
-		err = look("gobump")
+		err = look("gobump", false)
 		expect = "More than one repositories are found; Try more precise name"
 		if !strings.HasPrefix(fmt.Sprintf("%s", err), expect) {
 			t.Errorf("error should has prefix %q, but: %s", expect, err)
@@ -253,6 +537,9 @@ func TestDoGet_bulk(t *testing.T) {
 	}, {
 		name: "parallel",
 		args: []string{"-parallel"},
+	}, {
+		name: "parallel with workers",
+		args: []string{"--parallel=2"},
 	}}
 
 	buf := &bytes.Buffer{}
@@ -288,3 +575,184 @@ func TestDoGet_bulk(t *testing.T) {
 		}
 	})
 }
+
+func TestDoGet_failures(t *testing.T) {
+	in := []string{
+		"github.com/x-motemen/ghq",
+		"github.com/blog/invalid",
+		"github.com/motemen/gore",
+	}
+
+	buf := &bytes.Buffer{}
+	logger.SetOutput(buf)
+	defer func() { logger.SetOutput(os.Stderr) }()
+
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		for _, args := range [][]string{{}, {"--parallel=2"}} {
+			t.Run(strings.Join(args, " "), func(t *testing.T) {
+				os.RemoveAll(filepath.Join(tmproot, "github.com"))
+				buf.Reset()
+				var err error
+				captureWithInput(in, func() {
+					err = newApp().Run(append([]string{"", "get"}, args...))
+				})
+				expect := `failed to get 1 repositories (0 timed out): github.com/blog/invalid`
+				if err == nil || err.Error() != expect {
+					t.Errorf("error should be %q, but: %v", expect, err)
+				}
+				if exitStatus(err) != exitStatusPartialFailure {
+					t.Errorf("the batch should partially fail, but: %v", err)
+				}
+				log := filepath.ToSlash(buf.String())
+				for _, r := range []string{"github.com/x-motemen/ghq", "github.com/motemen/gore"} {
+					if !strings.Contains(log, r) {
+						t.Errorf("log should contains %q but not: %s", r, log)
+					}
+				}
+			})
+		}
+	})
+}
+
+func TestDoGet_fromFile(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger.SetOutput(buf)
+	defer func() { logger.SetOutput(os.Stderr) }()
+
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		repos := []string{"github.com/x-motemen/ghq", "github.com/motemen/gore"}
+		for _, r := range repos {
+			os.MkdirAll(filepath.Join(tmproot, r, ".git"), 0755)
+		}
+		manifest := filepath.Join(tmproot, "repos.txt")
+		ioutil.WriteFile(manifest, []byte(`# my repositories
+github.com/x-motemen/ghq
+
+  https://exa mple.com/invalid
+  github.com/motemen/gore  
+`), 0644)
+
+		for _, args := range [][]string{{}, {"--parallel"}} {
+			t.Run(strings.Join(append([]string{"from-file"}, args...), " "), func(t *testing.T) {
+				buf.Reset()
+				err := newApp().Run(append([]string{"", "get", "--from-file", manifest}, args...))
+				if exitStatus(err) != exitStatusPartialFailure {
+					t.Errorf("the batch should partially fail, but: %v", err)
+				}
+				log := filepath.ToSlash(buf.String())
+				if expect := "repos.txt:4: "; !strings.Contains(log, expect) {
+					t.Errorf("log should contain %q but not: %s", expect, log)
+				}
+				for _, r := range repos {
+					if !strings.Contains(log, r) {
+						t.Errorf("log should contain %q but not: %s", r, log)
+					}
+				}
+				if strings.Contains(log, "my repositories") {
+					t.Errorf("comments should be skipped: %s", log)
+				}
+			})
+		}
+
+		err := newApp().Run([]string{"", "get", "--from-file", manifest, "github.com/x-motemen/ghq"})
+		if exitStatus(err) != exitStatusUsage {
+			t.Errorf("usage error should be occurred with the arguments, but: %v", err)
+		}
+	})
+}
+
+func TestDoGet_reference(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		for _, args := range [][]string{
+			{"--dissociate"},
+			{"--reference", filepath.Join(tmproot, "unknown")},
+			{"--reference", tmproot},
+		} {
+			err := newApp().Run(append(append([]string{"", "get"}, args...), "github.com/motemen/ghq"))
+			if exitStatus(err) != exitStatusUsage {
+				t.Errorf("usage error should be occurred for %v, but: %v", args, err)
+			}
+		}
+	})
+}
+
+func TestParallelValue(t *testing.T) {
+	testCases := []struct {
+		in      string
+		expect  parallelValue
+		wantErr bool
+	}{
+		{in: "true", expect: parallelValue{enabled: true}},
+		{in: "false", expect: parallelValue{}},
+		{in: "4", expect: parallelValue{enabled: true, workers: 4}},
+		{in: "0", wantErr: true},
+		{in: "many", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			v := &parallelValue{}
+			err := v.Set(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("error should be occurred but got: %v", *v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *v != tc.expect {
+				t.Errorf("got: %v, expect: %v", *v, tc.expect)
+			}
+		})
+	}
+}
+
+func TestHooksDir(t *testing.T) {
+	tmpd := newTempDir(t)
+	defer os.RemoveAll(tmpd)
+
+	got, err := hooksDir(tmpd)
+	if err != nil || got != tmpd {
+		t.Errorf("got: %s, %v, expect: %s", got, err, tmpd)
+	}
+
+	defer gitconfig.WithConfig(t, fmt.Sprintf(`
+[ghq]
+  hooksPath = "%s"
+`, filepath.ToSlash(tmpd)))()
+	got, err = hooksDir("")
+	if err != nil || filepath.Clean(got) != tmpd {
+		t.Errorf("got: %s, %v, expect: %s", got, err, tmpd)
+	}
+
+	if _, err := hooksDir(filepath.Join(tmpd, "missing")); err == nil {
+		t.Errorf("error should be occurred for the missing directory")
+	}
+}
+
+func TestParseSparseDirs(t *testing.T) {
+	testCases := []struct {
+		dirs    []string
+		expect  []string
+		wantErr bool
+	}{
+		{dirs: []string{"path/to/dir", "/docs/"}, expect: []string{"path/to/dir", "docs"}},
+		{dirs: []string{"a/../b"}, expect: []string{"b"}},
+		{dirs: []string{""}, wantErr: true},
+		{dirs: []string{"."}, wantErr: true},
+		{dirs: []string{"../outside"}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.dirs, ","), func(t *testing.T) {
+			got, err := parseSparseDirs(tc.dirs)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error: %v, wantErr: %t", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.expect) {
+				t.Errorf("got: %v, expect: %v", got, tc.expect)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"github.com/urfave/cli/v2"
+	"github.com/x-motemen/ghq/logger"
+)
+
+// doImport clones the repositories in the manifest printed by `ghq export`
+// from stdin. The repositories cloned already are skipped.
+func doImport(c *cli.Context) error {
+	fd := os.Stdin.Fd()
+	if isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd) {
+		return usageErrorf("no manifest given from stdin. see `ghq import -h` for more details")
+	}
+	urlRewrites, err := loadURLRewrites()
+	if err != nil {
+		return err
+	}
+	var (
+		scr       = &manifestScanner{name: "stdin", scanner: bufio.NewScanner(os.Stdin)}
+		succeeded int
+		failed    []string
+	)
+	for scr.Scan() {
+		u, vcs, err := parseManifestLine(scr.Text())
+		if err != nil {
+			logger.Logf("error", "%s:%d: %s", scr.name, scr.line, err)
+			failed = append(failed, scr.Text())
+			continue
+		}
+		g := &getter{
+			onExists:    onExistsSkip,
+			vcs:         vcs,
+			silent:      c.Bool("silent"),
+			recursive:   true,
+			urlRewrites: urlRewrites,
+		}
+		if err := g.get(context.Background(), u); err != nil {
+			logger.Logf("error", "failed to import %q: %s", u, err)
+			failed = append(failed, u)
+			continue
+		}
+		succeeded++
+	}
+	if err := scr.Err(); err != nil {
+		return fmt.Errorf("error occurred while reading input: %w", err)
+	}
+	if len(failed) > 0 {
+		return partialFailureErrorf(succeeded, "failed to import %d repositories: %s",
+			len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// parseManifestLine parses the line of `ghq export`, "<url>\t<vcs>", where the
+// VCS is optional.
+func parseManifestLine(line string) (string, string, error) {
+	fields := strings.Fields(line)
+	if len(fields) > 2 {
+		return "", "", fmt.Errorf("invalid line %q: <url> and <vcs> are expected", line)
+	}
+	var vcs string
+	if len(fields) == 2 {
+		vcs = fields[1]
+		if _, ok := vcsRegistry[vcs]; !ok {
+			return "", "", fmt.Errorf("invalid vcs %q: %s is available", vcs, strings.Join(vcsNames(), ", "))
+		}
+	}
+	if _, err := newURL(fields[0], false, false); err != nil {
+		return "", "", err
+	}
+	return fields[0], vcs, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/x-motemen/ghq/logger"
+)
+
+func TestParseManifestLine(t *testing.T) {
+	testCases := []struct {
+		line, url, vcs string
+		wantErr        bool
+	}{
+		{line: "https://github.com/x-motemen/ghq\tgit", url: "https://github.com/x-motemen/ghq", vcs: "git"},
+		{line: "git@github.com:x-motemen/ghq.git", url: "git@github.com:x-motemen/ghq.git"},
+		{line: "https://example.com/hg/repo hg", url: "https://example.com/hg/repo", vcs: "hg"},
+		{line: "https://example.com/repo\tcvs", wantErr: true},
+		{line: "https://example.com/repo\tgit\textra", wantErr: true},
+		{line: "https://exa mple.com/repo", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.line, func(t *testing.T) {
+			u, vcs, err := parseManifestLine(tc.line)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("error should be occurred, but got: %s %s", u, vcs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error should be nil but: %s", err)
+			}
+			if u != tc.url || vcs != tc.vcs {
+				t.Errorf("got: %s %s, expect: %s %s", u, vcs, tc.url, tc.vcs)
+			}
+		})
+	}
+}
+
+func TestDoImport(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger.SetOutput(buf)
+	defer func() { logger.SetOutput(os.Stderr) }()
+
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, cloneArgs *_cloneArgs, _ *_updateArgs) {
+		os.MkdirAll(filepath.Join(tmproot, "github.com", "x-motemen", "ghq", ".git"), 0755)
+		in := []string{
+			"# exported",
+			// the VCS is omitted not to use the real git instead of the fake
+			"https://github.com/x-motemen/ghq",
+			"https://github.com/motemen/gore",
+			"https://example.com/repo\tcvs",
+		}
+		var err error
+		captureWithInput(in, func() {
+			err = newApp().Run([]string{"", "import"})
+		})
+		if exitStatus(err) != exitStatusPartialFailure {
+			t.Errorf("the import should partially fail, but: %v", err)
+		}
+		if expect := filepath.Join(tmproot, "github.com", "motemen", "gore"); cloneArgs.local != expect {
+			t.Errorf("got: %s, expect: %s", cloneArgs.local, expect)
+		}
+		log := filepath.ToSlash(buf.String())
+		for _, expect := range []string{"exists", "stdin:4: invalid vcs"} {
+			if !strings.Contains(log, expect) {
+				t.Errorf("log should contain %q but not: %s", expect, log)
+			}
+		}
+	})
+}
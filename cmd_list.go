@@ -1,13 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
+	"text/template"
+	"text/template/parse"
 
 	"github.com/urfave/cli/v2"
+	"github.com/x-motemen/ghq/logger"
 )
 
 func doList(c *cli.Context) error {
@@ -18,7 +25,100 @@ func doList(c *cli.Context) error {
 		vcsBackend       = c.String("vcs")
 		printFullPaths   = c.Bool("full-path")
 		printUniquePaths = c.Bool("unique")
+		noBare           = c.Bool("no-bare")
+		bareOnly         = c.Bool("bare-only")
+		unpushed         = c.Bool("unpushed")
+		printTree        = c.Bool("tree")
+		duplicates       = c.Bool("duplicates")
+		caseCollisions   = c.Bool("case-collisions")
+		contains         = c.String("contains")
+		writable         = c.Bool("writable")
+		noRemote         = c.Bool("no-remote")
+		printJSON        = c.Bool("json")
+		printJSONLines   = c.Bool("json-lines")
+		withStatus       = c.Bool("status")
+		format           = c.String("format")
+		limit            = c.Int("limit")
+		noSort           = c.Bool("no-sort")
+		relativeTo       = c.String("relative-to")
+		vcsRoot          = c.Bool("vcs-root")
 	)
+	if vcsBackend != "" {
+		if _, ok := vcsRegistry[vcsBackend]; !ok {
+			return usageErrorf("invalid --vcs %q: %s is available", vcsBackend, strings.Join(vcsNames(), ", "))
+		}
+	}
+	if noBare && bareOnly {
+		return usageErrorf("--no-bare and --bare-only cannot be specified at the same time")
+	}
+	formats := 0
+	for _, f := range []bool{printUniquePaths, printTree, duplicates, caseCollisions, printJSON, printJSONLines, format != ""} {
+		if f {
+			formats++
+		}
+	}
+	if formats > 1 {
+		return usageErrorf("only one of --unique, --tree, --duplicates, --case-collisions, --json, --json-lines and --format can be specified")
+	}
+	var (
+		tmpl         *template.Template
+		formatFields map[string]bool
+	)
+	if format != "" {
+		var err error
+		if tmpl, err = template.New("format").Parse(format); err != nil {
+			return usageErrorf("invalid --format: %w", err)
+		}
+		formatFields = templateFields(tmpl)
+	}
+	if printTree && printFullPaths {
+		return usageErrorf("--tree cannot be specified with --full-path")
+	}
+	if limit < 0 {
+		return usageErrorf("invalid --limit: %d", limit)
+	}
+	if limit > 0 && (printTree || duplicates || caseCollisions) {
+		return usageErrorf("--limit cannot be specified with --tree, --duplicates nor --case-collisions")
+	}
+	if withStatus && !printJSON && !printJSONLines {
+		return usageErrorf("--status can be specified only with --json or --json-lines")
+	}
+	if relativeTo != "" {
+		if !printFullPaths || formats > 0 {
+			return usageErrorf("--relative-to can be specified only with --full-path, without other formats")
+		}
+		var err error
+		if relativeTo, err = relativeBase(relativeTo); err != nil {
+			return err
+		}
+	}
+	if vcsRoot {
+		if formats > 0 || exact || vcsBackend != "" || noBare || bareOnly || unpushed || contains != "" || writable || noRemote || limit > 0 {
+			return usageErrorf("--vcs-root can be specified only with --full-path and --relative-to")
+		}
+		// the argument is the path, not the query
+		p := query
+		if p == "" {
+			p = "."
+		}
+		repo, err := localRepositoryFromPath(p)
+		if err != nil {
+			return err
+		}
+		switch {
+		case relativeTo != "":
+			fmt.Fprintln(w, relativePath(relativeTo, repo.FullPath))
+		case printFullPaths:
+			fmt.Fprintln(w, repo.FullPath)
+		default:
+			fmt.Fprintln(w, repo.RelPath)
+		}
+		return nil
+	}
+	walkOpts := walkOptions{
+		noCache:            c.Bool("no-cache"),
+		followSymlinksDeep: c.Bool("follow-symlinks-deep"),
+	}
 
 	filterByQuery := func(_ *LocalRepository) bool {
 		return true
@@ -34,7 +134,7 @@ func doList(c *cli.Context) error {
 
 		if exact {
 			filterByQuery = func(repo *LocalRepository) bool {
-				return repo.Matches(query)
+				return repo.MatchesExactly(query)
 			}
 		} else {
 			var host string
@@ -47,72 +147,585 @@ func doList(c *cli.Context) error {
 			if strings.ToLower(query) == query {
 				filterByQuery = func(repo *LocalRepository) bool {
 					return strings.Contains(strings.ToLower(repo.NonHostPath()), query) &&
-						(host == "" || repo.PathParts[0] == host)
+						(host == "" || repo.Host() == "" || repo.Host() == host)
 				}
 			} else {
 				filterByQuery = func(repo *LocalRepository) bool {
 					return strings.Contains(repo.NonHostPath(), query) &&
-						(host == "" || repo.PathParts[0] == host)
+						(host == "" || repo.Host() == "" || repo.Host() == host)
 				}
 			}
 		}
 	}
 
 	var (
-		repos []*LocalRepository
-		mu    sync.Mutex
+		repos     []*LocalRepository
+		mu        sync.Mutex
+		printed   int
+		remotes   = map[string][]string{}             // local paths grouped by remote for --duplicates
+		relPaths  = map[string][]string{}             // local paths grouped by relative path for --duplicates
+		statuses  = map[*LocalRepository]*GitStatus{} // for --status
+		formatted = map[*LocalRepository]string{}     // for --format
 	)
-	if err := walkLocalRepositories(vcsBackend, func(repo *LocalRepository) {
+	toJSON := func(repo *LocalRepository) interface{} {
+		if withStatus {
+			return newRepositoryStatusJSON(repo, statuses[repo])
+		}
+		return newRepositoryJSON(repo)
+	}
+	// Repositories are collected and printed sorted by default. With --no-sort,
+	// they are printed as soon as they are found, in the walking order, unless
+	// --unique, --tree, --duplicates, --case-collisions or --json is specified,
+	// which needs all of them to be collected to detect the unique subpaths,
+	// to group them or to make an array.
+	streaming := noSort && !printUniquePaths && !printTree && !duplicates && !caseCollisions && !printJSON
+	enc := json.NewEncoder(w)
+	printRepo := func(repo *LocalRepository) {
+		switch {
+		case printJSONLines:
+			enc.Encode(toJSON(repo))
+		case tmpl != nil:
+			fmt.Fprintln(w, formatted[repo])
+		case relativeTo != "":
+			fmt.Fprintln(w, relativePath(relativeTo, repo.FullPath))
+		case printFullPaths:
+			fmt.Fprintln(w, repo.FullPath)
+		default:
+			fmt.Fprintln(w, repo.RelPath)
+		}
+	}
+	if err := walkLocalRepositories(vcsBackend, walkOpts, func(repo *LocalRepository) {
 		if !filterByQuery(repo) {
 			return
 		}
+		if (noBare || bareOnly) && repo.IsBare() != bareOnly {
+			return
+		}
+		if contains != "" {
+			if _, err := os.Stat(filepath.Join(repo.FullPath, contains)); err != nil {
+				return
+			}
+		}
+		if writable && !isWritable(repo.FullPath) {
+			return
+		}
+		if noRemote {
+			remotes, err := repo.Remotes()
+			if err != nil {
+				logger.Log("warning", fmt.Sprintf("failed to read the remotes of %s: %s", repo.FullPath, err))
+				return
+			}
+			if len(remotes) > 0 {
+				return
+			}
+		}
+		// the repositories are checked in parallel since the callback is
+		// called concurrently while walking
+		if unpushed {
+			ok, err := repo.HasUnpushedCommits()
+			if err != nil {
+				logger.Log("warning", fmt.Sprintf("failed to check unpushed commits of %s: %s", repo.FullPath, err))
+				return
+			}
+			if !ok {
+				return
+			}
+		}
+		var remote string
+		if duplicates {
+			// repositories without remote are duplicates only by the paths
+			if u, err := repo.RemoteURL(); err == nil && u != "" {
+				remote = normalizeRemoteURL(u)
+			}
+		}
+		var st *GitStatus
+		if withStatus {
+			var err error
+			if st, err = repo.GitStatus(); err != nil {
+				logger.Log("warning", fmt.Sprintf("failed to get the status of %s: %s", repo.FullPath, err))
+			}
+		}
+		var buf bytes.Buffer
+		if tmpl != nil {
+			if err := tmpl.Execute(&buf, newRepositoryFormat(repo, formatFields)); err != nil {
+				logger.Log("warning", fmt.Sprintf("failed to format %s: %s", repo.FullPath, err))
+				return
+			}
+		}
 		mu.Lock()
 		defer mu.Unlock()
+		if withStatus {
+			statuses[repo] = st
+		}
+		if tmpl != nil {
+			formatted[repo] = buf.String()
+		}
+		if duplicates {
+			if remote != "" {
+				remotes[remote] = append(remotes[remote], repo.FullPath)
+			}
+			relPath := filepath.ToSlash(repo.RelPath)
+			relPaths[relPath] = append(relPaths[relPath], repo.FullPath)
+			return
+		}
+		if streaming {
+			// the walk cannot be stopped, so the rest are just ignored
+			if limit > 0 && printed >= limit {
+				return
+			}
+			printed++
+			printRepo(repo)
+			return
+		}
 		repos = append(repos, repo)
 	}); err != nil {
 		return fmt.Errorf("failed to filter repos while walkLocalRepositories(repo): %w", err)
 	}
+	if streaming {
+		return nil
+	}
+	// the relative paths are sorted, and the full paths as well for the
+	// repositories cloned under more than one root
+	sort.Slice(repos, func(i, j int) bool {
+		if repos[i].RelPath != repos[j].RelPath {
+			return repos[i].RelPath < repos[j].RelPath
+		}
+		return repos[i].FullPath < repos[j].FullPath
+	})
+	if printTree {
+		printRepositoryTree(w, repos)
+		return nil
+	}
+	if duplicates {
+		printDuplicates(w, duplicateGroups(remotes, relPaths))
+		return nil
+	}
+	if caseCollisions {
+		printCaseCollisions(w, repos)
+		return nil
+	}
+	if !printUniquePaths && limit > 0 && len(repos) > limit {
+		repos = repos[:limit]
+	}
+	if printJSON {
+		list := make([]interface{}, 0, len(repos))
+		for _, repo := range repos {
+			list = append(list, toJSON(repo))
+		}
+		return enc.Encode(list)
+	}
+	if !printUniquePaths {
+		for _, repo := range repos {
+			printRepo(repo)
+		}
+		return nil
+	}
 
 	repoList := make([]string, 0, len(repos))
-	if printUniquePaths {
-		subpathCount := map[string]int{} // Count duplicated subpaths (ex. foo/dotfiles and bar/dotfiles)
-		reposCount := map[string]int{}   // Check duplicated repositories among roots
+	subpathCount := map[string]int{} // Count duplicated subpaths (ex. foo/dotfiles and bar/dotfiles)
+	reposCount := map[string]int{}   // Check duplicated repositories among roots
 
-		// Primary first
-		for _, repo := range repos {
-			if reposCount[repo.RelPath] == 0 {
-				for _, p := range repo.Subpaths() {
-					subpathCount[p] = subpathCount[p] + 1
-				}
+	// Primary first
+	for _, repo := range repos {
+		if reposCount[repo.RelPath] == 0 {
+			for _, p := range repo.Subpaths() {
+				subpathCount[p] = subpathCount[p] + 1
 			}
-
-			reposCount[repo.RelPath] = reposCount[repo.RelPath] + 1
 		}
 
-		for _, repo := range repos {
-			if reposCount[repo.RelPath] > 1 && !repo.IsUnderPrimaryRoot() {
-				continue
-			}
+		reposCount[repo.RelPath] = reposCount[repo.RelPath] + 1
+	}
 
-			for _, p := range repo.Subpaths() {
-				if subpathCount[p] == 1 {
-					repoList = append(repoList, p)
-					break
-				}
-			}
+	for _, repo := range repos {
+		if reposCount[repo.RelPath] > 1 && !repo.IsUnderPrimaryRoot() {
+			continue
 		}
-	} else {
-		for _, repo := range repos {
-			if printFullPaths {
-				repoList = append(repoList, repo.FullPath)
-			} else {
-				repoList = append(repoList, repo.RelPath)
+
+		for _, p := range repo.Subpaths() {
+			if subpathCount[p] == 1 {
+				repoList = append(repoList, p)
+				break
 			}
 		}
 	}
 	sort.Strings(repoList)
+	if limit > 0 && len(repoList) > limit {
+		repoList = repoList[:limit]
+	}
 	for _, r := range repoList {
 		fmt.Fprintln(w, r)
 	}
 	return nil
 }
+
+// relativeBase returns the absolute path of the base directory of
+// --relative-to, with the symlinks resolved as the roots are.
+func relativeBase(base string) (string, error) {
+	abs, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	if p, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = p
+	}
+	return abs, nil
+}
+
+// relativePath returns the path relative to the base for --relative-to, e.g.
+// "motemen/ghq" for "/home/ghq/github.com/motemen/ghq" under
+// "/home/ghq/github.com". The path is returned as is when it is not under the
+// base, since "../.." is rather harder to use than the full path.
+func relativePath(base, fpath string) string {
+	rel, err := filepath.Rel(base, fpath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fpath
+	}
+	return rel
+}
+
+// repositoryJSON is the representation of a repository for --json and
+// --json-lines
+type repositoryJSON struct {
+	FullPath string `json:"full_path"`
+	RelPath  string `json:"rel_path"`
+	Root     string `json:"root"`
+	Host     string `json:"host"`
+	User     string `json:"user"`
+	Name     string `json:"name"`
+}
+
+// newRepositoryJSON makes repositoryJSON from the path parts of the
+// repository. The name of the repository under subgroups, like GitLab's,
+// consists of the rest of the parts after the user, e.g. "subgroup/repo".
+func newRepositoryJSON(repo *LocalRepository) *repositoryJSON {
+	r := &repositoryJSON{
+		FullPath: repo.FullPath,
+		RelPath:  filepath.ToSlash(repo.RelPath),
+		Root:     repo.RootPath,
+		Host:     repo.Host(),
+	}
+	parts := repo.PathParts
+	if r.Host != "" {
+		parts = parts[1:]
+	}
+	switch len(parts) {
+	case 0:
+	case 1:
+		r.Name = parts[0]
+	default:
+		r.User = parts[0]
+		r.Name = strings.Join(parts[1:], "/")
+	}
+	return r
+}
+
+// repositoryStatusJSON is repositoryJSON with the status of the working tree
+// for --status. The fields are null for other VCSs than git, and "ahead" and
+// "behind" are null as well for the branch without upstream.
+type repositoryStatusJSON struct {
+	*repositoryJSON
+	Dirty  *bool   `json:"dirty"`
+	Ahead  *int    `json:"ahead"`
+	Behind *int    `json:"behind"`
+	Branch *string `json:"branch"`
+}
+
+func newRepositoryStatusJSON(repo *LocalRepository, st *GitStatus) *repositoryStatusJSON {
+	r := &repositoryStatusJSON{repositoryJSON: newRepositoryJSON(repo)}
+	if st == nil {
+		return r
+	}
+	r.Dirty = &st.Dirty
+	if st.HasUpstream {
+		r.Ahead, r.Behind = &st.Ahead, &st.Behind
+	}
+	if st.Branch != "" {
+		r.Branch = &st.Branch
+	}
+	return r
+}
+
+// repositoryFormat is the data of a repository for the template of --format.
+// The fields other than the ones of repositoryJSON are computed only if the
+// template refers to them, since they need VCS commands for each repository.
+// They are zero values for the repositories without them, e.g. the status of
+// other VCSs than git.
+type repositoryFormat struct {
+	*repositoryJSON
+	Remote        string
+	Branch        string
+	Dirty         bool
+	Ahead, Behind int
+	// VCS is the name of the backend, e.g. "git"
+	VCS string
+}
+
+// lazyFormatFields are the fields of repositoryFormat computed lazily
+var lazyFormatFields = []string{"Remote", "Branch", "Dirty", "Ahead", "Behind", "VCS"}
+
+func newRepositoryFormat(repo *LocalRepository, fields map[string]bool) *repositoryFormat {
+	r := &repositoryFormat{repositoryJSON: newRepositoryJSON(repo)}
+	if fields["Remote"] {
+		// the repositories without remote have the empty one
+		r.Remote, _ = repo.RemoteURL()
+	}
+	if fields["VCS"] {
+		// detecting the VCS looks into the directory for the contents
+		vcs, _ := repo.VCS()
+		r.VCS = vcsName(vcs)
+	}
+	if fields["Branch"] || fields["Dirty"] || fields["Ahead"] || fields["Behind"] {
+		st, err := repo.GitStatus()
+		if err != nil {
+			logger.Log("warning", fmt.Sprintf("failed to get the status of %s: %s", repo.FullPath, err))
+		}
+		if st != nil {
+			r.Branch, r.Dirty, r.Ahead, r.Behind = st.Branch, st.Dirty, st.Ahead, st.Behind
+		}
+	}
+	return r
+}
+
+// templateFields returns the names of the fields which the template refers to,
+// e.g. "Remote" for "{{.Remote}}", to compute only them. All the lazy fields
+// are regarded as referred when the dot itself is used, e.g. "{{printf "%v" .}}".
+func templateFields(tmpl *template.Template) map[string]bool {
+	fields := map[string]bool{}
+	var walk func(node parse.Node)
+	walkBranch := func(n *parse.BranchNode) {
+		walk(n.Pipe)
+		walk(n.List)
+		if n.ElseList != nil {
+			walk(n.ElseList)
+		}
+	}
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			for _, c := range n.Nodes {
+				walk(c)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walkBranch(&n.BranchNode)
+		case *parse.RangeNode:
+			walkBranch(&n.BranchNode)
+		case *parse.WithNode:
+			walkBranch(&n.BranchNode)
+		case *parse.TemplateNode:
+			if n.Pipe != nil {
+				walk(n.Pipe)
+			}
+		case *parse.PipeNode:
+			for _, c := range n.Cmds {
+				walk(c)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.ChainNode:
+			walk(n.Node)
+		case *parse.FieldNode:
+			fields[n.Ident[0]] = true
+		case *parse.VariableNode:
+			// "$.Remote" refers to the field of the data
+			if len(n.Ident) > 1 && n.Ident[0] == "$" {
+				fields[n.Ident[1]] = true
+			} else if len(n.Ident) == 1 && n.Ident[0] == "$" {
+				for _, f := range lazyFormatFields {
+					fields[f] = true
+				}
+			}
+		case *parse.DotNode:
+			for _, f := range lazyFormatFields {
+				fields[f] = true
+			}
+		}
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil && t.Tree.Root != nil {
+			walk(t.Tree.Root)
+		}
+	}
+	return fields
+}
+
+// printRepositoryTree prints the repositories grouped by host and then owner
+// with indentation. The rest of the path parts are printed as a leaf.
+func printRepositoryTree(w io.Writer, repos []*LocalRepository) {
+	tree := map[string]map[string][]string{}
+	seen := map[string]bool{}
+	for _, repo := range repos {
+		relPath := filepath.ToSlash(repo.RelPath)
+		if seen[relPath] || len(repo.PathParts) < 2 {
+			continue
+		}
+		seen[relPath] = true
+
+		host, owner := repo.PathParts[0], repo.PathParts[1]
+		if tree[host] == nil {
+			tree[host] = map[string][]string{}
+		}
+		if len(repo.PathParts) == 2 {
+			// the repository placed directly under the host has no leaves
+			if _, ok := tree[host][owner]; !ok {
+				tree[host][owner] = nil
+			}
+			continue
+		}
+		tree[host][owner] = append(tree[host][owner], strings.Join(repo.PathParts[2:], "/"))
+	}
+
+	hosts := make([]string, 0, len(tree))
+	for host := range tree {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		fmt.Fprintln(w, host)
+		owners := make([]string, 0, len(tree[host]))
+		for owner := range tree[host] {
+			owners = append(owners, owner)
+		}
+		sort.Strings(owners)
+		for _, owner := range owners {
+			fmt.Fprintln(w, "  "+owner)
+			names := tree[host][owner]
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintln(w, "    "+name)
+			}
+		}
+	}
+}
+
+// normalizeRemoteURL returns the key to detect the clones of the same remote
+// repository regardless of the protocols and the ".git" suffix, such as
+// "github.com/x-motemen/ghq".
+func normalizeRemoteURL(remote string) string {
+	if !hasSchemePattern.MatchString(remote) && !scpLikeURLPattern.MatchString(remote) {
+		return remote
+	}
+	u, err := newURL(remote, false, false)
+	if err != nil {
+		return remote
+	}
+	p := strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), ".git")
+	return strings.ToLower(localHostname(u) + p)
+}
+
+// printCaseCollisions prints the groups of the repositories whose relative
+// paths differ only by case, which collide on case-insensitive filesystems.
+// Each group is printed as the lowercased path followed by the full paths.
+func printCaseCollisions(w io.Writer, repos []*LocalRepository) {
+	groups := map[string][]*LocalRepository{}
+	for _, repo := range repos {
+		key := strings.ToLower(filepath.ToSlash(repo.RelPath))
+		groups[key] = append(groups[key], repo)
+	}
+	var keys []string
+	for key, group := range groups {
+		for _, repo := range group[1:] {
+			// the same path under different roots is not a collision
+			if repo.RelPath != group[0].RelPath {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintln(w, key)
+		paths := make([]string, 0, len(groups[key]))
+		for _, repo := range groups[key] {
+			paths = append(paths, repo.FullPath)
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			fmt.Fprintln(w, "  "+p)
+		}
+	}
+}
+
+// duplicateGroups returns the groups of the local paths for --duplicates: the
+// ones cloned from the same remote, and the ones of the same relative path
+// under more than one root. The latter are merged into the former of the same
+// key, and are not reported again if one of the former has them all. The
+// paths pointing at the same physical directory by symlinks are not regarded
+// as duplicates.
+func duplicateGroups(remotes, relPaths map[string][]string) map[string][]string {
+	groups := map[string][]string{}
+	grouped := map[string]string{} // the remote of the group for each path
+	for remote, paths := range remotes {
+		for _, p := range paths {
+			grouped[p] = remote
+		}
+		if paths = uniqueRealPaths(paths); len(paths) > 1 {
+			groups[remote] = paths
+		}
+	}
+	for relPath, paths := range relPaths {
+		paths = uniqueRealPaths(paths)
+		if len(paths) < 2 {
+			continue
+		}
+		all := grouped[paths[0]] != ""
+		for _, p := range paths[1:] {
+			all = all && grouped[p] == grouped[paths[0]]
+		}
+		if all {
+			continue
+		}
+		seen := map[string]bool{}
+		for _, p := range groups[relPath] {
+			seen[p] = true
+		}
+		for _, p := range paths {
+			if !seen[p] {
+				groups[relPath] = append(groups[relPath], p)
+			}
+		}
+	}
+	return groups
+}
+
+// uniqueRealPaths removes the paths resolved to the same directory as the
+// former ones by symlinks.
+func uniqueRealPaths(paths []string) []string {
+	seen := map[string]bool{}
+	var ret []string
+	for _, p := range paths {
+		real := p
+		if r, err := filepath.EvalSymlinks(p); err == nil {
+			real = r
+		}
+		if seen[real] {
+			continue
+		}
+		seen[real] = true
+		ret = append(ret, p)
+	}
+	return ret
+}
+
+// printDuplicates prints the groups of the duplicate local paths, with the
+// remote or the relative path as the header of each group.
+func printDuplicates(w io.Writer, remotes map[string][]string) {
+	keys := make([]string, 0, len(remotes))
+	for remote, paths := range remotes {
+		if len(paths) > 1 {
+			keys = append(keys, remote)
+		}
+	}
+	sort.Strings(keys)
+	for _, remote := range keys {
+		fmt.Fprintln(w, remote)
+		paths := remotes[remote]
+		sort.Strings(paths)
+		for _, p := range paths {
+			fmt.Fprintln(w, "  "+p)
+		}
+	}
+}
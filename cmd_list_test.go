@@ -1,16 +1,23 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"sort"
 	"strings"
 	"sync"
 	"testing"
+	"text/template"
 
 	"github.com/urfave/cli/v2"
+	"github.com/x-motemen/ghq/cmdutil"
 )
 
 func flagSet(name string, flags []cli.Flag) *flag.FlagSet {
@@ -119,8 +126,16 @@ func TestDoList_query(t *testing.T) {
 		expect: "github.com/motemen/ghq\n",
 	}, {
 		name:   "exact",
+		args:   []string{"-exact", "motemen/gobump"},
+		expect: "github.com/motemen/gobump\n",
+	}, {
+		name:   "exact with host",
+		args:   []string{"-exact", "github.com/Songmu/gobump"},
+		expect: "github.com/Songmu/gobump\n",
+	}, {
+		name:   "exact tail",
 		args:   []string{"-exact", "gobump"},
-		expect: "github.com/Songmu/gobump\ngithub.com/motemen/gobump\n",
+		expect: "",
 	}, {
 		name:   "query",
 		args:   []string{"men/go"},
@@ -133,6 +148,14 @@ func TestDoList_query(t *testing.T) {
 		name:   "vcs",
 		args:   []string{"--vcs", "svn"},
 		expect: "github.com/msh5/svntest\n",
+	}, {
+		name:   "vcs alias",
+		args:   []string{"--vcs", "subversion"},
+		expect: "github.com/msh5/svntest\n",
+	}, {
+		name:   "vcs git",
+		args:   []string{"--vcs", "git", "golang.org/"},
+		expect: "golang.org/x/crypt\ngolang.org/x/image\n",
 	}, {
 		name:   "smartcasing fuzzy",
 		args:   []string{"awesome"},
@@ -187,6 +210,207 @@ func TestDoList_query(t *testing.T) {
 	})
 }
 
+func TestDoList_invalidVCS(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		err := newApp().Run([]string{"ghq", "list", "--vcs", "cvs"})
+		if err == nil || !strings.HasPrefix(err.Error(), `invalid --vcs "cvs"`) {
+			t.Errorf("error should be occurred for the unknown vcs, but: %v", err)
+		}
+		if exitStatus(err) != exitStatusUsage {
+			t.Errorf("exit status should be %d, but: %d", exitStatusUsage, exitStatus(err))
+		}
+	})
+}
+
+func TestDoList_limit(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		for _, p := range []string{"github.com/c/repo", "github.com/a/repo", "example.com/b/repo", "github.com/b/repo"} {
+			os.MkdirAll(filepath.Join(tmproot, p, ".git"), 0755)
+		}
+		testCases := []struct {
+			name   string
+			args   []string
+			expect string
+		}{{
+			name:   "sorted",
+			args:   []string{},
+			expect: "example.com/b/repo\ngithub.com/a/repo\ngithub.com/b/repo\ngithub.com/c/repo\n",
+		}, {
+			name:   "limit",
+			args:   []string{"--limit", "2"},
+			expect: "example.com/b/repo\ngithub.com/a/repo\n",
+		}, {
+			name:   "limit with query",
+			args:   []string{"--limit", "2", "github.com/"},
+			expect: "github.com/a/repo\ngithub.com/b/repo\n",
+		}, {
+			name:   "limit larger than the repositories",
+			args:   []string{"--limit", "10", "c/repo"},
+			expect: "github.com/c/repo\n",
+		}, {
+			name:   "unique",
+			args:   []string{"--unique", "--limit", "1"},
+			expect: "a/repo\n",
+		}}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				out, _, _ := capture(func() {
+					newApp().Run(append([]string{"ghq", "list"}, tc.args...))
+				})
+				if out != filepath.FromSlash(tc.expect) {
+					t.Errorf("got: %q, expect: %q", out, tc.expect)
+				}
+			})
+		}
+
+		out, _, _ := capture(func() {
+			newApp().Run([]string{"ghq", "list", "--no-sort", "--limit", "3"})
+		})
+		if n := strings.Count(out, "\n"); n != 3 {
+			t.Errorf("3 repositories should be printed with --no-sort, but: %q", out)
+		}
+
+		for _, args := range [][]string{{"--limit", "-1"}, {"--limit", "1", "--tree"}} {
+			err := newApp().Run(append([]string{"ghq", "list"}, args...))
+			if exitStatus(err) != exitStatusUsage {
+				t.Errorf("usage error should be occurred for %v, but: %v", args, err)
+			}
+		}
+	})
+}
+
+func TestDoList_relativeTo(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		for _, p := range []string{"github.com/a/repo", "example.com/b/repo"} {
+			os.MkdirAll(filepath.Join(tmproot, p, ".git"), 0755)
+		}
+		testCases := []struct {
+			name   string
+			args   []string
+			expect string
+		}{{
+			name:   "root",
+			args:   []string{"--relative-to", tmproot},
+			expect: "example.com/b/repo\ngithub.com/a/repo\n",
+		}, {
+			name: "host",
+			args: []string{"--relative-to", filepath.Join(tmproot, "github.com")},
+			expect: filepath.Join(tmproot, "example.com", "b", "repo") + "\n" +
+				filepath.Join("a", "repo") + "\n",
+		}, {
+			name:   "repository itself",
+			args:   []string{"--relative-to", filepath.Join(tmproot, "github.com", "a", "repo"), "a/repo"},
+			expect: ".\n",
+		}}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				out, _, _ := capture(func() {
+					newApp().Run(append([]string{"ghq", "list", "--full-path"}, tc.args...))
+				})
+				if out != filepath.FromSlash(tc.expect) {
+					t.Errorf("got: %q, expect: %q", out, tc.expect)
+				}
+			})
+		}
+
+		for _, args := range [][]string{{"--relative-to", "."}, {"-p", "--json", "--relative-to", "."}} {
+			err := newApp().Run(append([]string{"ghq", "list"}, args...))
+			if exitStatus(err) != exitStatusUsage {
+				t.Errorf("usage error should be occurred for %v, but: %v", args, err)
+			}
+		}
+	})
+}
+
+func TestDoList_vcsRoot(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		repo := filepath.Join(tmproot, "github.com", "motemen", "mono")
+		os.MkdirAll(filepath.Join(repo, ".git"), 0755)
+		os.MkdirAll(filepath.Join(repo, "packages", "app", "src"), 0755)
+		os.MkdirAll(filepath.Join(tmproot, "github.com", "motemen", "plain"), 0755)
+		outside := newTempDir(t)
+		defer os.RemoveAll(outside)
+
+		testCases := []struct {
+			name   string
+			args   []string
+			expect string
+		}{{
+			name:   "repository root",
+			args:   []string{repo},
+			expect: "github.com/motemen/mono\n",
+		}, {
+			name:   "subdirectory",
+			args:   []string{filepath.Join(repo, "packages", "app", "src")},
+			expect: "github.com/motemen/mono\n",
+		}, {
+			name:   "full path",
+			args:   []string{"-p", filepath.Join(repo, "packages")},
+			expect: repo + "\n",
+		}, {
+			name:   "relative to",
+			args:   []string{"-p", "--relative-to", filepath.Join(tmproot, "github.com"), filepath.Join(repo, "packages")},
+			expect: filepath.Join("motemen", "mono") + "\n",
+		}}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				var err error
+				out, _, _ := capture(func() {
+					err = newApp().Run(append([]string{"ghq", "list", "--vcs-root"}, tc.args...))
+				})
+				if err != nil {
+					t.Fatalf("error should be nil, but: %s", err)
+				}
+				if out != tc.expect {
+					t.Errorf("got: %q, expect: %q", out, tc.expect)
+				}
+			})
+		}
+
+		for _, p := range []string{
+			filepath.Join(tmproot, "github.com", "motemen", "plain"),
+			filepath.Join(tmproot, "github.com"),
+			tmproot,
+			outside,
+		} {
+			err := newApp().Run([]string{"ghq", "list", "--vcs-root", p})
+			if exitStatus(err) != exitStatusNotFound {
+				t.Errorf("not found error should be occurred for %s, but: %v", p, err)
+			}
+		}
+		for _, args := range [][]string{{"--json"}, {"--unpushed"}, {"--limit", "1"}} {
+			err := newApp().Run(append([]string{"ghq", "list", "--vcs-root"}, args...))
+			if exitStatus(err) != exitStatusUsage {
+				t.Errorf("usage error should be occurred for %v, but: %v", args, err)
+			}
+		}
+	})
+}
+
+func TestRelativePath(t *testing.T) {
+	base := filepath.FromSlash("/home/ghq/github.com")
+	testCases := []struct {
+		path   string
+		expect string
+	}{
+		{"/home/ghq/github.com/motemen/ghq", "motemen/ghq"},
+		{"/home/ghq/github.com", "."},
+		{"/home/ghq/example.com/motemen/ghq", "/home/ghq/example.com/motemen/ghq"},
+		{"/home/ghq/github.community/ghq", "/home/ghq/github.community/ghq"},
+		{"/home/ghq/github.com/..foo/bar", "..foo/bar"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			got := relativePath(base, filepath.FromSlash(tc.path))
+			if got != filepath.FromSlash(tc.expect) {
+				t.Errorf("got: %q, expect: %q", got, tc.expect)
+			}
+		})
+	}
+}
+
 func TestDoList_unique(t *testing.T) {
 	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
 	defer func(orig string) { os.Setenv(envGhqRoot, orig) }(os.Getenv(envGhqRoot))
@@ -267,3 +491,513 @@ func TestDoList_withSystemHiddenDir(t *testing.T) {
 		t.Errorf("error should be nil, but: %v", err)
 	}
 }
+
+func TestDoList_bare(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		os.MkdirAll(filepath.Join(tmproot, "github.com/motemen/ghq", ".git"), 0755)
+		bare := filepath.Join(tmproot, "github.com/motemen/gore.git")
+		os.MkdirAll(filepath.Join(bare, "objects"), 0755)
+		os.MkdirAll(filepath.Join(bare, "refs"), 0755)
+		ioutil.WriteFile(filepath.Join(bare, "HEAD"), []byte("ref: refs/heads/master\n"), 0644)
+		ioutil.WriteFile(filepath.Join(bare, "config"), []byte("[core]\n\tbare = true\n"), 0644)
+
+		testCases := []struct {
+			name   string
+			args   []string
+			expect string
+		}{{
+			name:   "default",
+			args:   []string{},
+			expect: "github.com/motemen/ghq\ngithub.com/motemen/gore.git\n",
+		}, {
+			name:   "no-bare",
+			args:   []string{"--no-bare"},
+			expect: "github.com/motemen/ghq\n",
+		}, {
+			name:   "bare-only",
+			args:   []string{"--bare-only"},
+			expect: "github.com/motemen/gore.git\n",
+		}}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				out, _, _ := capture(func() {
+					newApp().Run(append([]string{"ghq", "list"}, tc.args...))
+				})
+				if !equalPathLines(out, tc.expect) {
+					t.Errorf("got:\n%s\nexpect:\n%s", out, tc.expect)
+				}
+			})
+		}
+	})
+}
+
+func TestDoList_tree(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		for _, p := range []string{
+			"github.com/motemen/ghq",
+			"github.com/motemen/gore",
+			"github.com/Songmu/gobump",
+			"gitlab.com/group/subgroup/repo",
+			"golang.org/x",
+		} {
+			os.MkdirAll(filepath.Join(tmproot, p, ".git"), 0755)
+		}
+		out, _, _ := capture(func() {
+			newApp().Run([]string{"ghq", "list", "--tree"})
+		})
+		expect := `github.com
+  Songmu
+    gobump
+  motemen
+    ghq
+    gore
+gitlab.com
+  group
+    subgroup/repo
+golang.org
+  x
+`
+		if out != expect {
+			t.Errorf("got:\n%s\nexpect:\n%s", out, expect)
+		}
+
+		err := newApp().Run([]string{"ghq", "list", "--tree", "--unique"})
+		if err == nil {
+			t.Errorf("error should be occurred with --tree and --unique")
+		}
+	})
+}
+
+func TestDoList_duplicates(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		remotes := map[string]string{
+			"github.com/x-motemen/ghq": "https://github.com/x-motemen/ghq",
+			"github.com/motemen/ghq":   "git@github.com:x-motemen/ghq.git",
+			"github.com/Songmu/ghq":    "https://github.com/Songmu/ghq",
+		}
+		for p := range remotes {
+			os.MkdirAll(filepath.Join(tmproot, p, ".git"), 0755)
+		}
+		os.MkdirAll(filepath.Join(tmproot, "github.com/motemen/local", ".git"), 0755)
+
+		defer func(orig func(cmd *exec.Cmd) error) {
+			cmdutil.CommandRunner = orig
+		}(cmdutil.CommandRunner)
+		cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+			rel, _ := filepath.Rel(tmproot, cmd.Dir)
+			remote, ok := remotes[filepath.ToSlash(rel)]
+			switch {
+			case reflect.DeepEqual(cmd.Args, []string{"git", "remote"}):
+				if ok {
+					fmt.Fprintln(cmd.Stdout, "origin")
+				}
+			case reflect.DeepEqual(cmd.Args, []string{"git", "remote", "get-url", "origin"}) && ok:
+				fmt.Fprintln(cmd.Stdout, remote)
+			default:
+				return fmt.Errorf("[test] failed to %s", strings.Join(cmd.Args, " "))
+			}
+			return nil
+		}
+
+		out, _, _ := capture(func() {
+			newApp().Run([]string{"ghq", "list", "--duplicates"})
+		})
+		expect := fmt.Sprintf("github.com/x-motemen/ghq\n  %s\n  %s\n",
+			filepath.Join(tmproot, "github.com/motemen/ghq"),
+			filepath.Join(tmproot, "github.com/x-motemen/ghq"))
+		if out != expect {
+			t.Errorf("got:\n%s\nexpect:\n%s", out, expect)
+		}
+	})
+}
+
+func TestDoList_duplicatesAcrossRoots(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.SkipNow()
+	}
+	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
+	tmp1 := newTempDir(t)
+	defer os.RemoveAll(tmp1)
+	tmp2 := newTempDir(t)
+	defer os.RemoveAll(tmp2)
+	tmpLink := newTempDir(t)
+	defer os.RemoveAll(tmpLink)
+	link := filepath.Join(tmpLink, "link")
+	os.Symlink(tmp1, link)
+
+	// the symlink to tmp1 is the same root
+	defer tmpEnv(envGhqRoot, strings.Join([]string{tmp1, tmp2, link}, string(os.PathListSeparator)))()
+	_localRepositoryRoots = nil
+	localRepoOnce = &sync.Once{}
+	for _, p := range []string{"github.com/motemen/ghq", "github.com/motemen/gore"} {
+		os.MkdirAll(filepath.Join(tmp1, p, ".git"), 0755)
+	}
+	os.MkdirAll(filepath.Join(tmp2, "github.com/motemen/ghq", ".git"), 0755)
+
+	defer func(orig func(cmd *exec.Cmd) error) {
+		cmdutil.CommandRunner = orig
+	}(cmdutil.CommandRunner)
+	cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+		return fmt.Errorf("[test] no remote")
+	}
+
+	out, _, _ := capture(func() {
+		newApp().Run([]string{"ghq", "list", "--duplicates"})
+	})
+	paths := []string{
+		filepath.Join(tmp1, "github.com/motemen/ghq"),
+		filepath.Join(tmp2, "github.com/motemen/ghq"),
+	}
+	sort.Strings(paths)
+	expect := fmt.Sprintf("github.com/motemen/ghq\n  %s\n  %s\n", paths[0], paths[1])
+	if out != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s", out, expect)
+	}
+}
+
+func TestDuplicateGroups_symlinkedRoot(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.SkipNow()
+	}
+	root := newTempDir(t)
+	defer os.RemoveAll(root)
+	tmpLink := newTempDir(t)
+	defer os.RemoveAll(tmpLink)
+	link := filepath.Join(tmpLink, "link")
+	if err := os.Symlink(root, link); err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Join(root, "github.com/a/b", ".git"), 0755)
+
+	// the repository having a remote is reached through both of the roots
+	paths := []string{filepath.Join(root, "github.com/a/b"), filepath.Join(link, "github.com/a/b")}
+	got := duplicateGroups(
+		map[string][]string{"https://github.com/a/b": paths},
+		map[string][]string{"github.com/a/b": paths},
+	)
+	if len(got) != 0 {
+		t.Errorf("the same directory should not be reported, but: %v", got)
+	}
+}
+
+func TestDuplicateGroups(t *testing.T) {
+	testCases := []struct {
+		name     string
+		remotes  map[string][]string
+		relPaths map[string][]string
+		expect   map[string][]string
+	}{{
+		name:     "by remote",
+		remotes:  map[string][]string{"github.com/a/b": {"/r1/github.com/a/b", "/r1/github.com/c/b"}},
+		relPaths: map[string][]string{"github.com/a/b": {"/r1/github.com/a/b"}, "github.com/c/b": {"/r1/github.com/c/b"}},
+		expect:   map[string][]string{"github.com/a/b": {"/r1/github.com/a/b", "/r1/github.com/c/b"}},
+	}, {
+		name:     "by relative path",
+		remotes:  map[string][]string{"github.com/a/b": {"/r1/github.com/a/b"}},
+		relPaths: map[string][]string{"github.com/a/b": {"/r1/github.com/a/b", "/r2/github.com/a/b"}},
+		expect:   map[string][]string{"github.com/a/b": {"/r1/github.com/a/b", "/r2/github.com/a/b"}},
+	}, {
+		name:     "reported by remote already",
+		remotes:  map[string][]string{"github.com/x/y": {"/r1/github.com/a/b", "/r2/github.com/a/b"}},
+		relPaths: map[string][]string{"github.com/a/b": {"/r1/github.com/a/b", "/r2/github.com/a/b"}},
+		expect:   map[string][]string{"github.com/x/y": {"/r1/github.com/a/b", "/r2/github.com/a/b"}},
+	}, {
+		name: "different remotes",
+		remotes: map[string][]string{
+			"github.com/x/y": {"/r1/github.com/a/b", "/r1/github.com/x/y"},
+			"github.com/z/w": {"/r2/github.com/a/b", "/r2/github.com/z/w"},
+		},
+		relPaths: map[string][]string{"github.com/a/b": {"/r1/github.com/a/b", "/r2/github.com/a/b"}},
+		expect: map[string][]string{
+			"github.com/x/y": {"/r1/github.com/a/b", "/r1/github.com/x/y"},
+			"github.com/z/w": {"/r2/github.com/a/b", "/r2/github.com/z/w"},
+			"github.com/a/b": {"/r1/github.com/a/b", "/r2/github.com/a/b"},
+		},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := duplicateGroups(tc.remotes, tc.relPaths)
+			if !reflect.DeepEqual(got, tc.expect) {
+				t.Errorf("got: %v, expect: %v", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestDoList_contains(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		for _, p := range []string{"github.com/motemen/ghq", "github.com/motemen/blogsync", "github.com/motemen/dotfiles"} {
+			os.MkdirAll(filepath.Join(tmproot, p, ".git"), 0755)
+		}
+		ioutil.WriteFile(filepath.Join(tmproot, "github.com/motemen/ghq", "go.mod"), []byte("module github.com/x-motemen/ghq\n"), 0644)
+		ioutil.WriteFile(filepath.Join(tmproot, "github.com/motemen/blogsync", "go.mod"), []byte("module github.com/x-motemen/blogsync\n"), 0644)
+		os.MkdirAll(filepath.Join(tmproot, "github.com/motemen/dotfiles", "go", "go.mod"), 0755)
+
+		out, _, _ := capture(func() {
+			newApp().Run([]string{"ghq", "list", "--contains", "go.mod"})
+		})
+		// the file in a subdirectory is not counted
+		expect := "github.com/motemen/blogsync\ngithub.com/motemen/ghq\n"
+		if !equalPathLines(out, expect) {
+			t.Errorf("got:\n%s\nexpect:\n%s", out, expect)
+		}
+	})
+}
+
+func TestDoList_writable(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("every directory is writable for this user")
+	}
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		for _, p := range []string{"github.com/motemen/ghq", "github.com/motemen/readonly"} {
+			os.MkdirAll(filepath.Join(tmproot, p, ".git"), 0755)
+		}
+		readonly := filepath.Join(tmproot, "github.com/motemen/readonly")
+		os.Chmod(readonly, 0555)
+		defer os.Chmod(readonly, 0755)
+
+		out, _, _ := capture(func() {
+			newApp().Run([]string{"ghq", "list", "--writable"})
+		})
+		expect := "github.com/motemen/ghq\n"
+		if !equalPathLines(out, expect) {
+			t.Errorf("got:\n%s\nexpect:\n%s", out, expect)
+		}
+	})
+}
+
+func TestDoList_noRemote(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		for _, p := range []string{"github.com/motemen/ghq", "github.com/motemen/local-only"} {
+			os.MkdirAll(filepath.Join(tmproot, p, ".git"), 0755)
+		}
+		defer func(orig func(cmd *exec.Cmd) error) {
+			cmdutil.CommandRunner = orig
+		}(cmdutil.CommandRunner)
+		cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+			if filepath.Base(cmd.Dir) == "ghq" {
+				fmt.Fprint(cmd.Stdout, "origin\thttps://github.com/motemen/ghq (fetch)\n")
+			}
+			return nil
+		}
+
+		out, _, _ := capture(func() {
+			newApp().Run([]string{"ghq", "list", "--no-remote"})
+		})
+		expect := "github.com/motemen/local-only\n"
+		if !equalPathLines(out, expect) {
+			t.Errorf("got:\n%s\nexpect:\n%s", out, expect)
+		}
+	})
+}
+
+func TestDoList_json(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		os.MkdirAll(filepath.Join(tmproot, "github.com/motemen/ghq", ".git"), 0755)
+		os.MkdirAll(filepath.Join(tmproot, "gitlab.com/group/subgroup/repo", ".git"), 0755)
+
+		expect := []*repositoryJSON{{
+			FullPath: filepath.Join(tmproot, "github.com/motemen/ghq"),
+			RelPath:  "github.com/motemen/ghq",
+			Root:     tmproot,
+			Host:     "github.com",
+			User:     "motemen",
+			Name:     "ghq",
+		}}
+
+		out, _, _ := capture(func() {
+			newApp().Run([]string{"ghq", "list", "--json", "ghq"})
+		})
+		var got []*repositoryJSON
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("failed to unmarshal %q: %s", out, err)
+		}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("got: %+v, expect: %+v", got, expect)
+		}
+
+		out, _, _ = capture(func() {
+			newApp().Run([]string{"ghq", "list", "--json-lines", "subgroup"})
+		})
+		var gotLine repositoryJSON
+		if err := json.Unmarshal([]byte(out), &gotLine); err != nil {
+			t.Fatalf("failed to unmarshal %q: %s", out, err)
+		}
+		if gotLine.User != "group" || gotLine.Name != "subgroup/repo" {
+			t.Errorf("unexpected user and name: %+v", gotLine)
+		}
+
+		out, _, _ = capture(func() {
+			newApp().Run([]string{"ghq", "list", "--json", "unknown"})
+		})
+		if out != "[]\n" {
+			t.Errorf("got: %q, expect: %q", out, "[]\n")
+		}
+	})
+}
+
+func TestDoList_jsonStatus(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		defer func(orig func(cmd *exec.Cmd) error) {
+			cmdutil.CommandRunner = orig
+		}(cmdutil.CommandRunner)
+		cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+			if strings.Join(cmd.Args, " ") == "git status --porcelain=v2 --branch" {
+				fmt.Fprint(cmd.Stdout, "# branch.head master\n# branch.ab +1 -0\n? new.txt\n")
+				return nil
+			}
+			return fmt.Errorf("[test] unexpected command: %v", cmd.Args)
+		}
+		os.MkdirAll(filepath.Join(tmproot, "github.com/motemen/ghq", ".git"), 0755)
+		os.MkdirAll(filepath.Join(tmproot, "bitbucket.org/motemen/hg-repo", ".hg"), 0755)
+
+		out, _, _ := capture(func() {
+			newApp().Run([]string{"ghq", "list", "--json", "--status"})
+		})
+		var got []map[string]interface{}
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("failed to unmarshal %q: %s", out, err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("length of repositories should be 2, but: %d", len(got))
+		}
+		for _, r := range got {
+			var expect map[string]interface{}
+			switch r["rel_path"] {
+			case "github.com/motemen/ghq":
+				expect = map[string]interface{}{"dirty": true, "ahead": 1.0, "behind": 0.0, "branch": "master"}
+			case "bitbucket.org/motemen/hg-repo":
+				expect = map[string]interface{}{"dirty": nil, "ahead": nil, "behind": nil, "branch": nil}
+			}
+			for k, v := range expect {
+				if got, ok := r[k]; !ok || got != v {
+					t.Errorf("%s of %s: got: %v, expect: %v", k, r["rel_path"], got, v)
+				}
+			}
+		}
+
+		err := newApp().Run([]string{"ghq", "list", "--status"})
+		expect := "--status can be specified only with --json or --json-lines"
+		if err == nil || err.Error() != expect {
+			t.Errorf("error should be %q, but: %v", expect, err)
+		}
+	})
+}
+
+func TestDoList_format(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		os.MkdirAll(filepath.Join(tmproot, "github.com/motemen/ghq", ".git"), 0755)
+		defer func(orig func(cmd *exec.Cmd) error) {
+			cmdutil.CommandRunner = orig
+		}(cmdutil.CommandRunner)
+		var commands []string
+		cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+			commands = append(commands, strings.Join(cmd.Args, " "))
+			switch strings.Join(cmd.Args, " ") {
+			case "git remote":
+				fmt.Fprint(cmd.Stdout, "origin\n")
+			case "git remote get-url origin":
+				fmt.Fprint(cmd.Stdout, "https://github.com/motemen/ghq\n")
+			default:
+				return fmt.Errorf("[test] unexpected command: %v", cmd.Args)
+			}
+			return nil
+		}
+
+		out, _, _ := capture(func() {
+			newApp().Run([]string{"ghq", "list", "--format", "{{.Host}} {{.User}}/{{.Name}}"})
+		})
+		if expect := "github.com motemen/ghq\n"; out != expect {
+			t.Errorf("got: %q, expect: %q", out, expect)
+		}
+		if len(commands) > 0 {
+			t.Errorf("no commands should be run without the lazy fields, but: %v", commands)
+		}
+
+		out, _, _ = capture(func() {
+			newApp().Run([]string{"ghq", "list", "--format", "{{.RelPath}} {{.Remote}}"})
+		})
+		if expect := "github.com/motemen/ghq https://github.com/motemen/ghq\n"; out != expect {
+			t.Errorf("got: %q, expect: %q", out, expect)
+		}
+
+		os.MkdirAll(filepath.Join(tmproot, "example.com/motemen/hgrepo", ".hg"), 0755)
+		out, _, _ = capture(func() {
+			newApp().Run([]string{"ghq", "list", "--format", "{{.VCS}} {{.RelPath}}", "hgrepo"})
+		})
+		if expect := "hg example.com/motemen/hgrepo\n"; out != expect {
+			t.Errorf("got: %q, expect: %q", out, expect)
+		}
+
+		err := newApp().Run([]string{"ghq", "list", "--format", "{{.Host"})
+		if err == nil || !strings.HasPrefix(err.Error(), "invalid --format") {
+			t.Errorf("error should be occurred for the invalid template, but: %v", err)
+		}
+	})
+}
+
+func TestTemplateFields(t *testing.T) {
+	testCases := []struct {
+		format string
+		expect []string
+	}{{
+		format: "{{.Host}}/{{.Name}}",
+		expect: []string{"Host", "Name"},
+	}, {
+		format: "{{if .Dirty}}*{{end}}{{with .Branch}}{{.}}{{end}}",
+		expect: []string{"Ahead", "Behind", "Branch", "Dirty", "Remote", "VCS"},
+	}, {
+		format: `{{range $i, $x := .Name}}{{$.Remote}}{{end}}`,
+		expect: []string{"Name", "Remote"},
+	}, {
+		format: `{{printf "%s" .Remote | len}}`,
+		expect: []string{"Remote"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			tmpl := template.Must(template.New("format").Parse(tc.format))
+			var got []string
+			for f := range templateFields(tmpl) {
+				got = append(got, f)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tc.expect) {
+				t.Errorf("got: %v, expect: %v", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestPrintCaseCollisions(t *testing.T) {
+	newRepo := func(root, relPath string) *LocalRepository {
+		return &LocalRepository{
+			FullPath:  filepath.Join(root, filepath.FromSlash(relPath)),
+			RelPath:   relPath,
+			RootPath:  root,
+			PathParts: strings.Split(relPath, "/"),
+		}
+	}
+	root1, root2 := filepath.FromSlash("/path/to/ghq1"), filepath.FromSlash("/path/to/ghq2")
+	repos := []*LocalRepository{
+		newRepo(root1, "github.com/motemen/ghq"),
+		newRepo(root1, "github.com/Motemen/GHQ"),
+		newRepo(root1, "github.com/x-motemen/gore"),
+		newRepo(root2, "github.com/x-motemen/gore"),
+		newRepo(root2, "github.com/Songmu/gobump"),
+	}
+
+	buf := &strings.Builder{}
+	printCaseCollisions(buf, repos)
+	expect := fmt.Sprintf("github.com/motemen/ghq\n  %s\n  %s\n",
+		filepath.Join(root1, "github.com/Motemen/GHQ"),
+		filepath.Join(root1, "github.com/motemen/ghq"))
+	if buf.String() != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s", buf.String(), expect)
+	}
+
+	err := newApp().Run([]string{"ghq", "list", "--case-collisions", "--duplicates"})
+	if err == nil {
+		t.Errorf("error should be occurred for --case-collisions with --duplicates")
+	}
+}
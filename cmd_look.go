@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"github.com/x-motemen/ghq/cmdutil"
+)
+
+func doLook(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		return usageErrorf("no repository specified. see `ghq look -h` for more details")
+	}
+	return look(name, c.Bool("tmux"))
+}
+
+func detectShell() string {
+	shell := os.Getenv("SHELL")
+	if shell != "" {
+		return shell
+	}
+	if runtime.GOOS == "windows" {
+		return os.Getenv("COMSPEC")
+	}
+	return "/bin/sh"
+}
+
+// lookInto spawns a shell in the repository with GHQ_LOOK, which is set to
+// the relative path of the repository, e.g. for customizing the prompt.
+func lookInto(repo *LocalRepository) error {
+	cmd := exec.Command(detectShell())
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Dir = repo.FullPath
+	cmd.Env = append(os.Environ(), "GHQ_LOOK="+filepath.ToSlash(repo.RelPath))
+	err := cmdutil.RunCommand(cmd, true)
+	// exit with the status of the shell, e.g. of the last command run in it
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return cli.Exit("", exitErr.ExitCode())
+	}
+	return err
+}
+
+// look looks into the repository matching the name. With tmux, a tmux window
+// is opened instead of the subshell when running in tmux.
+func look(name string, tmux bool) error {
+	repo, err := resolveLocalRepository(name, true)
+	if err != nil {
+		return err
+	}
+	if tmux && os.Getenv("TMUX") != "" {
+		return lookIntoTmux(repo)
+	}
+	return lookInto(repo)
+}
+
+// lookIntoTmux switches to the tmux window named after the repository, or
+// creates it in the repository if it does not exist in the current session.
+func lookIntoTmux(repo *LocalRepository) error {
+	name := tmuxWindowName(repo)
+	// "=" matches the window name exactly
+	if err := cmdutil.RunSilently("tmux", "select-window", "-t", "="+name); err == nil {
+		return nil
+	}
+	return cmdutil.RunSilently("tmux", "new-window", "-n", name, "-c", repo.FullPath,
+		"-e", "GHQ_LOOK="+filepath.ToSlash(repo.RelPath))
+}
+
+// tmuxWindowName returns the relative path of the repository without "." and
+// ":", which are the separators of the targets of tmux. The whole path is used
+// not to mix up the repositories of the same name of other owners or hosts.
+func tmuxWindowName(repo *LocalRepository) string {
+	return strings.NewReplacer(".", "_", ":", "_").Replace(filepath.ToSlash(repo.RelPath))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+	"github.com/x-motemen/ghq/cmdutil"
+)
+
+func TestDoLook(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		os.MkdirAll(filepath.Join(tmproot, "github.com", "motemen", "ghq", ".git"), 0755)
+		os.MkdirAll(filepath.Join(tmproot, "github.com", "motemen", "gobump", ".git"), 0755)
+		os.MkdirAll(filepath.Join(tmproot, "github.com", "Songmu", "gobump", ".git"), 0755)
+		defer func(orig func(cmd *exec.Cmd) error) {
+			cmdutil.CommandRunner = orig
+		}(cmdutil.CommandRunner)
+		var lastCmd *exec.Cmd
+		cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+			lastCmd = cmd
+			return nil
+		}
+
+		if err := newApp().Run([]string{"", "look", "motemen/ghq"}); err != nil {
+			t.Fatalf("error should be nil, but: %s", err)
+		}
+		dir := filepath.Join(tmproot, "github.com", "motemen", "ghq")
+		if filepath.Clean(lastCmd.Dir) != dir {
+			t.Errorf("lastCmd.Dir: got: %s, expect: %s", lastCmd.Dir, dir)
+		}
+		if got, expect := lastCmd.Env[len(lastCmd.Env)-1], "GHQ_LOOK=github.com/motemen/ghq"; got != expect {
+			t.Errorf("got: %s, expect: %s", got, expect)
+		}
+
+		lastCmd = nil
+		err := newApp().Run([]string{"", "look", "gobump"})
+		if err == nil || !strings.Contains(err.Error(), "github.com/Songmu/gobump") {
+			t.Errorf("the candidates should be listed, but: %v", err)
+		}
+		if lastCmd != nil {
+			t.Errorf("shell should not be spawned for ambiguous query")
+		}
+
+		if err := newApp().Run([]string{"", "look"}); err == nil {
+			t.Errorf("error should be occurred without the query")
+		}
+
+		if runtime.GOOS == "windows" {
+			return
+		}
+		cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+			return exec.Command("sh", "-c", "exit 3").Run()
+		}
+		err = newApp().Run([]string{"", "look", "motemen/ghq"})
+		excoder, ok := err.(cli.ExitCoder)
+		if !ok {
+			t.Fatalf("cli.ExitCoder should be returned, but: %#v", err)
+		}
+		if excoder.ExitCode() != 3 {
+			t.Errorf("exit code: got: %d, expect: 3", excoder.ExitCode())
+		}
+	})
+}
+
+func TestDoLook_tmux(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		dir := filepath.Join(tmproot, "github.com", "x-motemen", "ghq.vim")
+		os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+		defer func(orig func(cmd *exec.Cmd) error) {
+			cmdutil.CommandRunner = orig
+		}(cmdutil.CommandRunner)
+		var cmds [][]string
+		windowExists := false
+		cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+			cmds = append(cmds, cmd.Args)
+			if cmd.Args[0] == "tmux" && cmd.Args[1] == "select-window" && !windowExists {
+				return errors.New("can't find window")
+			}
+			return nil
+		}
+
+		defer tmpEnv("TMUX", "/tmp/tmux-1000/default,1234,0")()
+		if err := newApp().Run([]string{"", "look", "--tmux", "ghq.vim"}); err != nil {
+			t.Fatal(err)
+		}
+		expect := [][]string{
+			{"tmux", "select-window", "-t", "=github_com/x-motemen/ghq_vim"},
+			{"tmux", "new-window", "-n", "github_com/x-motemen/ghq_vim", "-c", dir, "-e", "GHQ_LOOK=github.com/x-motemen/ghq.vim"},
+		}
+		if !reflect.DeepEqual(cmds, expect) {
+			t.Errorf("got: %v, expect: %v", cmds, expect)
+		}
+
+		cmds = nil
+		windowExists = true
+		if err := newApp().Run([]string{"", "look", "--tmux", "ghq.vim"}); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(cmds, expect[:1]) {
+			t.Errorf("got: %v, expect: %v", cmds, expect[:1])
+		}
+
+		// the repository of the same name of another owner has its own window
+		os.MkdirAll(filepath.Join(tmproot, "github.com", "motemen", "ghq.vim", ".git"), 0755)
+		cmds = nil
+		if err := newApp().Run([]string{"", "look", "--tmux", "motemen/ghq.vim"}); err != nil {
+			t.Fatal(err)
+		}
+		if expect := "=github_com/motemen/ghq_vim"; len(cmds) != 1 || cmds[0][3] != expect {
+			t.Errorf("got: %v, expect to select: %s", cmds, expect)
+		}
+
+		// the shell is spawned out of tmux
+		cmds = nil
+		os.Unsetenv("TMUX")
+		if err := newApp().Run([]string{"", "look", "--tmux", "x-motemen/ghq.vim"}); err != nil {
+			t.Fatal(err)
+		}
+		if len(cmds) != 1 || cmds[0][0] != detectShell() {
+			t.Errorf("shell should be spawned, but: %v", cmds)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"github.com/x-motemen/ghq/cmdutil"
+	"github.com/x-motemen/ghq/logger"
+)
+
+func doMigrate(c *cli.Context) error {
+	var (
+		rewriteRemote = c.Bool("rewrite-remote")
+		toScheme      = c.String("to-scheme")
+		dryRun        = c.Bool("dry-run")
+	)
+	if !rewriteRemote {
+		return usageErrorf("nothing to migrate. see `ghq migrate -h` for more details")
+	}
+	switch toScheme {
+	case "ssh", "https":
+	case "":
+		return usageErrorf("--rewrite-remote requires --to-scheme")
+	default:
+		return usageErrorf("invalid --to-scheme %q: ssh or https is expected", toScheme)
+	}
+
+	repos, err := collectLocalRepositories("", func(repo *LocalRepository) bool {
+		vcs, _ := repo.VCS()
+		return vcs == GitBackend
+	})
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, repo := range repos {
+		if err := migrateRemote(repo, toScheme, dryRun); err != nil {
+			logger.Log("error", fmt.Sprintf("%s: %s", repo.FullPath, err))
+			failed++
+		}
+	}
+	if failed > 0 {
+		return partialFailureErrorf(len(repos)-failed, "failed to rewrite the remotes of %d repositories", failed)
+	}
+	return nil
+}
+
+// migrateRemote rewrites the URL of the remote read by RemoteURL to the scheme
+// by 'git remote set-url'. The remotes in the scheme already or in the other
+// schemes are left as they are.
+func migrateRemote(repo *LocalRepository, scheme string, dryRun bool) error {
+	remote, err := repo.RemoteURL()
+	if err != nil {
+		return err
+	}
+	rewritten, err := rewriteRemoteScheme(remote, scheme)
+	if err != nil {
+		return err
+	}
+	if rewritten == "" {
+		logger.Log("skip", repo.FullPath)
+		return nil
+	}
+	_, dir := repo.VCS()
+	name, err := gitRemoteName(dir)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		logger.Log("dry-run", fmt.Sprintf("would rewrite %s: %s -> %s", repo.FullPath, remote, rewritten))
+		return nil
+	}
+	logger.Log("rewrite", fmt.Sprintf("%s: %s -> %s", repo.FullPath, remote, rewritten))
+	return cmdutil.RunInDirSilently(dir, "git", "remote", "set-url", name, rewritten)
+}
+
+// rewriteRemoteScheme returns the remote URL rewritten to the scheme, ssh or
+// https, keeping the host and the path. The empty string is returned when the
+// remote need not or cannot be rewritten, e.g. git:// or local ones.
+func rewriteRemoteScheme(remote, scheme string) (string, error) {
+	if !hasSchemePattern.MatchString(remote) && !scpLikeURLPattern.MatchString(remote) {
+		return "", nil
+	}
+	u, err := newURL(remote, false, false)
+	if err != nil {
+		return "", err
+	}
+	from := u.Scheme
+	if from == "http" {
+		from = "https"
+	}
+	if from == scheme || (from != "ssh" && from != "https") {
+		return "", nil
+	}
+	switch scheme {
+	case "ssh":
+		// the user of https, e.g. with a token, is not for ssh
+		u.User = nil
+		u, err = convertGitURLHTTPToSSH(u)
+		if err != nil {
+			return "", err
+		}
+		return u.String(), nil
+	default:
+		// the port of ssh is not for https either
+		return (&url.URL{
+			Scheme: "https",
+			Host:   u.Hostname(),
+			Path:   "/" + strings.TrimPrefix(u.Path, "/"),
+		}).String(), nil
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"github.com/x-motemen/ghq/cmdutil"
+	"github.com/x-motemen/ghq/logger"
+)
+
+func doMove(c *cli.Context) error {
+	var (
+		name         = c.Args().Get(0)
+		newName      = c.Args().Get(1)
+		root         = c.String("root")
+		updateRemote = c.Bool("update-remote")
+		dryRun       = c.Bool("dry-run")
+	)
+	if name == "" || (newName == "" && root == "") || c.Args().Len() > 2 {
+		return usageErrorf("specify the repository and the new one or --root. see `ghq move -h` for more details")
+	}
+	repo, err := resolveLocalRepository(name, false)
+	if err != nil {
+		return err
+	}
+	srcRoot, err := rootContaining(repo.FullPath)
+	if err != nil {
+		return err
+	}
+	if root != "" {
+		if root, err = findLocalRepositoryRoot(root); err != nil {
+			return usageErrorf("invalid --root: %w", err)
+		}
+	} else {
+		root = repo.RootPath
+	}
+
+	var (
+		dst = filepath.Join(root, filepath.FromSlash(repo.RelPath))
+		u   *url.URL
+	)
+	if newName != "" {
+		var err error
+		if u, err = newURL(newName, false, false); err != nil {
+			return fmt.Errorf("Could not parse URL %q: %w", newName, err)
+		}
+		newRepo, err := newLocalRepositoryFromURL(u, root)
+		if err != nil {
+			return err
+		}
+		dst = newRepo.FullPath
+	}
+	if _, err := rootContaining(dst); err != nil {
+		return err
+	}
+	if dst == repo.FullPath {
+		return usageErrorf("%s is already there", repo.FullPath)
+	}
+	if strings.HasPrefix(dst, repo.FullPath+string(filepath.Separator)) {
+		return usageErrorf("cannot move %s into itself", repo.FullPath)
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if dryRun {
+		logger.Log("dry-run", fmt.Sprintf("would move %s -> %s", repo.FullPath, dst))
+		return nil
+	}
+	logger.Log("move", fmt.Sprintf("%s -> %s", repo.FullPath, dst))
+	if err := moveDir(repo.FullPath, dst); err != nil {
+		return err
+	}
+	pruneEmptyParents(repo.FullPath, srcRoot)
+
+	// the remote is not changed by moving to another root alone
+	if updateRemote && u != nil {
+		return updateMovedRemote(dst, u)
+	}
+	return nil
+}
+
+// moveDir moves the directory to dst creating its parents. It is copied and
+// then removed when it cannot be renamed, e.g. to another filesystem.
+func moveDir(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyDir(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return fmt.Errorf("failed to move %s to %s: %w", src, dst, err)
+	}
+	return os.RemoveAll(src)
+}
+
+// copyDir copies the directory recursively keeping the permissions, the
+// modification times of the files and the symlinks.
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case fi.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case fi.IsDir():
+			return os.Mkdir(target, fi.Mode().Perm())
+		case fi.Mode().IsRegular():
+			if err := copyFile(p, target, fi.Mode().Perm()); err != nil {
+				return err
+			}
+			return os.Chtimes(target, fi.ModTime(), fi.ModTime())
+		}
+		// sockets and devices are not expected in repositories
+		return nil
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// updateMovedRemote rewrites the URL of the remote read by RemoteURL of the
+// moved git repository to point to the new one. The other VCSs are skipped.
+func updateMovedRemote(dir string, u *url.URL) error {
+	if vcs := findVCSBackend(dir, ""); vcs != GitBackend {
+		logger.Log("skip", fmt.Sprintf("updating the remote is only supported for git: %s", dir))
+		return nil
+	}
+	repo, err := LocalRepositoryFromFullPath(dir, GitBackend)
+	if err != nil {
+		return err
+	}
+	remote, err := repo.RemoteURL()
+	if err != nil {
+		return err
+	}
+	moved, err := movedRemoteURL(remote, u)
+	if err != nil {
+		return err
+	}
+	if moved == "" {
+		logger.Log("skip", fmt.Sprintf("the remote of %s: %s", dir, remote))
+		return nil
+	}
+	name, err := gitRemoteName(dir)
+	if err != nil {
+		return err
+	}
+	logger.Log("rewrite", fmt.Sprintf("%s: %s -> %s", dir, remote, moved))
+	return cmdutil.RunInDirSilently(dir, "git", "remote", "set-url", name, moved)
+}
+
+// movedRemoteURL returns the remote URL rewritten to the host and the path of
+// the new URL, keeping the scheme and the user of the remote and its ".git"
+// suffix. The empty string is returned for the remotes which need not or
+// cannot be rewritten, e.g. local ones.
+func movedRemoteURL(remote string, u *url.URL) (string, error) {
+	if !hasSchemePattern.MatchString(remote) && !scpLikeURLPattern.MatchString(remote) {
+		return "", nil
+	}
+	r, err := newURL(remote, false, false)
+	if err != nil {
+		return "", err
+	}
+	switch r.Scheme {
+	case "ssh", "https", "http", "git":
+	default:
+		return "", nil
+	}
+	moved := *r
+	// the port of the remote is kept for the same host
+	if r.Hostname() != u.Hostname() {
+		moved.Host = u.Hostname()
+	}
+	moved.Path = "/" + strings.TrimPrefix(strings.TrimSuffix(u.Path, ".git"), "/")
+	if strings.HasSuffix(r.Path, ".git") {
+		moved.Path += ".git"
+	}
+	if moved.String() == r.String() {
+		return "", nil
+	}
+	return moved.String(), nil
+}
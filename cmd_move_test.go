@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/x-motemen/ghq/cmdutil"
+)
+
+func TestDoMove(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		root2 := newTempDir(t)
+		defer os.RemoveAll(root2)
+		_localRepositoryRoots = append(_localRepositoryRoots, root2)
+		outside := newTempDir(t)
+		defer os.RemoveAll(outside)
+
+		setup := func() {
+			for _, root := range []string{tmproot, root2} {
+				os.RemoveAll(filepath.Join(root, "github.com"))
+			}
+			for _, p := range []string{"github.com/old/name", "github.com/motemen/ghq"} {
+				os.MkdirAll(filepath.Join(tmproot, p, ".git"), 0755)
+			}
+		}
+
+		var setURLs []string
+		defer func(orig func(cmd *exec.Cmd) error) {
+			cmdutil.CommandRunner = orig
+		}(cmdutil.CommandRunner)
+		cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+			switch args := strings.Join(cmd.Args, " "); {
+			case args == "git remote":
+				fmt.Fprintln(cmd.Stdout, "origin")
+				return nil
+			case args == "git remote get-url origin":
+				fmt.Fprintln(cmd.Stdout, "git@github.com:old/name.git")
+				return nil
+			case strings.HasPrefix(args, "git remote set-url "):
+				setURLs = append(setURLs, args)
+				return nil
+			}
+			return fmt.Errorf("[test] unexpected command: %v", cmd.Args)
+		}
+
+		testCases := []struct {
+			name      string
+			args      []string
+			expectErr int
+			moved     []string
+			removed   []string
+			setURLs   []string
+		}{{
+			name:    "rename",
+			args:    []string{"old/name", "github.com/new/name"},
+			moved:   []string{filepath.Join(tmproot, "github.com/new/name/.git")},
+			removed: []string{filepath.Join(tmproot, "github.com/old")},
+		}, {
+			name:    "update remote",
+			args:    []string{"--update-remote", "old/name", "new/name"},
+			moved:   []string{filepath.Join(tmproot, "github.com/new/name/.git")},
+			setURLs: []string{"git remote set-url origin ssh://git@github.com/new/name.git"},
+		}, {
+			name:    "another root",
+			args:    []string{"--root", root2, "--update-remote", "old/name"},
+			moved:   []string{filepath.Join(root2, "github.com/old/name/.git")},
+			removed: []string{filepath.Join(tmproot, "github.com/old")},
+		}, {
+			name:  "another root and name",
+			args:  []string{"--root", root2, "github.com/old/name", "https://gitlab.com/new/name"},
+			moved: []string{filepath.Join(root2, "gitlab.com/new/name/.git")},
+		}, {
+			name:  "dry-run",
+			args:  []string{"--dry-run", "old/name", "github.com/new/name"},
+			moved: []string{filepath.Join(tmproot, "github.com/old/name/.git")},
+		}, {
+			name:      "destination exists",
+			args:      []string{"old/name", "github.com/motemen/ghq"},
+			expectErr: exitStatusError,
+			moved:     []string{filepath.Join(tmproot, "github.com/old/name/.git")},
+		}, {
+			name:      "same path",
+			args:      []string{"old/name", "github.com/old/name"},
+			expectErr: exitStatusUsage,
+		}, {
+			name:      "into itself",
+			args:      []string{"old/name", "github.com/old/name/sub"},
+			expectErr: exitStatusUsage,
+		}, {
+			name:      "unknown root",
+			args:      []string{"--root", outside, "old/name"},
+			expectErr: exitStatusUsage,
+		}, {
+			name:      "no destination",
+			args:      []string{"old/name"},
+			expectErr: exitStatusUsage,
+		}, {
+			name:      "not found",
+			args:      []string{"unknown", "github.com/new/name"},
+			expectErr: exitStatusNotFound,
+		}}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				setup()
+				setURLs = nil
+				err := newApp().Run(append([]string{"", "move"}, tc.args...))
+				if status := exitStatus(err); status != tc.expectErr {
+					t.Errorf("exit status: got: %d, expect: %d (%v)", status, tc.expectErr, err)
+				}
+				for _, p := range tc.moved {
+					if _, err := os.Stat(p); err != nil {
+						t.Errorf("%s should exist, but: %s", p, err)
+					}
+				}
+				for _, p := range tc.removed {
+					if _, err := os.Stat(p); !os.IsNotExist(err) {
+						t.Errorf("%s should be removed, but: %v", p, err)
+					}
+				}
+				if !reflect.DeepEqual(setURLs, tc.setURLs) {
+					t.Errorf("set-url: got: %v, expect: %v", setURLs, tc.setURLs)
+				}
+			})
+		}
+	})
+}
+
+func TestMovedRemoteURL(t *testing.T) {
+	u := mustParseURL("https://github.com/new/name")
+	testCases := []struct {
+		remote, expect string
+	}{
+		{"https://github.com/old/name", "https://github.com/new/name"},
+		{"https://github.com/old/name.git", "https://github.com/new/name.git"},
+		{"git@github.com:old/name.git", "ssh://git@github.com/new/name.git"},
+		{"ssh://git@github.com:2222/old/name", "ssh://git@github.com:2222/new/name"},
+		{"https://gitlab.com/old/name", "https://github.com/new/name"},
+		{"https://github.com/new/name", ""},
+		{"/path/to/repo", ""},
+		{"file:///path/to/repo", ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.remote, func(t *testing.T) {
+			got, err := movedRemoteURL(tc.remote, u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.expect {
+				t.Errorf("got: %q, expect: %q", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestCopyDir(t *testing.T) {
+	tmpdir := newTempDir(t)
+	defer os.RemoveAll(tmpdir)
+	src := filepath.Join(tmpdir, "src")
+	dst := filepath.Join(tmpdir, "dst")
+	os.MkdirAll(filepath.Join(src, ".git", "objects"), 0755)
+	ioutil.WriteFile(filepath.Join(src, ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "run.sh"), []byte("#!/bin/sh\n"), 0755)
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	os.Chtimes(filepath.Join(src, "run.sh"), mtime, mtime)
+	if runtime.GOOS != "windows" {
+		os.Symlink("run.sh", filepath.Join(src, "link"))
+	}
+
+	if err := copyDir(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dst, ".git", "HEAD"))
+	if err != nil || string(b) != "ref: refs/heads/main\n" {
+		t.Errorf("the file should be copied, but: %q, %v", b, err)
+	}
+	if fi, err := os.Stat(filepath.Join(dst, ".git", "objects")); err != nil || !fi.IsDir() {
+		t.Errorf("the directory should be copied, but: %v", err)
+	}
+	fi, err := os.Stat(filepath.Join(dst, "run.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(mtime) {
+		t.Errorf("mtime: got: %s, expect: %s", fi.ModTime(), mtime)
+	}
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if fi.Mode().Perm() != 0755 {
+		t.Errorf("mode: got: %s, expect: %s", fi.Mode().Perm(), os.FileMode(0755))
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "link")); err != nil || link != "run.sh" {
+		t.Errorf("the symlink should be copied, but: %q, %v", link, err)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/urfave/cli/v2"
+	"github.com/x-motemen/ghq/cmdutil"
+	"github.com/x-motemen/ghq/logger"
+)
+
+// errRemoteCheckUnsupported is returned by checkRemoteGone for the VCSs whose
+// remotes cannot be checked
+var errRemoteCheckUnsupported = errors.New("checking the remote is not supported")
+
+// remoteGoneReg matches the error outputs of `git ls-remote` and `hg identify`
+// telling explicitly that the remote repository does not exist. The other
+// errors, e.g. of the network or of the authentication, are not regarded as
+// gone, since private repositories fail the same way without the credentials.
+var remoteGoneReg = regexp.MustCompile(`(?i)repository (?:'[^']*' |[^ ]+ )?not found|` +
+	`does not appear to be a git repository|` +
+	`returned error: 404|HTTP Error 404`)
+
+// checkRemoteGone reports whether the remote repository is gone. An error is
+// returned if it cannot be told, e.g. for network errors.
+func checkRemoteGone(vcs *VCSBackend, remote string) (bool, error) {
+	var cmd *exec.Cmd
+	switch vcs {
+	case GitBackend:
+		cmd = exec.Command("git", "ls-remote", remote, "HEAD")
+		// fail instead of asking for the credentials or the host key
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		if os.Getenv("GIT_SSH_COMMAND") == "" {
+			cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+		}
+	case MercurialBackend:
+		cmd = exec.Command("hg", "identify", "--noninteractive", remote)
+	default:
+		return false, errRemoteCheckUnsupported
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = ioutil.Discard
+	cmd.Stderr = stderr
+	err := cmdutil.RunCommand(cmd, true)
+	if err == nil {
+		return false, nil
+	}
+	msg := strings.TrimSpace(stderr.String())
+	if remoteGoneReg.MatchString(msg) {
+		return true, nil
+	}
+	if msg != "" {
+		return false, fmt.Errorf("%w: %s", err, msg)
+	}
+	return false, err
+}
+
+// doPrune lists the repositories whose remotes are all gone, and removes them
+// with --force. The repositories without remotes, the ones whose remotes
+// cannot be checked and the ones out of the roots are left as they are.
+func doPrune(c *cli.Context) error {
+	var (
+		w          = c.App.Writer
+		force      = c.Bool("force")
+		yes        = c.Bool("yes")
+		pruneEmpty = c.Bool("prune-empty-dirs")
+	)
+	if force && c.Bool("dry-run") {
+		return usageErrorf("--dry-run and --force cannot be specified at the same time")
+	}
+	if yes && !force {
+		return usageErrorf("--yes requires --force")
+	}
+
+	type candidate struct {
+		repo *LocalRepository
+		root string
+	}
+	var (
+		candidates []candidate
+		mu         sync.Mutex
+	)
+	// the remotes are checked in parallel since the callback is called
+	// concurrently while walking
+	if err := walkAllLocalRepositories(func(repo *LocalRepository) {
+		root, err := rootContaining(repo.FullPath)
+		if err != nil {
+			return
+		}
+		remotes, err := repo.Remotes()
+		if err != nil {
+			logger.Log("warning", fmt.Sprintf("failed to read the remotes of %s: %s", repo.FullPath, err))
+			return
+		}
+		if len(remotes) == 0 {
+			return
+		}
+		vcs, _ := repo.VCS()
+		for name, remote := range remotes {
+			gone, err := checkRemoteGone(vcs, remote)
+			if err == errRemoteCheckUnsupported {
+				return
+			}
+			if err != nil {
+				logger.Log("warning", fmt.Sprintf("failed to check the remote %s of %s: %s", name, repo.FullPath, err))
+				return
+			}
+			if !gone {
+				return
+			}
+		}
+		mu.Lock()
+		candidates = append(candidates, candidate{repo, root})
+		mu.Unlock()
+	}); err != nil {
+		return err
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].repo.FullPath < candidates[j].repo.FullPath
+	})
+
+	if !force {
+		for _, cand := range candidates {
+			fmt.Fprintln(w, cand.repo.FullPath)
+		}
+		return nil
+	}
+	var (
+		r         = bufio.NewReader(os.Stdin)
+		succeeded int
+		failed    []string
+	)
+	for _, cand := range candidates {
+		if !yes && !confirmRemoval(r, cand.repo.FullPath) {
+			continue
+		}
+		if err := removeRepository(cand.repo.FullPath, cand.root, pruneEmpty); err != nil {
+			logger.Log("error", fmt.Sprintf("failed to remove %s: %s", cand.repo.FullPath, err))
+			failed = append(failed, cand.repo.FullPath)
+			continue
+		}
+		succeeded++
+	}
+	if len(failed) > 0 {
+		return partialFailureErrorf(succeeded, "failed to remove %d repositories: %s",
+			len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
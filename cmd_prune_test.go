@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/x-motemen/ghq/cmdutil"
+)
+
+func TestDoPrune(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		remotes := map[string][]string{
+			"github.com/motemen/gone":    {"https://github.com/motemen/gone"},
+			"github.com/motemen/alive":   {"https://github.com/motemen/alive"},
+			"github.com/motemen/offline": {"https://offline.example.com/motemen/offline"},
+			"github.com/motemen/private": {"https://github.com/motemen/private"},
+			"github.com/motemen/forked":  {"https://github.com/motemen/gone", "https://github.com/motemen/alive"},
+			"github.com/motemen/local":   nil,
+		}
+		setup := func() {
+			for p := range remotes {
+				os.MkdirAll(filepath.Join(tmproot, p, ".git"), 0755)
+			}
+		}
+		exists := func(p string) bool {
+			_, err := os.Stat(filepath.Join(tmproot, p))
+			return err == nil
+		}
+
+		defer func(orig func(cmd *exec.Cmd) error) {
+			cmdutil.CommandRunner = orig
+		}(cmdutil.CommandRunner)
+		cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+			switch {
+			case reflect.DeepEqual(cmd.Args, []string{"git", "remote", "-v"}):
+				rel, _ := filepath.Rel(tmproot, cmd.Dir)
+				for i, u := range remotes[filepath.ToSlash(rel)] {
+					fmt.Fprintf(cmd.Stdout, "remote%d\t%s (fetch)\nremote%d\t%s (push)\n", i, u, i, u)
+				}
+				return nil
+			case len(cmd.Args) > 2 && cmd.Args[1] == "ls-remote":
+				switch u := cmd.Args[2]; {
+				case strings.HasSuffix(u, "/gone"):
+					fmt.Fprintf(cmd.Stderr, "remote: Repository not found.\nfatal: repository '%s/' not found\n", u)
+				case strings.Contains(u, "offline"):
+					fmt.Fprintf(cmd.Stderr, "fatal: unable to access '%s/': Could not resolve host: offline.example.com\n", u)
+				case strings.HasSuffix(u, "/private"):
+					// a private repository without the credentials
+					fmt.Fprintf(cmd.Stderr, "fatal: could not read Username for 'https://github.com': terminal prompts disabled\n")
+				default:
+					return nil
+				}
+				return errors.New("exit status 128")
+			}
+			return fmt.Errorf("[test] unexpected command: %s", strings.Join(cmd.Args, " "))
+		}
+
+		testCases := []struct {
+			name     string
+			args     []string
+			input    []string
+			expect   string
+			removed  []string
+			remained []string
+		}{{
+			name:     "list",
+			args:     []string{},
+			expect:   filepath.Join(tmproot, "github.com/motemen/gone") + "\n",
+			remained: []string{"github.com/motemen/gone"},
+		}, {
+			name:     "dry-run",
+			args:     []string{"--dry-run"},
+			expect:   filepath.Join(tmproot, "github.com/motemen/gone") + "\n",
+			remained: []string{"github.com/motemen/gone"},
+		}, {
+			name:     "declined",
+			args:     []string{"--force"},
+			input:    []string{"n"},
+			remained: []string{"github.com/motemen/gone"},
+		}, {
+			name:     "confirmed",
+			args:     []string{"--force"},
+			input:    []string{"y"},
+			removed:  []string{"github.com/motemen/gone"},
+			remained: []string{"github.com/motemen/alive", "github.com/motemen/offline", "github.com/motemen/private", "github.com/motemen/forked", "github.com/motemen/local"},
+		}, {
+			name:     "yes",
+			args:     []string{"--force", "--yes", "--prune-empty-dirs"},
+			removed:  []string{"github.com/motemen/gone"},
+			remained: []string{"github.com/motemen"},
+		}}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				setup()
+				var err error
+				out, _, _ := captureWithInput(tc.input, func() {
+					err = newApp().Run(append([]string{"", "prune"}, tc.args...))
+				})
+				if err != nil {
+					t.Errorf("error should be nil, but: %s", err)
+				}
+				if tc.expect != "" && out != tc.expect {
+					t.Errorf("got: %q, expect: %q", out, tc.expect)
+				}
+				for _, p := range tc.removed {
+					if exists(p) {
+						t.Errorf("%s should be removed", p)
+					}
+				}
+				for _, p := range tc.remained {
+					if !exists(p) {
+						t.Errorf("%s should not be removed", p)
+					}
+				}
+			})
+		}
+
+		for _, args := range [][]string{{"--dry-run", "--force"}, {"--yes"}} {
+			err := newApp().Run(append([]string{"", "prune"}, args...))
+			if exitStatus(err) != exitStatusUsage {
+				t.Errorf("usage error should be occurred for %v, but: %v", args, err)
+			}
+		}
+	})
+}
+
+func TestRemoteGoneReg(t *testing.T) {
+	testCases := []struct {
+		stderr string
+		expect bool
+	}{
+		{"remote: Repository not found.\nfatal: repository 'https://github.com/motemen/gone/' not found", true},
+		{"ERROR: Repository not found.\nfatal: Could not read from remote repository.", true},
+		{"fatal: could not read Username for 'https://github.com': terminal prompts disabled", false},
+		{"remote: Invalid username or password.\nfatal: Authentication failed for 'https://github.com/foo/bar/'", false},
+		{"fatal: unable to access 'https://example.com/foo/': The requested URL returned error: 401", false},
+		{"abort: authorization failed", false},
+		{"fatal: '/path/to/repo' does not appear to be a git repository", true},
+		{"fatal: unable to access 'https://example.com/foo/': The requested URL returned error: 404", true},
+		{"abort: HTTP Error 404: Not Found", true},
+		{"abort: repository /path/to/repo not found", true},
+		{"fatal: unable to access 'https://github.com/foo/bar/': Could not resolve host: github.com", false},
+		{"ssh: connect to host github.com port 22: Connection timed out", false},
+		{"git@github.com: Permission denied (publickey).", false},
+		{"fatal: unable to access 'https://example.com/foo/': The requested URL returned error: 500", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.stderr, func(t *testing.T) {
+			if got := remoteGoneReg.MatchString(tc.stderr); got != tc.expect {
+				t.Errorf("got: %t, expect: %t", got, tc.expect)
+			}
+		})
+	}
+}
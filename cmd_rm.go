@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"github.com/x-motemen/ghq/logger"
+)
+
+func doRm(c *cli.Context) error {
+	var (
+		name       = c.Args().First()
+		dryRun     = c.Bool("dry-run")
+		force      = c.Bool("force")
+		pruneEmpty = c.Bool("prune-empty-dirs")
+	)
+	if name == "" {
+		return usageErrorf("no repository specified. see `ghq rm -h` for more details")
+	}
+	repo, err := resolveLocalRepository(name, false)
+	if err != nil {
+		return err
+	}
+	root, err := rootContaining(repo.FullPath)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		logger.Log("dry-run", fmt.Sprintf("would remove %s", repo.FullPath))
+		return nil
+	}
+	if !force && !confirmRemoval(bufio.NewReader(os.Stdin), repo.FullPath) {
+		return fmt.Errorf("aborted")
+	}
+	return removeRepository(repo.FullPath, root, pruneEmpty)
+}
+
+// confirmRemoval asks whether to remove the path, and reports whether the
+// answer is yes.
+func confirmRemoval(r *bufio.Reader, p string) bool {
+	fmt.Fprintf(os.Stderr, "Remove %s? [y/N] ", p)
+	ans, err := r.ReadString('\n')
+	if err != nil && ans == "" {
+		return false
+	}
+	a := strings.ToLower(strings.TrimSpace(ans))
+	return a == "y" || a == "yes"
+}
+
+// removeRepository removes the repository under the root, and the parent
+// directories left empty as well if pruneEmpty is true.
+func removeRepository(fullPath, root string, pruneEmpty bool) error {
+	logger.Log("remove", fullPath)
+	if err := os.RemoveAll(fullPath); err != nil {
+		return err
+	}
+	if pruneEmpty {
+		pruneEmptyParents(fullPath, root)
+	}
+	return nil
+}
+
+// rootContaining returns the root which the path is placed under. It is an
+// error for the path out of the roots, or the root itself, not to remove
+// anything unexpected.
+func rootContaining(p string) (string, error) {
+	roots, err := localRepositoryRoots(true)
+	if err != nil {
+		return "", err
+	}
+	p = filepath.Clean(p)
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return root, nil
+	}
+	return "", fmt.Errorf("%s is not under the roots of ghq", p)
+}
+
+// pruneEmptyParents removes the empty directories above the removed path,
+// i.e. the directories of the user and the host, up to but not including
+// the root.
+func pruneEmptyParents(removed, root string) {
+	for dir := filepath.Dir(removed); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		// os.Remove fails for a non-empty directory
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDoRm(t *testing.T) {
+	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
+	tmproot := newTempDir(t)
+	defer os.RemoveAll(tmproot)
+	_localRepositoryRoots = []string{tmproot}
+
+	setup := func() {
+		for _, p := range []string{"github.com/motemen/ghq", "github.com/x-motemen/ghq", "github.com/motemen/gobump"} {
+			os.MkdirAll(filepath.Join(tmproot, p, ".git"), 0755)
+		}
+	}
+	exists := func(p string) bool {
+		_, err := os.Stat(filepath.Join(tmproot, p))
+		return err == nil
+	}
+
+	testCases := []struct {
+		name      string
+		args      []string
+		input     []string
+		expectErr string
+		removed   []string
+		remained  []string
+	}{{
+		name:     "force",
+		args:     []string{"-f", "motemen/gobump"},
+		removed:  []string{"github.com/motemen/gobump"},
+		remained: []string{"github.com/motemen"},
+	}, {
+		name:     "confirmed",
+		args:     []string{"github.com/x-motemen/ghq"},
+		input:    []string{"y"},
+		removed:  []string{"github.com/x-motemen/ghq"},
+		remained: []string{"github.com/x-motemen"},
+	}, {
+		name:      "declined",
+		args:      []string{"github.com/x-motemen/ghq"},
+		input:     []string{"n"},
+		expectErr: "aborted",
+		remained:  []string{"github.com/x-motemen/ghq"},
+	}, {
+		name:     "dry-run",
+		args:     []string{"--dry-run", "motemen/gobump"},
+		remained: []string{"github.com/motemen/gobump"},
+	}, {
+		name:     "prune empty dirs",
+		args:     []string{"-f", "--prune-empty-dirs", "x-motemen/ghq"},
+		removed:  []string{"github.com/x-motemen"},
+		remained: []string{"github.com/motemen/ghq"},
+	}, {
+		name:      "ambiguous",
+		args:      []string{"-f", "ghq"},
+		expectErr: "More than one repositories are found",
+		remained:  []string{"github.com/motemen/ghq", "github.com/x-motemen/ghq"},
+	}, {
+		name:      "not found",
+		args:      []string{"-f", "unknown"},
+		expectErr: "No repository found",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setup()
+			var err error
+			captureWithInput(tc.input, func() {
+				err = newApp().Run(append([]string{"", "rm"}, tc.args...))
+			})
+			if tc.expectErr == "" && err != nil {
+				t.Errorf("error should be nil, but: %s", err)
+			}
+			if tc.expectErr != "" && (err == nil || !strings.Contains(err.Error(), tc.expectErr)) {
+				t.Errorf("error should contain %q, but: %v", tc.expectErr, err)
+			}
+			for _, p := range tc.removed {
+				if exists(p) {
+					t.Errorf("%s should be removed", p)
+				}
+			}
+			for _, p := range tc.remained {
+				if !exists(p) {
+					t.Errorf("%s should not be removed", p)
+				}
+			}
+			if !exists("") {
+				t.Errorf("root should not be removed")
+			}
+		})
+	}
+}
+
+func TestRootContaining(t *testing.T) {
+	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
+	root := filepath.FromSlash("/path/to/ghq")
+	_localRepositoryRoots = []string{root}
+
+	testCases := []struct {
+		path    string
+		wantErr bool
+	}{
+		{"/path/to/ghq/github.com/x-motemen/ghq", false},
+		{"/path/to/ghq", true},
+		{"/path/to/ghq/../other/repo", true},
+		{"/path/to/ghq2/github.com/x-motemen/ghq", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			got, err := rootContaining(filepath.FromSlash(tc.path))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !tc.wantErr && got != root {
+				t.Errorf("got: %s, expect: %s", got, root)
+			}
+		})
+	}
+}
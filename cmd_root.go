@@ -1,23 +1,52 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"strings"
 
 	"github.com/urfave/cli/v2"
 )
 
 func doRoot(c *cli.Context) error {
 	var (
-		w   = c.App.Writer
-		all = c.Bool("all")
+		w            = c.App.Writer
+		all          = c.Bool("all")
+		homeRelative = c.Bool("home-relative")
 	)
+	format := func(root string) string {
+		if homeRelative {
+			return shortenHome(root)
+		}
+		return root
+	}
+	if c.Bool("json") {
+		infos, err := localRepositoryRootInfos(c.Bool("with-counts"))
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(w).Encode(infos)
+	}
+	if c.Bool("with-status") {
+		infos, err := localRepositoryRootInfos(false)
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			if all || info.Primary {
+				fmt.Fprintf(w, "%s\t%s\n", format(info.Path), strings.Join(info.labels(), ","))
+			}
+		}
+		return nil
+	}
 	if all {
 		roots, err := localRepositoryRoots(true)
 		if err != nil {
 			return err
 		}
 		for _, root := range roots {
-			fmt.Fprintln(w, root)
+			fmt.Fprintln(w, format(root))
 		}
 		return nil
 	}
@@ -25,6 +54,40 @@ func doRoot(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	fmt.Fprintln(w, root)
+	fmt.Fprintln(w, format(root))
 	return nil
 }
+
+// labels returns the status of the root for the plain text output, e.g.
+// "primary,exists,writable". A root which does not exist is labeled "missing"
+// so that stale ghq.root entries can be detected.
+func (info *RootInfo) labels() []string {
+	var labels []string
+	if info.Primary {
+		labels = append(labels, "primary")
+	}
+	if !info.Exists {
+		return append(labels, "missing")
+	}
+	labels = append(labels, "exists")
+	if info.Writable {
+		labels = append(labels, "writable")
+	}
+	return labels
+}
+
+// shortenHome replaces the home directory at the beginning of the path with
+// "~". The path out of the home directory is returned as it is.
+func shortenHome(p string) string {
+	home, err := getHome()
+	if err != nil || home == "" {
+		return p
+	}
+	if p == home {
+		return "~"
+	}
+	if rel := strings.TrimPrefix(p, home+string(filepath.Separator)); rel != p {
+		return "~" + string(filepath.Separator) + rel
+	}
+	return p
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -117,3 +118,81 @@ func TestDoRoot(t *testing.T) {
 		})
 	}
 }
+
+func TestDoRoot_json(t *testing.T) {
+	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
+	tmpd := newTempDir(t)
+	defer os.RemoveAll(tmpd)
+	os.MkdirAll(filepath.Join(tmpd, "github.com", "motemen", "ghq", ".git"), 0755)
+	unknown := filepath.Join(tmpd, "unknown-ghq-dummy")
+	_localRepositoryRoots = []string{tmpd, unknown}
+
+	out, _, _ := capture(func() {
+		newApp().Run([]string{"", "root", "--json", "--with-counts"})
+	})
+	var infos []*RootInfo
+	if err := json.Unmarshal([]byte(out), &infos); err != nil {
+		t.Fatalf("error should be nil, but: %s", err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("length of roots should be 2, but: %d", len(infos))
+	}
+	if !infos[0].Primary || !infos[0].Exists || !infos[0].Writable ||
+		infos[0].RepoCount == nil || *infos[0].RepoCount != 1 {
+		t.Errorf("unexpected primary root info: %+v", infos[0])
+	}
+	if infos[1].Primary || infos[1].Exists || infos[1].Writable ||
+		infos[1].RepoCount == nil || *infos[1].RepoCount != 0 {
+		t.Errorf("unexpected non-existent root info: %+v", infos[1])
+	}
+}
+
+func TestShortenHome(t *testing.T) {
+	defer func(orig string) { _home = orig }(_home)
+	defer func(orig *sync.Once) { homeOnce = orig }(homeOnce)
+	homeOnce = &sync.Once{}
+	homeOnce.Do(func() {})
+	_home = filepath.FromSlash("/home/motemen")
+
+	testCases := []struct {
+		in, expect string
+	}{
+		{"/home/motemen", "~"},
+		{"/home/motemen/ghq", "~/ghq"},
+		{"/home/motemen2/ghq", "/home/motemen2/ghq"},
+		{"/var/ghq", "/var/ghq"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			got := shortenHome(filepath.FromSlash(tc.in))
+			if expect := filepath.FromSlash(tc.expect); got != expect {
+				t.Errorf("got: %s, expect: %s", got, expect)
+			}
+		})
+	}
+}
+
+func TestDoRoot_withStatus(t *testing.T) {
+	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
+	tmpd := newTempDir(t)
+	defer os.RemoveAll(tmpd)
+	unknown := filepath.Join(tmpd, "unknown-ghq-dummy")
+	_localRepositoryRoots = []string{tmpd, unknown}
+
+	out, _, _ := capture(func() {
+		newApp().Run([]string{"", "root", "--all", "--with-status"})
+	})
+	expect := tmpd + "\tprimary,exists,writable\n" + unknown + "\tmissing\n"
+	if out != expect {
+		t.Errorf("got: %q, expect: %q", out, expect)
+	}
+
+	out, _, _ = capture(func() {
+		newApp().Run([]string{"", "root", "--with-status"})
+	})
+	expect = tmpd + "\tprimary,exists,writable\n"
+	if out != expect {
+		t.Errorf("got: %q, expect: %q", out, expect)
+	}
+}
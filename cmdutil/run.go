@@ -1,6 +1,7 @@
 package cmdutil
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -12,25 +13,34 @@ import (
 
 // Run the command
 func Run(command string, args ...string) error {
-	cmd := exec.Command(command, args...)
-	cmd.Stdout = os.Stderr
-	cmd.Stderr = os.Stderr
+	return RunContext(context.Background(), command, args...)
+}
 
-	return RunCommand(cmd, false)
+// RunContext runs the command, which is killed when the context is done
+func RunContext(ctx context.Context, command string, args ...string) error {
+	return RunInDirContext(ctx, "", command, args...)
 }
 
 // RunSilently runs the command silently
 func RunSilently(command string, args ...string) error {
-	cmd := exec.Command(command, args...)
-	cmd.Stdout = ioutil.Discard
-	cmd.Stderr = ioutil.Discard
+	return RunSilentlyContext(context.Background(), command, args...)
+}
 
-	return RunCommand(cmd, true)
+// RunSilentlyContext runs the command silently, which is killed when the
+// context is done
+func RunSilentlyContext(ctx context.Context, command string, args ...string) error {
+	return RunInDirSilentlyContext(ctx, "", command, args...)
 }
 
 // RunInDir runs the command in the specified directory
 func RunInDir(dir, command string, args ...string) error {
-	cmd := exec.Command(command, args...)
+	return RunInDirContext(context.Background(), dir, command, args...)
+}
+
+// RunInDirContext runs the command in the specified directory, which is
+// killed when the context is done
+func RunInDirContext(ctx context.Context, dir, command string, args ...string) error {
+	cmd := exec.CommandContext(ctx, command, args...)
 	cmd.Stdout = os.Stderr
 	cmd.Stderr = os.Stderr
 	cmd.Dir = dir
@@ -40,7 +50,13 @@ func RunInDir(dir, command string, args ...string) error {
 
 // RunInDirSilently run the command in the specified directory silently
 func RunInDirSilently(dir, command string, args ...string) error {
-	cmd := exec.Command(command, args...)
+	return RunInDirSilentlyContext(context.Background(), dir, command, args...)
+}
+
+// RunInDirSilentlyContext runs the command in the specified directory
+// silently, which is killed when the context is done
+func RunInDirSilentlyContext(ctx context.Context, dir, command string, args ...string) error {
+	cmd := exec.CommandContext(ctx, command, args...)
 	cmd.Stdout = ioutil.Discard
 	cmd.Stderr = ioutil.Discard
 	cmd.Dir = dir
@@ -79,3 +95,8 @@ type RunError struct {
 func (e *RunError) Error() string {
 	return fmt.Sprintf("%s: %s", e.Command.Path, e.ExecError)
 }
+
+// Unwrap returns the error of the execution, e.g. *exec.ExitError
+func (e *RunError) Unwrap() error {
+	return e.ExecError
+}
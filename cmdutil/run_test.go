@@ -1,9 +1,12 @@
 package cmdutil
 
 import (
+	"context"
 	"fmt"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestRunInDirSilently(t *testing.T) {
@@ -34,3 +37,18 @@ func TestRunSilently(t *testing.T) {
 		t.Errorf("error should be nil but: %s", err)
 	}
 }
+
+func TestRunContext(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sleep is not available on windows")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := RunSilentlyContext(ctx, "sleep", "10")
+	if err == nil {
+		t.Fatal("error should be occurred")
+	}
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Errorf("the command should be killed at the deadline, but: %s", err)
+	}
+}
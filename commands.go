@@ -12,6 +12,15 @@ var commands = []*cli.Command{
 	commandList,
 	commandRoot,
 	commandCreate,
+	commandRm,
+	commandMove,
+	commandPrune,
+	commandLook,
+	commandMigrate,
+	commandCache,
+	commandDoctor,
+	commandExport,
+	commandImport,
 }
 
 var commandGet = &cli.Command{
@@ -25,16 +34,74 @@ var commandGet = &cli.Command{
 	Action: doGet,
 	Flags: []cli.Flag{
 		&cli.BoolFlag{Name: "update", Aliases: []string{"u"},
-			Usage: "Update local repository if cloned already"},
+			Usage: "Update local repository if cloned already (same as --on-exists=update)"},
+		&cli.StringFlag{Name: "on-exists",
+			Usage: "What to do if cloned already: `skip`, update, replace or error. It takes precedence over -u"},
 		&cli.BoolFlag{Name: "p", Usage: "Clone with SSH"},
 		&cli.BoolFlag{Name: "shallow", Usage: "Do a shallow clone"},
+		&cli.IntFlag{Name: "depth", Usage: "Do a shallow clone of the last `N` commits, or a full clone if 0 (git and git-svn only)"},
+		&cli.BoolFlag{Name: "unshallow", Usage: "Fetch the full history of shallow clones when updating (git only)"},
+		&cli.BoolFlag{Name: "prune", Usage: "Remove the remote-tracking branches deleted upstream when updating (git only)"},
+		&cli.DurationFlag{Name: "skip-if-fetched-within",
+			Usage: "Skip updating git repositories fetched within `duration`, e.g. 1h, for frequent bulk updates"},
 		&cli.BoolFlag{Name: "look", Aliases: []string{"l"}, Usage: "Look after get"},
+		&cli.BoolFlag{Name: "print-path", Usage: "Print the path of the repository after getting it, e.g. for cd"},
 		&cli.StringFlag{Name: "vcs", Usage: "Specify `vcs` backend for cloning"},
-		&cli.BoolFlag{Name: "silent", Aliases: []string{"s"}, Usage: "clone or update silently"},
+		&cli.BoolFlag{Name: "silent", Aliases: []string{"s"}, Usage: "Suppress the outputs of the VCS commands and print a progress line per repository"},
 		&cli.BoolFlag{Name: "no-recursive", Usage: "prevent recursive fetching"},
+		&cli.IntFlag{Name: "submodule-parallel",
+			Usage: "Fetch `N` submodules in parallel when cloning or updating recursively (git 2.9 or later)"},
 		&cli.StringFlag{Name: "branch", Aliases: []string{"b"},
 			Usage: "Specify `branch` name. This flag implies --single-branch on Git"},
-		&cli.BoolFlag{Name: "parallel", Aliases: []string{"P"}, Usage: "Import parallely"},
+		&cli.StringFlag{Name: "ref", Usage: "Check out the branch or tag `name` after cloning (git only)"},
+		&cli.StringFlag{Name: "prefer",
+			Usage: "Which of `tag` or branch to check out when --ref matches both. Left to git by default"},
+		&parallelFlag{Name: "parallel", Aliases: []string{"P"},
+			Usage: "Import parallely. The number of workers can be given by --parallel=N"},
+		&cli.BoolFlag{Name: "dry-run",
+			Usage: "Print the URL to clone from, the path, the VCS and the commands to clone without running them nor updating"},
+		&cli.StringFlag{Name: "from-file",
+			Usage: "Read the repositories from `file` one per line, skipping blank lines and # comments"},
+		&cli.BoolFlag{Name: "print-target",
+			Usage: "Print the path where the repository would be cloned, without cloning nor looking for existing clones"},
+		&cli.StringFlag{Name: "root", Usage: "Use the repository under the `root` among multiple roots"},
+		&cli.BoolFlag{Name: "strict", Usage: "Fail when the repository is cloned under more than one root or --shallow or --depth is unsupported by the VCS"},
+		&cli.StringSliceFlag{Name: "env",
+			Usage: "Set the environment variable `KEY=VALUE` for the VCS commands, which can be specified multiple times"},
+		&cli.StringFlag{Name: "username", Usage: "Authenticate as `user` (Subversion and git-svn only)"},
+		&cli.BoolFlag{Name: "lfs", Usage: "Run 'git lfs pull' after cloning a repository using Git LFS"},
+		&cli.StringFlag{Name: "lfs-include", Usage: "Fetch only LFS files matching `glob` (git only)"},
+		&cli.StringFlag{Name: "lfs-exclude", Usage: "Do not fetch LFS files matching `glob` (git only)"},
+		&cli.StringSliceFlag{Name: "set-remote",
+			Usage: "Set the remote `name=url` after cloning, which can be specified multiple times (git only)"},
+		&cli.StringFlag{Name: "bundle",
+			Usage: "Clone from the bundle `file` and then set the remote to the repository URL, e.g. for offline provisioning (git only)"},
+		&cli.BoolFlag{Name: "mirror", Usage: "Make a bare mirror clone having all the refs, e.g. for backups (git only)"},
+		&cli.StringFlag{Name: "mirror-to", Usage: "Push a mirror of the cloned repository to `url` (git only)"},
+		&cli.BoolFlag{Name: "verify-clean-after",
+			Usage: "Fail if the working tree has modified or untracked files after cloning (git only)"},
+		&cli.StringFlag{Name: "hooks-dir",
+			Usage: "Set core.hooksPath of the repository to the shared hooks `directory` after cloning or updating (git only)"},
+		&cli.BoolFlag{Name: "gc", Usage: "Run 'git gc --auto' after cloning or updating (git only)"},
+		&cli.BoolFlag{Name: "gc-aggressive", Usage: "Run 'git gc --aggressive' after cloning or updating (git only)"},
+		&cli.BoolFlag{Name: "no-gc", Usage: "Disable automatic gc of git while cloning or updating"},
+		&cli.StringSliceFlag{Name: "sparse",
+			Usage: "Check out only the `directory` by sparse-checkout after a partial clone, which can be specified multiple times (git 2.25 or later)"},
+		&cli.BoolFlag{Name: "also-filter-submodules",
+			Usage: "Do a partial clone without blobs for the submodules as well (git 2.36 or later)"},
+		&cli.BoolFlag{Name: "insecure", Usage: "Skip verifying TLS certificates of the servers (git, hg and svn)"},
+		&cli.IntFlag{Name: "pr",
+			Usage: "Fetch the pull request `number` into the pr-<number> branch and check it out (GitHub and GitLab)"},
+		&cli.BoolFlag{Name: "no-hardlinks", Usage: "Copy objects instead of hardlinking them when cloning from a local repository (git only)"},
+		&cli.StringFlag{Name: "reference",
+			Usage: "Borrow the objects from the local `repository`, e.g. the clone of the upstream of a fork (git only)"},
+		&cli.BoolFlag{Name: "dissociate", Usage: "Copy the objects borrowed by --reference to make the clone independent of it"},
+		&cli.BoolFlag{Name: "notify", Usage: "Send a desktop notification summarizing the result when finished"},
+		&cli.IntFlag{Name: "retry", Usage: "Retry cloning up to `N` times with exponential backoff when it fails"},
+		&cli.DurationFlag{Name: "timeout",
+			Usage: "Give up getting the repositories not finished after `duration` in total, killing the VCS commands"},
+		&cli.DurationFlag{Name: "clone-timeout-per-repo",
+			Usage: "Give up getting each repository separately after `duration`, killing the VCS command, and continue with the rest"},
 	},
 }
 
@@ -45,14 +112,40 @@ var commandList = &cli.Command{
     List locally cloned repositories. If a query argument is given, only
     repositories whose names contain that query text are listed.
     '-e' ('--exact') forces the match to be an exact one (i.e. the query equals to
-    project or user/project) If '-p' ('--full-path') is given, the full paths
-    to the repository root are printed instead of relative ones.`,
+    user/project, or host/user/project if it has the host) If '-p' ('--full-path') is given, the full paths
+    to the repository root are printed instead of relative ones.
+    Repositories are collected and printed sorted by their relative paths.
+    '--no-sort' prints them as soon as they are found, in no particular order.
+    '--limit' prints at most the number of repositories.
+    '--vcs-root' takes a path instead of the query, the current directory by
+    default, and prints the repository enclosing it, e.g. from a subdirectory
+    of a monorepo.`,
 	Action: doList,
 	Flags: []cli.Flag{
 		&cli.BoolFlag{Name: "exact", Aliases: []string{"e"}, Usage: "Perform an exact match"},
 		&cli.StringFlag{Name: "vcs", Usage: "Specify `vcs` backend for matching"},
 		&cli.BoolFlag{Name: "full-path", Aliases: []string{"p"}, Usage: "Print full paths"},
+		&cli.StringFlag{Name: "relative-to", Usage: "Print full paths relative to `dir`, e.g. '.', for the repositories under it"},
 		&cli.BoolFlag{Name: "unique", Usage: "Print unique subpaths"},
+		&cli.BoolFlag{Name: "no-bare", Usage: "Exclude bare and mirror repositories"},
+		&cli.BoolFlag{Name: "bare-only", Usage: "List only bare and mirror repositories"},
+		&cli.BoolFlag{Name: "unpushed", Usage: "List only git repositories with unpushed commits"},
+		&cli.BoolFlag{Name: "tree", Usage: "Print repositories grouped by host and owner"},
+		&cli.BoolFlag{Name: "json", Usage: "Print repositories as an array of JSON objects"},
+		&cli.BoolFlag{Name: "json-lines", Usage: "Print repositories as JSON objects line by line"},
+		&cli.BoolFlag{Name: "status", Usage: "Include the status of git working trees in JSON output"},
+		&cli.StringFlag{Name: "format",
+			Usage: "Print repositories by Go `template`, e.g. '{{.Host}} {{.Name}}', with .Remote, .Branch, .Dirty, .Ahead, .Behind and .VCS"},
+		&cli.StringFlag{Name: "contains", Usage: "List only repositories containing `file` in their root, e.g. go.mod"},
+		&cli.BoolFlag{Name: "writable", Usage: "List only repositories writable by the current user"},
+		&cli.BoolFlag{Name: "no-remote", Usage: "List only repositories without any remote, e.g. local-only ones"},
+		&cli.BoolFlag{Name: "duplicates", Usage: "Print repositories cloned from the same remote or under more than one root"},
+		&cli.BoolFlag{Name: "case-collisions", Usage: "Print repositories whose paths differ only by case"},
+		&cli.BoolFlag{Name: "no-cache", Usage: "Walk the roots without using the cached index of the repositories"},
+		&cli.BoolFlag{Name: "follow-symlinks-deep", Usage: "Walk into the directories linked by symlinks as well as the linked repositories"},
+		&cli.IntFlag{Name: "limit", Usage: "Print at most `N` repositories, the first ones in the sorted order"},
+		&cli.BoolFlag{Name: "no-sort", Usage: "Print repositories as soon as found without sorting them"},
+		&cli.BoolFlag{Name: "vcs-root", Usage: "Print the repository enclosing the path given as the argument, the current directory by default"},
 	},
 }
 
@@ -62,6 +155,11 @@ var commandRoot = &cli.Command{
 	Action: doRoot,
 	Flags: []cli.Flag{
 		&cli.BoolFlag{Name: "all", Usage: "Show all roots"},
+		&cli.BoolFlag{Name: "json", Usage: "Show all roots with their status in JSON"},
+		&cli.BoolFlag{Name: "with-status",
+			Usage: "Append the status of each root (primary, exists or missing, writable) separated by a tab"},
+		&cli.BoolFlag{Name: "home-relative", Usage: "Shorten the home directory in the paths with ~"},
+		&cli.BoolFlag{Name: "with-counts", Usage: "Count repositories under each root in JSON output"},
 	},
 }
 
@@ -71,6 +169,125 @@ var commandCreate = &cli.Command{
 	Action: doCreate,
 	Flags: []cli.Flag{
 		&cli.StringFlag{Name: "vcs", Usage: "Specify `vcs` backend explicitly"},
+		&cli.BoolFlag{Name: "set-origin", Usage: "Set the repository URL as the origin remote (git only)"},
+		&cli.BoolFlag{Name: "initial-commit", Usage: "Create an empty initial commit (git only)"},
+	},
+}
+
+var commandRm = &cli.Command{
+	Name:  "rm",
+	Usage: "Remove a local repository",
+	Description: `
+    Remove the local repository matching the query, which is resolved like
+    'ghq look'. It asks for confirmation unless '--force' is given.`,
+	Action: doRm,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{Name: "dry-run", Usage: "Show the repository to be removed without removing it"},
+		&cli.BoolFlag{Name: "force", Aliases: []string{"f"}, Usage: "Remove without confirmation"},
+		&cli.BoolFlag{Name: "prune-empty-dirs", Usage: "Remove the parent directories left empty, up to the root"},
+	},
+}
+
+var commandMove = &cli.Command{
+	Name:  "move",
+	Usage: "Move a local repository to another path or root",
+	Description: `
+    Move the local repository matching the query, which is resolved like
+    'ghq look', to the path of the new repository, e.g. after renaming the
+    owner, or under another root given by '--root'. It refuses to overwrite
+    an existing directory.`,
+	Action: doMove,
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "root", Usage: "Move the repository under the `root` among multiple roots"},
+		&cli.BoolFlag{Name: "update-remote", Usage: "Rewrite the URL of the remote to the new repository (git only)"},
+		&cli.BoolFlag{Name: "dry-run", Usage: "Show the paths to be moved without moving it"},
+	},
+}
+
+var commandPrune = &cli.Command{
+	Name:  "prune",
+	Usage: "List or remove local repositories whose remotes are gone",
+	Description: `
+    List the local repositories whose remotes are all gone upstream, e.g.
+    deleted, by 'git ls-remote' or 'hg identify'. They are only listed by
+    default, and '--force' removes them with confirmation for each. The
+    repositories without remotes or whose remotes cannot be checked, e.g. for
+    network errors, are never removed.`,
+	Action: doPrune,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{Name: "dry-run", Usage: "List the repositories to be removed without removing them (default)"},
+		&cli.BoolFlag{Name: "force", Aliases: []string{"f"}, Usage: "Remove the repositories, asking for confirmation for each"},
+		&cli.BoolFlag{Name: "yes", Aliases: []string{"y"}, Usage: "Remove the repositories without confirmation with --force"},
+		&cli.BoolFlag{Name: "prune-empty-dirs", Usage: "Remove the parent directories left empty, up to the root"},
+	},
+}
+
+var commandLook = &cli.Command{
+	Name:  "look",
+	Usage: "Look into a local repository",
+	Description: `
+    Look into a locally cloned repository with the shell. The repository is
+    matched by its path, e.g. 'ghq', 'x-motemen/ghq' or 'github.com/x-motemen/ghq'.`,
+	Action: doLook,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{Name: "tmux", Usage: "Open a tmux window named after the repository instead of the shell, when in tmux"},
+	},
+}
+
+var commandMigrate = &cli.Command{
+	Name:  "migrate",
+	Usage: "Migrate local repositories in bulk",
+	Description: `
+    Migrate all the local Git repositories at once. '--rewrite-remote' rewrites
+    the URL of the "origin" remote to the scheme given by '--to-scheme',
+    keeping the host and the path, e.g. after moving from https to ssh.`,
+	Action: doMigrate,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{Name: "rewrite-remote", Usage: "Rewrite the URL of the origin remote"},
+		&cli.StringFlag{Name: "to-scheme", Usage: "The `scheme` to rewrite the remote URL to: ssh or https"},
+		&cli.BoolFlag{Name: "dry-run", Usage: "Show the remotes to be rewritten without rewriting them"},
+	},
+}
+
+var commandCache = &cli.Command{
+	Name:  "cache",
+	Usage: "Manage the cached index of the repositories",
+	Subcommands: []*cli.Command{{
+		Name:   "clear",
+		Usage:  "Remove the cached index of the repositories",
+		Action: doCacheClear,
+	}},
+}
+
+var commandDoctor = &cli.Command{
+	Name:  "doctor",
+	Usage: "Report the configuration and its problems",
+	Description: `
+    Print the roots with the number of the repositories under them and the
+    commands of the VCSs found on PATH, and report the problems like missing or
+    read-only roots. It exits with non-zero status if a fatal one is found.`,
+	Action: doDoctor,
+}
+
+var commandExport = &cli.Command{
+	Name:  "export",
+	Usage: "Print the manifest of local repositories for import",
+	Description: `
+    Print the remote URL and the VCS of each local repository separated by a
+    tab, one per line, which 'ghq import' consumes to clone them again, e.g. on
+    a new machine.`,
+	Action: doExport,
+}
+
+var commandImport = &cli.Command{
+	Name:  "import",
+	Usage: "Clone the repositories in the manifest from stdin",
+	Description: `
+    Clone the repositories in the manifest printed by 'ghq export' from the
+    standard input. The ones cloned already are skipped.`,
+	Action: doImport,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{Name: "silent", Aliases: []string{"s"}, Usage: "Clone silently"},
 	},
 }
 
@@ -80,16 +297,25 @@ type commandDoc struct {
 }
 
 var commandDocs = map[string]commandDoc{
-	"get":    {"", "[-u] [-p] [--shallow] [--vcs <vcs>] [--look] [--silent] [--branch <branch>] [--no-recursive] <repository URL>|<project>|<user>/<project>|<host>/<user>/<project>"},
-	"list":   {"", "[-p] [-e] [<query>]"},
-	"create": {"", "<project>|<user>/<project>|<host>/<user>/<project>"},
-	"root":   {"", "[-all]"},
+	"get":     {"", "[-u|--on-exists=<action>] [-p] [--shallow|--depth <N>] [--vcs <vcs>] [--look] [--silent] [--branch <branch>] [--no-recursive] <repository URL>|<project>|<user>/<project>|<host>/<user>/<project>"},
+	"list":    {"", "[--vcs <vcs>] [-p [--relative-to <dir>]|--tree|(--json|--json-lines) [--status]|--format <template>] [-e] [--no-bare|--bare-only] [--unpushed] [--contains <file>] [--writable] [--no-remote] [--no-cache] [--follow-symlinks-deep] [--limit <N>] [--no-sort] [<query>]|--vcs-root [-p] [<path>]"},
+	"create":  {"", "[--vcs <vcs>] [--set-origin] [--initial-commit] <repository URL>|<project>|<user>/<project>|<host>/<user>/<project>"},
+	"rm":      {"", "[--dry-run] [-f|--force] [--prune-empty-dirs] <query>"},
+	"move":    {"", "[--root <root>] [--update-remote] [--dry-run] <query> [<repository URL>|<user>/<project>|<host>/<user>/<project>]"},
+	"prune":   {"", "[--dry-run|-f|--force [-y|--yes]] [--prune-empty-dirs]"},
+	"look":    {"", "[--tmux] <project>|<user>/<project>|<host>/<user>/<project>"},
+	"migrate": {"", "--rewrite-remote --to-scheme ssh|https [--dry-run]"},
+	"cache":   {"", "clear"},
+	"root":    {"", "[--all] [--with-status] [--home-relative] [--json [--with-counts]]"},
+	"doctor":  {"", ""},
+	"export":  {"", ""},
+	"import":  {"", "[-s|--silent] < manifest"},
 }
 
 // Makes template conditionals to generate per-command documents.
 func mkCommandsTemplate(genTemplate func(commandDoc) string) string {
 	template := "{{if false}}"
-	for _, command := range append(commands) {
+	for _, command := range commands {
 		template = template + fmt.Sprintf("{{else if (eq .Name %q)}}%s", command.Name, genTemplate(commandDocs[command.Name]))
 	}
 	return template + "{{end}}"
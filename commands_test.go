@@ -12,12 +12,17 @@ type _cloneArgs struct {
 	remote    *url.URL
 	local     string
 	shallow   bool
+	depth     int
 	branch    string
 	recursive bool
+	deadline  bool
+	origin    string
+	env       []string
 }
 
 type _updateArgs struct {
 	local string
+	env   []string
 }
 
 func withFakeGitBackend(t *testing.T, block func(*testing.T, string, *_cloneArgs, *_updateArgs)) {
@@ -33,21 +38,33 @@ func withFakeGitBackend(t *testing.T, block func(*testing.T, string, *_cloneArgs
 	var originalGitBackend = GitBackend
 	tmpBackend := &VCSBackend{
 		Clone: func(vg *vcsGetOption) error {
+			if vg.commands != nil {
+				// --dry-run only records the command
+				return vg.run("git", "clone", vg.url.String(), vg.dir)
+			}
+			_, deadline := vg.ctx.Deadline()
 			cloneArgs = _cloneArgs{
 				remote:    vg.url,
 				local:     filepath.FromSlash(vg.dir),
 				shallow:   vg.shallow,
+				depth:     vg.depth,
 				branch:    vg.branch,
 				recursive: vg.recursive,
+				deadline:  deadline,
+				origin:    vg.origin,
+				env:       vg.env,
 			}
 			return nil
 		},
 		Update: func(vg *vcsGetOption) error {
 			updateArgs = _updateArgs{
 				local: vg.dir,
+				env:   vg.env,
 			}
 			return nil
 		},
+		SupportsShallow: true,
+		SupportsDepth:   true,
 	}
 	defer func(orig string) { _home = orig }(_home)
 	_home = ""
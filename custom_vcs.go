@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Songmu/gitconfig"
+)
+
+// customVCSKeyReg matches the keys of the custom VCS backends in gitconfig,
+// "ghq.vcs.<name>.<key>", e.g. "ghq.vcs.mytool.clone"
+var customVCSKeyReg = regexp.MustCompile(`^ghq\.vcs\.(.+)\.(clone|update|contents|init)$`)
+
+var (
+	_customVCSErr error
+	customVCSOnce = &sync.Once{}
+)
+
+// loadCustomVCSBackends registers the VCS backends defined in gitconfig into
+// vcsRegistry and vcsContentsMap, so that they are used as the built-in ones,
+// e.g. by --vcs, ghq.<url>.vcs and the detection by the contents.
+//
+//	[ghq "vcs.mytool"]
+//	clone = mytool clone {url} {path}
+//	update = mytool pull
+//	contents = .mytool
+//	init = mytool init
+//
+// The commands are run by the shell with "{url}", "{path}" and "{branch}"
+// replaced with the quoted values. "clone", "update" and "contents" are
+// required.
+func loadCustomVCSBackends() error {
+	customVCSOnce.Do(func() {
+		_customVCSErr = func() error {
+			out, err := gitconfig.Do("--get-regexp", `^ghq\.vcs\.`)
+			if err != nil {
+				// git itself is missing, which is reported by the commands
+				if gitconfig.IsNotFound(err) || errors.Is(err, exec.ErrNotFound) {
+					return nil
+				}
+				return err
+			}
+			confs, err := parseCustomVCSConfig(out)
+			if err != nil {
+				return err
+			}
+			names := make([]string, 0, len(confs))
+			for name := range confs {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if err := registerCustomVCSBackend(name, confs[name]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}()
+	})
+	return _customVCSErr
+}
+
+// customVCSConfig is the configuration of a custom VCS backend
+type customVCSConfig struct {
+	clone, update, init string
+	contents            []string
+}
+
+// parseCustomVCSConfig parses the output of `git config --null --get-regexp`,
+// the records of "<key>\n<value>" terminated by NUL, into the configurations
+// by the names.
+func parseCustomVCSConfig(out string) (map[string]*customVCSConfig, error) {
+	confs := map[string]*customVCSConfig{}
+	for _, record := range strings.Split(out, "\x00") {
+		kv := strings.SplitN(strings.TrimSpace(record), "\n", 2)
+		m := customVCSKeyReg.FindStringSubmatch(kv[0])
+		if m == nil {
+			continue
+		}
+		var value string
+		if len(kv) == 2 {
+			value = strings.TrimSpace(kv[1])
+		}
+		name, key := m[1], m[2]
+		conf, ok := confs[name]
+		if !ok {
+			conf = &customVCSConfig{}
+			confs[name] = conf
+		}
+		switch key {
+		case "clone":
+			conf.clone = value
+		case "update":
+			conf.update = value
+		case "init":
+			conf.init = value
+		case "contents":
+			// can have multiple values, or be separated by spaces
+			conf.contents = append(conf.contents, strings.Fields(value)...)
+		}
+	}
+	for name, conf := range confs {
+		for _, r := range []struct {
+			key string
+			ok  bool
+		}{{"clone", conf.clone != ""}, {"update", conf.update != ""}, {"contents", len(conf.contents) > 0}} {
+			if !r.ok {
+				return nil, fmt.Errorf("ghq.vcs.%s.%s is required for the custom VCS %q", name, r.key, name)
+			}
+		}
+	}
+	return confs, nil
+}
+
+// registerCustomVCSBackend registers the backend by the name and its
+// contents. They cannot override the built-in ones.
+func registerCustomVCSBackend(name string, conf *customVCSConfig) error {
+	if _, ok := vcsRegistry[name]; ok {
+		return fmt.Errorf("invalid custom VCS %q: the name is used by another VCS", name)
+	}
+	for _, c := range conf.contents {
+		if _, ok := vcsContentsMap[c]; ok {
+			return fmt.Errorf("invalid ghq.vcs.%s.contents %q: it is used by another VCS", name, c)
+		}
+	}
+	backend := newCustomVCSBackend(name, conf)
+	vcsRegistry[name] = backend
+	for _, c := range conf.contents {
+		vcsContentsMap[c] = backend
+		vcsContents = append(vcsContents, c)
+	}
+	return nil
+}
+
+func newCustomVCSBackend(name string, conf *customVCSConfig) *VCSBackend {
+	expand := func(command string, vg *vcsGetOption) (string, error) {
+		if vg.branch != "" && !strings.Contains(command, "{branch}") {
+			return "", fmt.Errorf("--branch is not supported for the custom VCS %q", name)
+		}
+		var u string
+		if vg.url != nil {
+			u = vg.url.String()
+		}
+		// the values are quoted not to be split or interpreted by the shell,
+		// e.g. the paths having spaces or the URLs given by ghq import
+		return strings.NewReplacer(
+			"{url}", quoteShellArg(u),
+			"{path}", quoteShellArg(vg.dir),
+			"{branch}", quoteShellArg(vg.branch),
+		).Replace(command), nil
+	}
+	return &VCSBackend{
+		Clone: func(vg *vcsGetOption) error {
+			command, err := expand(conf.clone, vg)
+			if err != nil {
+				return err
+			}
+			dir, _ := filepath.Split(vg.dir)
+			if err := vg.mkdirAll(dir); err != nil {
+				return err
+			}
+			shell, flag := shellCommand()
+			return vg.runInDir(dir, shell, flag, command)
+		},
+		Update: func(vg *vcsGetOption) error {
+			command, err := expand(conf.update, vg)
+			if err != nil {
+				return err
+			}
+			shell, flag := shellCommand()
+			return vg.runInDir(vg.dir, shell, flag, command)
+		},
+		Init: func(dir string, silent bool) error {
+			if conf.init == "" {
+				return fmt.Errorf("ghq.vcs.%s.init is not configured", name)
+			}
+			shell, flag := shellCommand()
+			return runInit(dir, silent, shell, flag, strings.Replace(conf.init, "{path}", quoteShellArg(dir), -1))
+		},
+		Contents: conf.contents,
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Songmu/gitconfig"
+	"github.com/x-motemen/ghq/cmdutil"
+)
+
+func withCustomVCSRegistry(t *testing.T) {
+	t.Helper()
+	origRegistry := map[string]*VCSBackend{}
+	for k, v := range vcsRegistry {
+		origRegistry[k] = v
+	}
+	origContentsMap := map[string]*VCSBackend{}
+	for k, v := range vcsContentsMap {
+		origContentsMap[k] = v
+	}
+	origContents := append([]string{}, vcsContents...)
+	customVCSOnce = &sync.Once{}
+	t.Cleanup(func() {
+		vcsRegistry = origRegistry
+		vcsContentsMap = origContentsMap
+		vcsContents = origContents
+		customVCSOnce = &sync.Once{}
+	})
+}
+
+func TestLoadCustomVCSBackends(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config string
+		expect []string // contents of the custom backends
+		err    string
+	}{{
+		name:   "none",
+		config: "",
+	}, {
+		name: "valid",
+		config: `[ghq "vcs.mytool"]
+	clone = mytool clone {url} {path}
+	update = mytool pull
+	contents = .mytool
+	contents = .mytool2
+`,
+		expect: []string{".mytool", ".mytool2"},
+	}, {
+		name: "missing update",
+		config: `[ghq "vcs.mytool"]
+	clone = mytool clone {url} {path}
+	contents = .mytool
+`,
+		err: "ghq.vcs.mytool.update is required",
+	}, {
+		name: "built-in name",
+		config: `[ghq "vcs.hg"]
+	clone = mytool clone {url} {path}
+	update = mytool pull
+	contents = .mytool
+`,
+		err: `invalid custom VCS "hg"`,
+	}, {
+		name: "built-in contents",
+		config: `[ghq "vcs.mytool"]
+	clone = mytool clone {url} {path}
+	update = mytool pull
+	contents = .git
+`,
+		err: `invalid ghq.vcs.mytool.contents ".git"`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			withCustomVCSRegistry(t)
+			defer gitconfig.WithConfig(t, tc.config)()
+
+			err := loadCustomVCSBackends()
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Errorf("error should contain %q, but: %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error should be nil, but: %s", err)
+			}
+			if len(tc.expect) == 0 {
+				return
+			}
+			backend, ok := vcsRegistry["mytool"]
+			if !ok {
+				t.Fatalf("mytool should be registered")
+			}
+			if !reflect.DeepEqual(backend.Contents, tc.expect) {
+				t.Errorf("got: %v, expect: %v", backend.Contents, tc.expect)
+			}
+			for _, c := range tc.expect {
+				if vcsContentsMap[c] != backend {
+					t.Errorf("%s should be mapped to mytool", c)
+				}
+			}
+			if got := vcsContents[len(vcsContents)-len(tc.expect):]; !reflect.DeepEqual(got, tc.expect) {
+				t.Errorf("contents should be appended, but: %v", vcsContents)
+			}
+		})
+	}
+}
+
+func TestCustomVCSBackend(t *testing.T) {
+	withCustomVCSRegistry(t)
+	defer gitconfig.WithConfig(t, `[ghq "vcs.mytool"]
+	clone = mytool clone {url} {path}
+	update = mytool pull {branch}
+	contents = .mytool
+`)()
+	if err := loadCustomVCSBackends(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	defer func(orig func(cmd *exec.Cmd) error) {
+		cmdutil.CommandRunner = orig
+	}(cmdutil.CommandRunner)
+	cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+		got = cmd.Args[2:]
+		return nil
+	}
+
+	tmpdir := newTempDir(t)
+	localDir := filepath.Join(tmpdir, "repo")
+	backend := vcsRegistry["mytool"]
+
+	if err := backend.Clone(&vcsGetOption{
+		url: mustParseURL("https://example.com/motemen/repo"),
+		dir: localDir,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if expect := []string{"mytool clone https://example.com/motemen/repo " + localDir}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("got: %v, expect: %v", got, expect)
+	}
+
+	if err := backend.Update(&vcsGetOption{dir: localDir, branch: "main"}); err != nil {
+		t.Fatal(err)
+	}
+	if expect := []string{"mytool pull main"}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("got: %v, expect: %v", got, expect)
+	}
+
+	err := backend.Clone(&vcsGetOption{
+		url:    mustParseURL("https://example.com/motemen/repo"),
+		dir:    localDir,
+		branch: "main",
+	})
+	if err == nil || !strings.Contains(err.Error(), "--branch is not supported") {
+		t.Errorf("error should be occurred for --branch, but: %v", err)
+	}
+
+	if err := backend.Init(localDir, true); err == nil {
+		t.Errorf("error should be occurred without init")
+	}
+
+	os.MkdirAll(filepath.Join(localDir, ".mytool"), 0755)
+	if vcs := findVCSBackend(localDir, ""); vcs != backend {
+		t.Errorf("mytool should be detected by the contents")
+	}
+	if vcs := findVCSBackend(localDir, "mytool"); vcs != backend {
+		t.Errorf("mytool should be detected by the name")
+	}
+	if indexedVCSBackends()["mytool"] != backend {
+		t.Errorf("mytool should be recorded in the repository index")
+	}
+
+	// the values are quoted for the shell
+	spaced := filepath.Join(tmpdir, "Jane Doe", "repo")
+	if err := backend.Clone(&vcsGetOption{
+		url: mustParseURL("https://example.com/motemen/repo;$(touch%20pwned)"),
+		dir: spaced,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"mytool clone 'https://example.com/motemen/repo;$(touch%20pwned)' " + shellQuote(spaced)}
+	if runtime.GOOS == "windows" {
+		expect = []string{`mytool clone "https://example.com/motemen/repo;$(touch%20pwned)" "` + spaced + `"`}
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("got: %v, expect: %v", got, expect)
+	}
+}
+
+func TestCustomVCSBackend_prefix(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		withCustomVCSRegistry(t)
+		defer gitconfig.WithConfig(t, `[ghq "vcs.mytool"]
+	clone = mytool clone {url} {path}
+	update = mytool pull
+	contents = .mytool
+`)()
+		if err := loadCustomVCSBackends(); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		defer func(orig func(cmd *exec.Cmd) error) {
+			cmdutil.CommandRunner = orig
+		}(cmdutil.CommandRunner)
+		cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+			got = cmd.Args[2:]
+			return nil
+		}
+
+		if err := newApp().Run([]string{"", "get", "mytool::https://example.com/motemen/repo"}); err != nil {
+			t.Fatal(err)
+		}
+		localDir := filepath.Join(tmproot, "example.com", "motemen", "repo")
+		if expect := []string{"mytool clone https://example.com/motemen/repo " + shellQuote(localDir)}; !reflect.DeepEqual(got, expect) {
+			t.Errorf("got: %v, expect: %v", got, expect)
+		}
+	})
+}
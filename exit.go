@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// The exit statuses of ghq, which are stable so that scripts can tell the
+// failures apart.
+const (
+	exitStatusOK             = 0
+	exitStatusError          = 1 // generic errors
+	exitStatusUsage          = 2 // invalid flags or arguments
+	exitStatusPartialFailure = 3 // some of the repositories failed in bulk operations
+	exitStatusNotFound       = 4 // no repository matches
+)
+
+// exitError is the error with the exit status. It implements cli.ExitCoder,
+// which main looks for through the wrapped errors.
+type exitError struct {
+	status int
+	err    error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func (e *exitError) ExitCode() int {
+	return e.status
+}
+
+// exitStatus returns the exit status for the error, looking for cli.ExitCoder
+// like exitError through the wrapped errors.
+func exitStatus(err error) int {
+	if err == nil {
+		return exitStatusOK
+	}
+	var excoder cli.ExitCoder
+	if errors.As(err, &excoder) {
+		return excoder.ExitCode()
+	}
+	return exitStatusError
+}
+
+func usageErrorf(format string, a ...interface{}) error {
+	return &exitError{status: exitStatusUsage, err: fmt.Errorf(format, a...)}
+}
+
+func notFoundErrorf(format string, a ...interface{}) error {
+	return &exitError{status: exitStatusNotFound, err: fmt.Errorf(format, a...)}
+}
+
+// partialFailureErrorf returns the error with exitStatusPartialFailure if any
+// of the repositories succeeded, or exitStatusError if all of them failed.
+func partialFailureErrorf(succeeded int, format string, a ...interface{}) error {
+	status := exitStatusPartialFailure
+	if succeeded == 0 {
+		status = exitStatusError
+	}
+	return &exitError{status: status, err: fmt.Errorf(format, a...)}
+}
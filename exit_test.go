@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestExitStatus(t *testing.T) {
+	testCases := []struct {
+		name   string
+		err    error
+		expect int
+	}{
+		{"nil", nil, exitStatusOK},
+		{"generic", fmt.Errorf("failed"), exitStatusError},
+		{"usage", usageErrorf("invalid"), exitStatusUsage},
+		{"wrapped not found", fmt.Errorf("wrapped: %w", notFoundErrorf("No repository found")), exitStatusNotFound},
+		{"partial failure", partialFailureErrorf(1, "failed to get 1 repositories"), exitStatusPartialFailure},
+		{"total failure", partialFailureErrorf(0, "failed to get 2 repositories"), exitStatusError},
+		{"cli.Exit", cli.Exit("", 130), 130},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := exitStatus(tc.err); got != tc.expect {
+				t.Errorf("got: %d, expect: %d", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestExitStatus_commands(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		os.MkdirAll(filepath.Join(tmproot, "github.com/motemen/ghq", ".git"), 0755)
+
+		testCases := []struct {
+			name   string
+			args   []string
+			expect int
+		}{
+			{"unknown flag", []string{"list", "--unknown-flag"}, exitStatusUsage},
+			{"invalid flags", []string{"get", "--prune", "motemen/ghq"}, exitStatusUsage},
+			{"no argument", []string{"look"}, exitStatusUsage},
+			{"not found", []string{"rm", "--dry-run", "unknown-repo"}, exitStatusNotFound},
+			{"success", []string{"rm", "--dry-run", "motemen/ghq"}, exitStatusOK},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				var err error
+				capture(func() {
+					err = newApp().Run(append([]string{"ghq"}, tc.args...))
+				})
+				if got := exitStatus(err); got != tc.expect {
+					t.Errorf("got: %d, expect: %d (%v)", got, tc.expect, err)
+				}
+			})
+		}
+	})
+}
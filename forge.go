@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Songmu/gitconfig"
+	"github.com/x-motemen/ghq/logger"
+)
+
+// githubAPIBase is the endpoint of GitHub REST API
+var githubAPIBase = "https://api.github.com"
+
+// githubToken returns the token for GitHub API from $GITHUB_TOKEN, $GH_TOKEN
+// or "github.token" in gitconfig.
+func githubToken() (string, error) {
+	for _, env := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+		if token := os.Getenv(env); token != "" {
+			return token, nil
+		}
+	}
+	token, err := gitconfig.Get("github.token")
+	if err != nil && !gitconfig.IsNotFound(err) {
+		return "", err
+	}
+	return token, nil
+}
+
+// ghAuthToken returns the token of GitHub CLI. It is a variable to be
+// replaced in the tests.
+var ghAuthToken = func() (string, error) {
+	out, err := exec.Command("gh", "auth", "token").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+var (
+	_githubCloneToken    string
+	_githubCloneTokenErr error
+	githubCloneTokenOnce = &sync.Once{}
+)
+
+// githubCloneToken returns the token to clone the repositories on GitHub over
+// HTTPS. It is "ghq.github.com.token" in gitconfig, or the one for GitHub API
+// (see githubToken) or of `gh auth token` if "ghq.github.com.useToken" is true.
+// It is empty unless configured, not to send a token by surprise.
+func githubCloneToken() (string, error) {
+	githubCloneTokenOnce.Do(func() {
+		_githubCloneToken, _githubCloneTokenErr = func() (string, error) {
+			token, err := gitconfig.Get("ghq.github.com.token")
+			if err != nil && !gitconfig.IsNotFound(err) {
+				return "", err
+			}
+			if token != "" {
+				return token, nil
+			}
+			use, err := gitconfig.Bool("ghq.github.com.useToken")
+			if err != nil && !gitconfig.IsNotFound(err) {
+				return "", err
+			}
+			if !use {
+				return "", nil
+			}
+			if token, err = githubToken(); err != nil || token != "" {
+				return token, err
+			}
+			if token, err = ghAuthToken(); err != nil {
+				return "", fmt.Errorf("no token found for ghq.github.com.useToken: %w", err)
+			}
+			return token, nil
+		}()
+	})
+	return _githubCloneToken, _githubCloneTokenErr
+}
+
+// githubAuthEnv returns the environment variables to send the token of
+// githubCloneToken as an HTTP header for the HTTPS URL of GitHub. The header is
+// scoped to https://github.com/, so that it is not sent to other hosts, e.g.
+// of the submodules, and the token is not saved in the remote URL. It is not
+// put into the command line either, which other users can read. The token is
+// registered to the logger to be masked in the errors.
+func githubAuthEnv(u *url.URL) ([]string, error) {
+	if u.Scheme != "https" || u.Hostname() != "github.com" || u.User != nil {
+		return nil, nil
+	}
+	token, err := githubCloneToken()
+	if err != nil || token == "" {
+		return nil, err
+	}
+	cred := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + token))
+	logger.AddSecret(token)
+	logger.AddSecret(cred)
+	return gitConfigEnv("http.https://github.com/.extraHeader", "Authorization: Basic "+cred), nil
+}
+
+// gitConfigEnv returns the environment variables to give the configuration to
+// git (2.31 or later) following the ones given by the environment already.
+func gitConfigEnv(key, value string) []string {
+	n, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	return []string{
+		fmt.Sprintf("GIT_CONFIG_COUNT=%d", n+1),
+		fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", n, key),
+		fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", n, value),
+	}
+}
+
+// detectDefaultBranch asks the forge API for the default branch of the
+// repository. Only GitHub with a token is supported for now, and an error is
+// returned for other repositories so that the caller falls back to a normal
+// clone.
+func detectDefaultBranch(ctx context.Context, hostname string, u *url.URL) (string, error) {
+	if hostname != "github.com" {
+		return "", fmt.Errorf("detecting the default branch is not supported for %s", hostname)
+	}
+	token, err := githubToken()
+	if err != nil {
+		return "", err
+	}
+	if token == "" {
+		return "", fmt.Errorf("no token for GitHub API")
+	}
+	pathComponents := strings.Split(strings.Trim(strings.TrimSuffix(u.Path, ".git"), "/"), "/")
+	if len(pathComponents) < 2 {
+		return "", fmt.Errorf("unexpected path of GitHub repository: %s", u.Path)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	endpoint := fmt.Sprintf("%s/repos/%s/%s", githubAPIBase, pathComponents[0], pathComponents[1])
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("User-Agent", fmt.Sprintf("ghq/%s (+https://github.com/motemen/ghq)", version))
+	req.Header.Add("Accept", "application/vnd.github.v3+json")
+	req.Header.Add("Authorization", "token "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected response from %s: %s", endpoint, resp.Status)
+	}
+	return parseDefaultBranch(resp.Body)
+}
+
+func parseDefaultBranch(r io.Reader) (string, error) {
+	var repo struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := json.NewDecoder(r).Decode(&repo); err != nil {
+		return "", err
+	}
+	if repo.DefaultBranch == "" {
+		return "", fmt.Errorf("no default branch in the response")
+	}
+	return repo.DefaultBranch, nil
+}
@@ -1,14 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/Songmu/gitconfig"
 	"github.com/x-motemen/ghq/logger"
 )
 
@@ -26,12 +34,71 @@ func getRepoLock(localRepoRoot string) bool {
 	return !seen[localRepoRoot]
 }
 
+// the values of --on-exists
+const (
+	onExistsSkip    = "skip"
+	onExistsUpdate  = "update"
+	onExistsReplace = "replace"
+	onExistsError   = "error"
+)
+
 type getter struct {
-	update, shallow, silent, ssh, recursive bool
-	vcs, branch                             string
+	shallow, silent, ssh, recursive       bool
+	lfs, strict, dryRun, verifyClean      bool
+	prune, mirror, dissociate             bool
+	gc, gcAggressive, noGC                bool
+	noHardlinks, autoDeepen, insecure     bool
+	unshallow                             bool
+	alsoFilterSubmodules                  bool
+	pr, submoduleJobs, retry, depth       int
+	vcs, branch, mirrorTo, username, root string
+	hooksDir, bundle, reference           string
+	ref, preferRef                        string
+	onExists, remoteNameTemplate          string
+	lfsInclude, lfsExclude                string
+	setRemotes                            []remoteSetting
+	urlRewrites                           []urlRewrite
+	env, sparse                           []string
+	timeout, timeoutPerRepo               time.Duration
+	skipIfFetchedWithin                   time.Duration
+	limiter                               *concurrencyLimiter
+	progress                              *repoProgress
+	// w is where the preview of --dry-run is printed
+	w io.Writer
+}
+
+// logAction logs what is done to the repository, or records it for the
+// progress line in place of logging when --silent is specified.
+func (g *getter) logAction(action, message string) {
+	if g.progress != nil {
+		g.progress.action = action
+		return
+	}
+	logger.Log(action, message)
 }
 
-func (g *getter) get(argURL string) error {
+// splitVCSPrefix splits the VCS given before the URL, e.g. "mytool" of
+// "mytool::https://example.com/repo". The names not in vcsRegistry are not
+// regarded as the VCS, and neither is "codecommit::" of the CodeCommit URLs.
+func splitVCSPrefix(ref string) (string, string) {
+	i := strings.Index(ref, "::")
+	if i <= 0 || codecommitLikeURLPattern.MatchString(ref) {
+		return "", ref
+	}
+	if _, ok := vcsRegistry[ref[:i]]; !ok {
+		return "", ref
+	}
+	return ref[:i], ref[i+len("::"):]
+}
+
+func (g *getter) get(ctx context.Context, argURL string) error {
+	vcs, argURL := splitVCSPrefix(argURL)
+	if vcs != "" {
+		// the VCS of the argument takes precedence over --vcs
+		rg := *g
+		rg.vcs = vcs
+		g = &rg
+	}
 	u, err := newURL(argURL, g.ssh, false)
 	if err != nil {
 		return fmt.Errorf("Could not parse URL %q: %w", argURL, err)
@@ -42,15 +109,91 @@ func (g *getter) get(argURL string) error {
 		return err
 	}
 
-	return g.getRemoteRepository(remote)
+	// the repositories left when the whole batch timed out are not started
+	if err := ctx.Err(); err == context.DeadlineExceeded {
+		return &timeoutError{timeout: g.timeout, err: err}
+	}
+	batchCtx := ctx
+	if g.timeoutPerRepo > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.timeoutPerRepo)
+		defer cancel()
+	}
+	err = g.getRemoteRepository(ctx, remote)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		timeout := g.timeoutPerRepo
+		if batchCtx.Err() == context.DeadlineExceeded {
+			timeout = g.timeout
+		}
+		return &timeoutError{timeout: timeout, err: err}
+	}
+	return err
+}
+
+// targetPath returns the path where the repository would be cloned without
+// looking for existing clones.
+func (g *getter) targetPath(argURL string) (string, error) {
+	_, argURL = splitVCSPrefix(argURL)
+	u, err := newURL(argURL, g.ssh, false)
+	if err != nil {
+		return "", fmt.Errorf("Could not parse URL %q: %w", argURL, err)
+	}
+	root := g.root
+	if root != "" {
+		if root, err = findLocalRepositoryRoot(root); err != nil {
+			return "", err
+		}
+	}
+	local, err := newLocalRepositoryFromURL(u, root)
+	if err != nil {
+		return "", err
+	}
+	return local.FullPath, nil
+}
+
+// localRepository returns the local repository of the argument, which is
+// resolved in the same way as getting it whether it is cloned or not.
+func (g *getter) localRepository(argURL string) (*LocalRepository, error) {
+	_, argURL = splitVCSPrefix(argURL)
+	u, err := newURL(argURL, g.ssh, false)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse URL %q: %w", argURL, err)
+	}
+	remote, err := NewRemoteRepository(u)
+	if err != nil {
+		return nil, err
+	}
+	return localRepositoryFromURL(remote.URL(), g.root, g.strict)
+}
+
+// timeoutError is returned when getting a repository exceeds its deadline.
+type timeoutError struct {
+	timeout time.Duration
+	err     error
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s: %s", e.timeout, e.err)
+}
+
+func (e *timeoutError) Unwrap() error {
+	return e.err
 }
 
 // getRemoteRepository clones or updates a remote repository remote.
-// If doUpdate is true, updates the locally cloned repository. Otherwise does nothing.
-// If isShallow is true, does shallow cloning. (no effect if already cloned or the VCS is Mercurial and git-svn)
-func (g *getter) getRemoteRepository(remote RemoteRepository) error {
+// If the repository is cloned already, it is skipped, updated, replaced with
+// a fresh clone or treated as an error according to g.onExists.
+// If g.shallow is true, does shallow cloning. (no effect if already cloned.
+// The VCSs not supporting it warns and clones the full history, or fails with g.strict)
+func (g *getter) getRemoteRepository(ctx context.Context, remote RemoteRepository) error {
 	remoteURL := remote.URL()
-	local, err := LocalRepositoryFromURL(remoteURL)
+	if g.pr > 0 {
+		// fail fast before cloning for the unsupported forges
+		if _, err := pullRequestRefspec(localHostname(remoteURL), g.pr); err != nil {
+			return err
+		}
+	}
+	local, err := localRepositoryFromURL(remoteURL, g.root, g.strict)
 	if err != nil {
 		return err
 	}
@@ -70,13 +213,27 @@ func (g *getter) getRemoteRepository(remote RemoteRepository) error {
 			return err
 		}
 	}
+	if !newPath {
+		switch g.onExists {
+		case onExistsReplace:
+			g.logAction("replace", fpath)
+			if !g.dryRun {
+				if err := os.RemoveAll(fpath); err != nil {
+					return err
+				}
+			}
+			newPath = true
+		case onExistsError:
+			return fmt.Errorf("%s already exists", fpath)
+		}
+	}
 
 	switch {
 	case newPath:
 		if remoteURL.Scheme == "codecommit" {
-			logger.Log("clone", fmt.Sprintf("%s -> %s", remoteURL.Opaque, fpath))
+			g.logAction("clone", fmt.Sprintf("%s -> %s", remoteURL.Opaque, fpath))
 		} else {
-			logger.Log("clone", fmt.Sprintf("%s -> %s", remoteURL, fpath))
+			g.logAction("clone", fmt.Sprintf("%s -> %s", remoteURL, fpath))
 		}
 		var (
 			localRepoRoot = fpath
@@ -84,45 +241,835 @@ func (g *getter) getRemoteRepository(remote RemoteRepository) error {
 		)
 		vcs, ok := vcsRegistry[g.vcs]
 		if !ok {
-			vcs, repoURL, err = remote.VCS()
-			if err != nil {
+			if g.bundle != "" {
+				// the remote may be unreachable while provisioning offline
+				vcs = GitBackend
+			} else if vcs, repoURL, err = remote.VCS(); err != nil {
 				return err
 			}
 		}
+		if opts := g.gitOnlyOptions(); len(opts) > 0 && vcs != GitBackend {
+			return fmt.Errorf("%s only supported for git repositories", strings.Join(opts, ", "))
+		}
+		if g.shallow && !vcs.SupportsShallow {
+			name := vcsName(vcs)
+			if g.strict {
+				return fmt.Errorf("--shallow is not supported for %s", name)
+			}
+			logger.Log("warning", fmt.Sprintf("--shallow is not supported for %s, so the full history is cloned", name))
+		} else if g.depth > 1 && vcs.SupportsShallow && !vcs.SupportsDepth {
+			name := vcsName(vcs)
+			if g.strict {
+				return fmt.Errorf("--depth is not supported for %s", name)
+			}
+			logger.Log("warning", fmt.Sprintf("--depth is not supported for %s, so it is cloned as --shallow", name))
+		}
+		if g.alsoFilterSubmodules {
+			if err := checkGitVersion(2, 36, "--also-filter-submodules"); err != nil {
+				return err
+			}
+		}
+		if len(g.sparse) > 0 {
+			if err := checkGitVersion(2, 25, "--sparse"); err != nil {
+				return err
+			}
+		}
+		if g.submoduleJobs > 0 {
+			if err := checkGitVersion(2, 9, "--submodule-parallel"); err != nil {
+				return err
+			}
+		}
+		if g.noHardlinks && !isLocalRemote(repoURL) {
+			return fmt.Errorf("--no-hardlinks is only available for cloning from a local repository")
+		}
+		if g.username != "" && vcs != SubversionBackend && vcs != GitsvnBackend {
+			return fmt.Errorf("--username is only supported for Subversion and git-svn. " +
+				"Include the user name in the repository URL for other VCSs")
+		}
 		if l := detectLocalRepoRoot(remoteURL.Path, repoURL.Path); l != "" {
-			localRepoRoot = filepath.Join(local.RootPath, remoteURL.Hostname(), l)
+			u := *remoteURL
+			u.Path = l
+			layout, err := getRootLayout()
+			if err != nil {
+				return err
+			}
+			rel, err := layout.relPath(&u)
+			if err != nil {
+				return err
+			}
+			localRepoRoot = filepath.Join(local.RootPath, rel)
 		}
 
-		if remoteURL.Scheme == "codecommit" {
-			repoURL, _ = url.Parse(remoteURL.Opaque)
+		if repoURL, err = g.rewriteRepoURL(remoteURL, repoURL); err != nil {
+			return err
+		}
+		if g.dryRun {
+			vg, err := g.cloneOption(ctx, vcs, remoteURL, repoURL, localRepoRoot)
+			if err != nil {
+				return err
+			}
+			return g.previewClone(vcs, vg)
+		}
+		if getRepoLock(localRepoRoot) {
+			defer g.limiter.acquire(vcs)()
+			vg, err := g.cloneOption(ctx, vcs, remoteURL, repoURL, localRepoRoot)
+			if err != nil {
+				return err
+			}
+			existing := existingCloneDirs(localRepoRoot)
+			if g.bundle != "" {
+				err = g.cloneBundle(vg)
+			} else {
+				err = g.clone(vcs, vg, existing)
+			}
+			if err != nil {
+				// a broken clone, e.g. by a network drop or killed at the
+				// deadline, would make the next get fail as cloned already
+				removePartialClone(vg.dir, existing)
+				return err
+			}
+			if err := g.afterClone(vcs, vg); err != nil {
+				return err
+			}
+			if err := g.fetchPullRequest(vcs, vg, remoteURL); err != nil {
+				return err
+			}
+			return runAfterHook("get", remoteURL, vg)
+		}
+		return nil
+	case g.onExists == onExistsUpdate:
+		vcs, localRepoRoot := local.VCS()
+		if vcs == GitBackend && g.skipIfFetchedWithin > 0 {
+			if fetched, ok := gitLastFetched(localRepoRoot); ok && time.Since(fetched) < g.skipIfFetchedWithin {
+				g.logAction("skip", fmt.Sprintf("%s (fetched %s ago)", fpath, time.Since(fetched).Round(time.Second)))
+				return nil
+			}
+		}
+		g.logAction("update", fpath)
+		if g.dryRun {
+			return nil
+		}
+		if vcs == nil {
+			return fmt.Errorf("failed to detect VCS for %q", fpath)
+		}
+		strategy, err := updateStrategy(remoteURL)
+		if err != nil {
+			return err
+		}
+		if g.prune && vcs != GitBackend {
+			return fmt.Errorf("--prune only supported for git repositories")
+		}
+		if g.submoduleJobs > 0 && vcs == GitBackend {
+			if err := checkGitVersion(2, 9, "--submodule-parallel"); err != nil {
+				return err
+			}
 		}
 		if getRepoLock(localRepoRoot) {
-			return vcs.Clone(&vcsGetOption{
-				url:       repoURL,
+			defer g.limiter.acquire(vcs)()
+			vg := &vcsGetOption{
+				ctx:       ctx,
 				dir:       localRepoRoot,
-				shallow:   g.shallow,
 				silent:    g.silent,
-				branch:    g.branch,
 				recursive: g.recursive,
-			})
+				gitConfig: g.gitConfig(),
+				env:       g.env,
+
+				autoDeepen: g.autoDeepen,
+				unshallow:  g.unshallow,
+				insecure:   g.insecure,
+				prune:      g.prune,
+
+				updateStrategy: strategy,
+				submoduleJobs:  g.submoduleJobs,
+			}
+			if vcs == GitBackend {
+				// the same URL as cloning decides whether to send the token
+				repoURL, err := g.rewriteRepoURL(remoteURL, remoteURL)
+				if err != nil {
+					return err
+				}
+				if err := addGitHubAuth(vg, repoURL); err != nil {
+					return err
+				}
+			}
+			if err := vcs.Update(vg); err != nil {
+				return err
+			}
+			if err := g.setHooksPath(vcs, vg); err != nil {
+				return err
+			}
+			if err := g.runGC(vcs, vg); err != nil {
+				return err
+			}
+			if err := g.fetchPullRequest(vcs, vg, remoteURL); err != nil {
+				return err
+			}
+			return runAfterHook("update", remoteURL, vg)
 		}
 		return nil
-	case g.update:
-		logger.Log("update", fpath)
+	}
+	g.logAction("exists", fpath)
+	if g.pr > 0 && !g.dryRun {
 		vcs, localRepoRoot := local.VCS()
 		if vcs == nil {
 			return fmt.Errorf("failed to detect VCS for %q", fpath)
 		}
 		if getRepoLock(localRepoRoot) {
-			return vcs.Update(&vcsGetOption{
+			vg := &vcsGetOption{
+				ctx:       ctx,
 				dir:       localRepoRoot,
 				silent:    g.silent,
-				recursive: g.recursive,
-			})
+				gitConfig: g.gitConfig(),
+				env:       g.env,
+			}
+			return g.fetchPullRequest(vcs, vg, remoteURL)
+		}
+	}
+	return nil
+}
+
+// gitLastFetched returns the last time the git repository in the directory was
+// fetched, i.e. the modification time of FETCH_HEAD. ok is false if it has
+// never been fetched since cloned.
+func gitLastFetched(dir string) (fetched time.Time, ok bool) {
+	for _, p := range []string{
+		filepath.Join(dir, ".git", "FETCH_HEAD"),
+		filepath.Join(dir, "FETCH_HEAD"), // bare repositories
+	} {
+		if fi, err := os.Stat(p); err == nil {
+			return fi.ModTime(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// existingCloneDirs returns the directories which exist before cloning into
+// dir, not to remove them on failures: dir itself, the base of the trunk or
+// the branch which Subversion and git-svn clone into, and the nearest
+// ancestor, above which no directories are created by the clone.
+func existingCloneDirs(dir string) map[string]bool {
+	existing := map[string]bool{}
+	for _, d := range []string{dir, svnBase(dir)} {
+		if _, err := os.Stat(d); err == nil {
+			existing[d] = true
+		}
+	}
+	for d := filepath.Dir(dir); ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil || d == filepath.Dir(d) {
+			existing[d] = true
+			break
 		}
+	}
+	return existing
+}
+
+// removePartialClone removes the directory left by the failed clone and the
+// empty parents created for it. The directories in existing are kept.
+func removePartialClone(dir string, existing map[string]bool) {
+	if existing[dir] {
+		return
+	}
+	if _, err := os.Stat(dir); err == nil {
+		if err := os.RemoveAll(dir); err != nil {
+			logger.Log("warning", fmt.Sprintf("failed to remove the partial clone %s: %s", dir, err))
+			return
+		}
+		logger.Log("clean", fmt.Sprintf("removed the partial clone %s", dir))
+	}
+	for d := filepath.Dir(dir); !existing[d]; d = filepath.Dir(d) {
+		// os.Remove fails for a non-empty directory, e.g. of another clone
+		if err := os.Remove(d); err != nil || d == filepath.Dir(d) {
+			return
+		}
+	}
+}
+
+// cloneOption builds the option to clone the repository from repoURL, which
+// may be rewritten from remoteURL, to dir.
+func (g *getter) cloneOption(ctx context.Context, vcs *VCSBackend, remoteURL, repoURL *url.URL, dir string) (*vcsGetOption, error) {
+	vg := &vcsGetOption{
+		ctx:       ctx,
+		url:       repoURL,
+		dir:       dir,
+		shallow:   g.shallow,
+		depth:     g.depth,
+		silent:    g.silent,
+		branch:    g.branch,
+		username:  g.username,
+		recursive: g.recursive && !g.mirror,
+		gitConfig: g.gitConfig(),
+		env:       g.env,
+
+		noHardlinks: g.noHardlinks,
+		mirror:      g.mirror,
+		insecure:    g.insecure,
+		reference:   g.reference,
+		dissociate:  g.dissociate,
+
+		alsoFilterSubmodules: g.alsoFilterSubmodules,
+		sparse:               g.sparse,
+
+		ref:           g.ref,
+		preferRef:     g.preferRef,
+		submoduleJobs: g.submoduleJobs,
+	}
+	if g.needsDefaultBranch(vcs) {
+		// falls back to a normal clone if the API is unavailable
+		if b, err := detectDefaultBranch(ctx, localHostname(remoteURL), repoURL); err == nil {
+			vg.defaultBranch = b
+		}
+	}
+	if vcs == GitBackend && g.remoteNameTemplate != "" {
+		var err error
+		if vg.origin, err = renderRemoteName(g.remoteNameTemplate, remoteURL); err != nil {
+			return nil, err
+		}
+	}
+	if vcs == GitBackend {
+		if err := addGitHubAuth(vg, repoURL); err != nil {
+			return nil, err
+		}
+	}
+	return vg, nil
+}
+
+// rewriteRepoURL returns the URL to get the repository from, which is repoURL
+// detected for remoteURL rewritten by ghq.rewriteURL.
+func (g *getter) rewriteRepoURL(remoteURL, repoURL *url.URL) (*url.URL, error) {
+	if remoteURL.Scheme == "codecommit" {
+		return url.Parse(remoteURL.Opaque)
+	}
+	if len(g.urlRewrites) == 0 {
+		return repoURL, nil
+	}
+	rewritten, err := rewriteURL(repoURL, g.urlRewrites)
+	if err != nil {
+		return nil, err
+	}
+	if rewritten.String() != repoURL.String() {
+		logger.Log("resolved", fmt.Sprintf("%s to %s by ghq.rewriteURL", repoURL, rewritten))
+	}
+	return rewritten, nil
+}
+
+// addGitHubAuth adds the token of GitHub for the URL to the environment of
+// the git commands
+func addGitHubAuth(vg *vcsGetOption, u *url.URL) error {
+	env, err := githubAuthEnv(u)
+	if err != nil || len(env) == 0 {
+		return err
+	}
+	// the environment of the getter is shared by the repositories
+	vg.env = append(append([]string{}, vg.env...), env...)
+	return nil
+}
+
+// needsDefaultBranch reports whether the default branch should be asked to
+// the forge API for the clone. It is not when another branch is checked out
+// after all, or the clone is not run for --dry-run.
+func (g *getter) needsDefaultBranch(vcs *VCSBackend) bool {
+	return vcs == GitBackend && !g.dryRun && !g.mirror &&
+		g.branch == "" && g.ref == "" && g.bundle == "" && g.pr == 0
+}
+
+// previewClone prints the URL, the path, the VCS and the command lines of the
+// clone for --dry-run, which are recorded by the backend instead of running
+// them. The lines are printed at once not to be mixed in parallel.
+func (g *getter) previewClone(vcs *VCSBackend, vg *vcsGetOption) error {
+	vg.commands = &[]string{}
+	var err error
+	if g.bundle != "" {
+		err = g.cloneBundle(vg)
+	} else {
+		err = vcs.Clone(vg)
+	}
+	if err != nil {
+		return err
+	}
+	name := vcsName(vcs)
+	if name == "" {
+		name = "unknown"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "url: %s\npath: %s\nvcs: %s\n", logger.Redact(vg.url.String()), vg.dir, name)
+	for _, c := range *vg.commands {
+		fmt.Fprintf(&b, "command: %s\n", c)
+	}
+	fmt.Fprint(g.w, b.String())
+	return nil
+}
+
+// retryBackoff is the wait before the first retry of cloning, which is doubled
+// for each retry. It is a variable to be replaced in the tests.
+var retryBackoff = time.Second
+
+// clone clones the repository, retrying up to g.retry times with exponential
+// backoff when the VCS command fails, e.g. by transient network errors. The
+// directory left by the failed attempt is removed before retrying unless it is
+// in existing, in which case it is not retried.
+func (g *getter) clone(vcs *VCSBackend, vg *vcsGetOption, existing map[string]bool) error {
+	backoff := retryBackoff
+	for i := 0; ; i++ {
+		err := vcs.Clone(vg)
+		var exitErr *exec.ExitError
+		if err == nil || i >= g.retry || !errors.As(err, &exitErr) || existing[vg.dir] {
+			return err
+		}
+		if !vg.silent {
+			logger.Log("retry", fmt.Sprintf("%s in %s (%d/%d): %s", vg.url, backoff, i+1, g.retry, err))
+		}
+		if err := os.RemoveAll(vg.dir); err != nil {
+			return err
+		}
+		ctx := vg.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// cloneBundle clones the git repository from the bundle file, and then sets
+// the URL of the remote to the real one, so that the repository can be
+// updated online later.
+func (g *getter) cloneBundle(vg *vcsGetOption) error {
+	// list-heads checks the header of the bundle unlike verify needing a repository
+	if err := vg.runInDirSilently("", "git", "bundle", "list-heads", g.bundle); err != nil {
+		return fmt.Errorf("invalid bundle %s: %w", g.bundle, err)
+	}
+	bvg := *vg
+	bvg.url = &url.URL{Path: g.bundle}
+	if err := GitBackend.Clone(&bvg); err != nil {
+		return err
+	}
+	origin := vg.origin
+	if origin == "" {
+		origin = "origin"
+	}
+	return vg.runInDirSilently(vg.dir, "git", "remote", "set-url", origin, vg.url.String())
+}
+
+// updateStrategy returns the strategy to update the repository of the URL.
+// "ghq.<url>.updateStrategy" overrides "ghq.update.strategy" for the matched
+// URLs, e.g. corporate mirrors.
+func updateStrategy(u *url.URL) (string, error) {
+	strategy, err := gitconfig.Do("--get-urlmatch", "ghq.updateStrategy", u.String())
+	if err != nil && !gitconfig.IsNotFound(err) {
+		return "", err
+	}
+	if strategy == "" {
+		strategy, err = gitconfig.Get("ghq.update.strategy")
+		if err != nil && !gitconfig.IsNotFound(err) {
+			return "", err
+		}
+	}
+	switch strategy {
+	case "":
+		return updateStrategyFFOnly, nil
+	case updateStrategyFFOnly, updateStrategyRebase, updateStrategyMerge:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("invalid update strategy %q: ff-only, rebase or merge is available", strategy)
+}
+
+const defaultConcurrency = 6
+
+// concurrencyLimiter limits the number of concurrent clones and updates per
+// VCS backend in parallel mode. The limits can be configured for each backend
+// by "ghq.concurrency.<vcs>" in gitconfig.
+type concurrencyLimiter struct {
+	mu     sync.Mutex
+	limits map[*VCSBackend]int
+	sems   map[*VCSBackend]chan struct{}
+}
+
+func newConcurrencyLimiter() (*concurrencyLimiter, error) {
+	l := &concurrencyLimiter{
+		limits: map[*VCSBackend]int{},
+		sems:   map[*VCSBackend]chan struct{}{},
+	}
+	out, err := gitconfig.Do("--int", "--get-regexp", `^ghq\.concurrency\.`)
+	if err != nil {
+		if gitconfig.IsNotFound(err) {
+			return l, nil
+		}
+		return nil, err
+	}
+	for _, kvStr := range strings.Split(out, "\x00") {
+		kv := strings.SplitN(kvStr, "\n", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		vcs := strings.TrimPrefix(kv[0], "ghq.concurrency.")
+		backend, ok := vcsRegistry[vcs]
+		if !ok {
+			return nil, fmt.Errorf("unknown vcs in %s", kv[0])
+		}
+		n, err := strconv.Atoi(kv[1])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid value of %s: %s", kv[0], kv[1])
+		}
+		l.limits[backend] = n
+	}
+	return l, nil
+}
+
+// max returns the largest limit among the backends, which bounds the number
+// of repositories processed at once.
+func (l *concurrencyLimiter) max() int {
+	max := defaultConcurrency
+	for _, n := range l.limits {
+		if n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+// acquire blocks until the backend is available and returns the function to
+// release it. It does nothing for the nil limiter.
+func (l *concurrencyLimiter) acquire(vcs *VCSBackend) func() {
+	if l == nil {
+		return func() {}
+	}
+	l.mu.Lock()
+	sem, ok := l.sems[vcs]
+	if !ok {
+		n := l.limits[vcs]
+		if n == 0 {
+			n = defaultConcurrency
+		}
+		sem = make(chan struct{}, n)
+		l.sems[vcs] = sem
+	}
+	l.mu.Unlock()
+
+	sem <- struct{}{}
+	return func() { <-sem }
+}
+
+// gitOnlyOptions returns the specified options which are available only for git
+func (g *getter) gitOnlyOptions() []string {
+	var opts []string
+	if g.mirrorTo != "" {
+		opts = append(opts, "--mirror-to")
+	}
+	if g.lfsInclude != "" {
+		opts = append(opts, "--lfs-include")
+	}
+	if g.lfsExclude != "" {
+		opts = append(opts, "--lfs-exclude")
+	}
+	if g.noHardlinks {
+		opts = append(opts, "--no-hardlinks")
+	}
+	if len(g.setRemotes) > 0 {
+		opts = append(opts, "--set-remote")
+	}
+	if g.alsoFilterSubmodules {
+		opts = append(opts, "--also-filter-submodules")
+	}
+	if g.pr > 0 {
+		opts = append(opts, "--pr")
+	}
+	if g.ref != "" {
+		opts = append(opts, "--ref")
+	}
+	if g.submoduleJobs > 0 {
+		opts = append(opts, "--submodule-parallel")
+	}
+	if g.bundle != "" {
+		opts = append(opts, "--bundle")
+	}
+	if len(g.sparse) > 0 {
+		opts = append(opts, "--sparse")
+	}
+	if g.mirror {
+		opts = append(opts, "--mirror")
+	}
+	if g.reference != "" {
+		opts = append(opts, "--reference")
+	}
+	return opts
+}
+
+// gitConfig returns the git configurations scoped to the git subprocesses
+func (g *getter) gitConfig() []string {
+	var conf []string
+	if g.lfsInclude != "" {
+		conf = append(conf, "lfs.fetchinclude="+g.lfsInclude)
+	}
+	if g.lfsExclude != "" {
+		conf = append(conf, "lfs.fetchexclude="+g.lfsExclude)
+	}
+	if g.noGC {
+		conf = append(conf, "gc.auto=0")
+	}
+	if g.insecure {
+		conf = append(conf, "http.sslVerify=false")
+	}
+	return conf
+}
+
+// afterClone runs the extra steps for a freshly cloned repository.
+func (g *getter) afterClone(vcs *VCSBackend, vg *vcsGetOption) error {
+	if vcs != GitBackend {
 		return nil
 	}
-	logger.Log("exists", fpath)
+	if usesGitLFS(vg.dir) {
+		if err := vg.runInDirSilently(vg.dir, "git", "lfs", "version"); err != nil {
+			logger.Log("warning", fmt.Sprintf("%s uses Git LFS but git-lfs is not installed, "+
+				"so the LFS files are checked out as pointer files. "+
+				"Install it from https://git-lfs.github.com/ and run `git lfs pull` in the repository", vg.dir))
+		} else if g.lfs {
+			if err := vg.runInDir(vg.dir, "git", vg.gitArgs("lfs", "pull")...); err != nil {
+				return err
+			}
+		}
+	}
+	for _, r := range g.setRemotes {
+		// set-url for an existing remote like origin, otherwise add it
+		subcmd := "add"
+		if err := vg.runInDirSilently(vg.dir, "git", "remote", "get-url", r.name); err == nil {
+			subcmd = "set-url"
+		}
+		if err := vg.runInDir(vg.dir, "git", "remote", subcmd, r.name, r.url); err != nil {
+			return err
+		}
+	}
+	if g.mirrorTo != "" {
+		if err := vg.runInDir(vg.dir, "git", "remote", "add", mirrorRemoteName, g.mirrorTo); err != nil {
+			return err
+		}
+		if err := vg.runInDir(vg.dir, "git", "push", "--mirror", mirrorRemoteName); err != nil {
+			return err
+		}
+	}
+	if err := g.setHooksPath(vcs, vg); err != nil {
+		return err
+	}
+	if err := g.verifyCleanAfterClone(vcs, vg); err != nil {
+		return err
+	}
+	return g.runGC(vcs, vg)
+}
+
+// verifyCleanAfterClone fails if the working tree of the git repository just
+// cloned has modified or untracked files, e.g. made by filters or hooks, when
+// --verify-clean-after is specified. Other VCSs and bare repositories are
+// skipped.
+func (g *getter) verifyCleanAfterClone(vcs *VCSBackend, vg *vcsGetOption) error {
+	if !g.verifyClean || vcs != GitBackend || isBareGitRepository(vg.dir) {
+		return nil
+	}
+	out, err := outputInDir(vg.dir, "git", "status", "--porcelain")
+	if err != nil {
+		return err
+	}
+	if out != "" {
+		return fmt.Errorf("%s is not clean after cloning:\n%s", vg.dir, out)
+	}
+	return nil
+}
+
+// setHooksPath points core.hooksPath of the git repository at the shared hooks
+// directory given by --hooks-dir or ghq.hooksPath. Other VCSs are left as they
+// are.
+func (g *getter) setHooksPath(vcs *VCSBackend, vg *vcsGetOption) error {
+	if vcs != GitBackend || g.hooksDir == "" {
+		return nil
+	}
+	return vg.runInDirSilently(vg.dir, "git", "config", "core.hooksPath", g.hooksDir)
+}
+
+// runGC runs "git gc" for the git repository if --gc or --gc-aggressive is
+// specified. Other VCSs are left as they are.
+func (g *getter) runGC(vcs *VCSBackend, vg *vcsGetOption) error {
+	if vcs != GitBackend || !(g.gc || g.gcAggressive) {
+		return nil
+	}
+	args := []string{"gc", "--auto"}
+	if g.gcAggressive {
+		args = []string{"gc", "--aggressive"}
+	}
+	if vg.silent {
+		args = append(args, "--quiet")
+	}
+	return vg.runInDir(vg.dir, "git", args...)
+}
+
+// fetchPullRequest fetches the pull request specified by --pr into the
+// "pr-<number>" branch and checks it out. The branch is reset to the fetched
+// head when it exists already, so that it follows the force-pushes.
+func (g *getter) fetchPullRequest(vcs *VCSBackend, vg *vcsGetOption, remoteURL *url.URL) error {
+	if g.pr <= 0 {
+		return nil
+	}
+	if vcs != GitBackend {
+		return fmt.Errorf("--pr only supported for git repositories")
+	}
+	refspec, err := pullRequestRefspec(localHostname(remoteURL), g.pr)
+	if err != nil {
+		return err
+	}
+	remoteName := "origin"
+	if g.remoteNameTemplate != "" {
+		if remoteName, err = renderRemoteName(g.remoteNameTemplate, remoteURL); err != nil {
+			return err
+		}
+	}
+	branch := fmt.Sprintf("pr-%d", g.pr)
+	logger.Log("pr", fmt.Sprintf("%s -> %s", refspec, branch))
+	if err := vg.runInDir(vg.dir, "git", vg.gitArgs("fetch", remoteName, refspec)...); err != nil {
+		return err
+	}
+	return vg.runInDir(vg.dir, "git", "checkout", "-B", branch, "FETCH_HEAD")
+}
+
+// afterHook returns the command run after getting the repository of the host
+// for the kind, "get" for fresh clones or "update". "ghq.<host>.<kind>.afterHook"
+// takes precedence over "ghq.<kind>.afterHook".
+func afterHook(kind, hostname string) (string, error) {
+	for _, key := range []string{
+		fmt.Sprintf("ghq.%s.%s.afterHook", hostname, kind),
+		fmt.Sprintf("ghq.%s.afterHook", kind),
+	} {
+		hook, err := gitconfig.Get(key)
+		if err != nil && !gitconfig.IsNotFound(err) {
+			return "", err
+		}
+		if hook != "" {
+			return hook, nil
+		}
+	}
+	return "", nil
+}
+
+// runAfterHook runs the hook command by the shell in the repository, e.g.
+// "go mod download", replacing "{path}" with the path of the repository. The
+// repository is kept even if the hook fails.
+func runAfterHook(kind string, remoteURL *url.URL, vg *vcsGetOption) error {
+	hook, err := afterHook(kind, localHostname(remoteURL))
+	if err != nil || hook == "" {
+		return err
+	}
+	hook = strings.Replace(hook, "{path}", vg.dir, -1)
+	logger.Log("hook", hook)
+	shell, flag := shellCommand()
+	if err := vg.runInDir(vg.dir, shell, flag, hook); err != nil {
+		return fmt.Errorf("ghq.%s.afterHook failed in %s: %w", kind, vg.dir, err)
+	}
+	return nil
+}
+
+// pullRequestRefspec returns the ref of the pull request on the forge of the
+// host. GitHub and GitLab (merge requests) are supported.
+func pullRequestRefspec(hostname string, number int) (string, error) {
+	switch {
+	case hostname == "github.com":
+		return fmt.Sprintf("pull/%d/head", number), nil
+	case hostname == "gitlab.com" || strings.HasPrefix(hostname, "gitlab."):
+		return fmt.Sprintf("merge-requests/%d/head", number), nil
+	}
+	return "", fmt.Errorf("--pr is not supported for %s: only GitHub and GitLab are supported", hostname)
+}
+
+// renderRemoteName renders the name of the remote from the template of
+// "ghq.clone.remoteNameTemplate". "{hostname}" is replaced with the host name
+// of the URL and "{host}" with the one without the top-level domain, e.g.
+// "github" for github.com.
+func renderRemoteName(tmpl string, u *url.URL) (string, error) {
+	hostname := localHostname(u)
+	host := hostname
+	if i := strings.LastIndex(host, "."); i > 0 {
+		host = host[:i]
+	}
+	name := strings.NewReplacer("{hostname}", hostname, "{host}", host).Replace(tmpl)
+	if name == "" || strings.ContainsAny(name, " \t{}:~^?*[\\") {
+		return "", fmt.Errorf("invalid remote name %q rendered from ghq.clone.remoteNameTemplate", name)
+	}
+	return name, nil
+}
+
+// mirrorRemoteName is the name of the remote added for --mirror-to
+const mirrorRemoteName = "mirror"
+
+// remoteSetting is a remote specified by --set-remote
+type remoteSetting struct {
+	name, url string
+}
+
+var remoteNameReg = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+// parseRemoteSettings parses the values of --set-remote in "<name>=<url>"
+func parseRemoteSettings(vals []string) ([]remoteSetting, error) {
+	var remotes []remoteSetting
+	for _, v := range vals {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid value of --set-remote %q: <name>=<url> is expected", v)
+		}
+		if !remoteNameReg.MatchString(kv[0]) || strings.HasSuffix(kv[0], ".lock") || strings.Contains(kv[0], "..") {
+			return nil, fmt.Errorf("invalid remote name %q", kv[0])
+		}
+		if err := validateRemoteURL(kv[1]); err != nil {
+			return nil, err
+		}
+		remotes = append(remotes, remoteSetting{name: kv[0], url: kv[1]})
+	}
+	return remotes, nil
+}
+
+// parseEnvSettings parses the values of --env in "KEY=VALUE"
+func parseEnvSettings(vals []string) ([]string, error) {
+	var env []string
+	for _, v := range vals {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || strings.ContainsAny(kv[0], " \t") {
+			return nil, fmt.Errorf("invalid value of --env %q: KEY=VALUE is expected", v)
+		}
+		env = append(env, v)
+	}
+	return env, nil
+}
+
+// isLocalRemote reports whether the URL points a repository in the local
+// filesystem
+func isLocalRemote(u *url.URL) bool {
+	return u.Scheme == "file" || (u.Scheme == "" && u.Host == "" && u.Opaque == "")
+}
+
+// gitReferenceRepository validates the repository given by --reference and
+// returns the absolute path of it. Either a working tree or a bare repository
+// is accepted.
+func gitReferenceRepository(p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return "", usageErrorf("invalid --reference: %w", err)
+	}
+	if !isGitDir(abs) && !isGitDir(filepath.Join(abs, ".git")) {
+		return "", usageErrorf("invalid --reference: %s is not a git repository", p)
+	}
+	return abs, nil
+}
+
+func validateRemoteURL(u string) error {
+	if !hasSchemePattern.MatchString(u) && !scpLikeURLPattern.MatchString(u) {
+		return fmt.Errorf("invalid remote URL %q: a URL with scheme or scp-like one is required", u)
+	}
+	if hasSchemePattern.MatchString(u) {
+		if _, err := url.Parse(u); err != nil {
+			return fmt.Errorf("invalid remote URL %q: %w", u, err)
+		}
+	}
 	return nil
 }
 
@@ -1,6 +1,27 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Songmu/gitconfig"
+	"github.com/x-motemen/ghq/cmdutil"
+	"github.com/x-motemen/ghq/logger"
+)
 
 func TestDetectLocalRepoRoot(t *testing.T) {
 	testCases := []struct {
@@ -67,3 +88,1076 @@ func TestDetectLocalRepoRoot(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateRemoteURL(t *testing.T) {
+	testCases := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://example.com/backup/repo.git", true},
+		{"ssh://git@example.com/backup/repo.git", true},
+		{"git@example.com:backup/repo.git", true},
+		{"backup/repo", false},
+		{"repo", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			err := validateRemoteURL(tc.url)
+			if tc.valid && err != nil {
+				t.Errorf("error should be nil but: %s", err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("error should not be nil")
+			}
+		})
+	}
+}
+
+func TestNewConcurrencyLimiter(t *testing.T) {
+	defer gitconfig.WithConfig(t, `
+[ghq "concurrency"]
+  svn = 10
+  hg = 2
+`)()
+	l, err := newConcurrencyLimiter()
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if l.limits[SubversionBackend] != 10 {
+		t.Errorf("limit of svn: got: %d, expect: 10", l.limits[SubversionBackend])
+	}
+	if l.limits[MercurialBackend] != 2 {
+		t.Errorf("limit of hg: got: %d, expect: 2", l.limits[MercurialBackend])
+	}
+	if l.max() != 10 {
+		t.Errorf("max: got: %d, expect: 10", l.max())
+	}
+	release := l.acquire(GitBackend)
+	if len(l.sems[GitBackend]) != 1 || cap(l.sems[GitBackend]) != defaultConcurrency {
+		t.Errorf("semaphore of git should be acquired with the default capacity")
+	}
+	release()
+}
+
+func TestGetter_runGC(t *testing.T) {
+	var _commands [][]string
+	defer func(orig func(cmd *exec.Cmd) error) {
+		cmdutil.CommandRunner = orig
+	}(cmdutil.CommandRunner)
+	cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+		_commands = append(_commands, cmd.Args)
+		return nil
+	}
+
+	testCases := []struct {
+		name   string
+		g      *getter
+		vcs    *VCSBackend
+		silent bool
+		expect [][]string
+	}{{
+		name:   "no gc",
+		g:      &getter{},
+		vcs:    GitBackend,
+		expect: nil,
+	}, {
+		name:   "gc",
+		g:      &getter{gc: true},
+		vcs:    GitBackend,
+		expect: [][]string{{"git", "gc", "--auto"}},
+	}, {
+		name:   "gc aggressive silently",
+		g:      &getter{gc: true, gcAggressive: true},
+		vcs:    GitBackend,
+		silent: true,
+		expect: [][]string{{"git", "gc", "--aggressive", "--quiet"}},
+	}, {
+		name:   "not git",
+		g:      &getter{gc: true},
+		vcs:    MercurialBackend,
+		expect: nil,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_commands = nil
+			if err := tc.g.runGC(tc.vcs, &vcsGetOption{dir: "repo", silent: tc.silent}); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(_commands, tc.expect) {
+				t.Errorf("got: %v, expect: %v", _commands, tc.expect)
+			}
+		})
+	}
+}
+
+func TestGetter_gitConfig(t *testing.T) {
+	g := &getter{noGC: true, insecure: true, lfsInclude: "images/**"}
+	expect := []string{"lfs.fetchinclude=images/**", "gc.auto=0", "http.sslVerify=false"}
+	if got := g.gitConfig(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("got: %v, expect: %v", got, expect)
+	}
+}
+
+func TestRenderRemoteName(t *testing.T) {
+	testCases := []struct {
+		tmpl, url, expect string
+		wantErr           bool
+	}{{
+		tmpl:   "{host}",
+		url:    "https://github.com/motemen/ghq",
+		expect: "github",
+	}, {
+		tmpl:   "{hostname}",
+		url:    "https://github.com/motemen/ghq",
+		expect: "github.com",
+	}, {
+		tmpl:   "{host}-upstream",
+		url:    "https://git.example.com:8080/motemen/ghq",
+		expect: "git.example-upstream",
+	}, {
+		tmpl:   "{host}",
+		url:    "https://localhost/motemen/ghq",
+		expect: "localhost",
+	}, {
+		tmpl:    "{owner}",
+		url:     "https://github.com/motemen/ghq",
+		wantErr: true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.tmpl+" "+tc.url, func(t *testing.T) {
+			got, err := renderRemoteName(tc.tmpl, mustParseURL(tc.url))
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("error should be occurred but got: %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.expect {
+				t.Errorf("got: %s, expect: %s", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestIsLocalRemote(t *testing.T) {
+	testCases := []struct {
+		url    string
+		expect bool
+	}{
+		{"file:///path/to/repo", true},
+		{"/path/to/repo", true},
+		{"https://github.com/motemen/ghq", false},
+		{"ssh://git@github.com/motemen/ghq", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			if got := isLocalRemote(mustParseURL(tc.url)); got != tc.expect {
+				t.Errorf("got: %t, expect: %t", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestParseRemoteSettings(t *testing.T) {
+	testCases := []struct {
+		name    string
+		vals    []string
+		expect  []remoteSetting
+		wantErr bool
+	}{{
+		name: "valid",
+		vals: []string{"origin=https://github.com/Songmu/ghq", "upstream=git@github.com:x-motemen/ghq.git"},
+		expect: []remoteSetting{
+			{name: "origin", url: "https://github.com/Songmu/ghq"},
+			{name: "upstream", url: "git@github.com:x-motemen/ghq.git"},
+		},
+	}, {
+		name:    "without url",
+		vals:    []string{"origin"},
+		wantErr: true,
+	}, {
+		name:    "invalid name",
+		vals:    []string{"my fork=https://github.com/Songmu/ghq"},
+		wantErr: true,
+	}, {
+		name:    "invalid url",
+		vals:    []string{"origin=github.com/Songmu/ghq"},
+		wantErr: true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseRemoteSettings(tc.vals)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("error should be occurred but got: %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.expect) {
+				t.Errorf("got: %v, expect: %v", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestGetter_afterClone_setRemotes(t *testing.T) {
+	var _commands [][]string
+	defer func(orig func(cmd *exec.Cmd) error) {
+		cmdutil.CommandRunner = orig
+	}(cmdutil.CommandRunner)
+	cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+		_commands = append(_commands, cmd.Args)
+		if reflect.DeepEqual(cmd.Args, []string{"git", "remote", "get-url", "upstream"}) {
+			return fmt.Errorf("[test] no such remote")
+		}
+		return nil
+	}
+
+	g := &getter{setRemotes: []remoteSetting{
+		{name: "origin", url: "https://github.com/Songmu/ghq"},
+		{name: "upstream", url: "https://github.com/x-motemen/ghq"},
+	}}
+	tmpdir := newTempDir(t)
+	defer os.RemoveAll(tmpdir)
+	if err := g.afterClone(GitBackend, &vcsGetOption{dir: tmpdir}); err != nil {
+		t.Fatal(err)
+	}
+	expect := [][]string{
+		{"git", "remote", "get-url", "origin"},
+		{"git", "remote", "set-url", "origin", "https://github.com/Songmu/ghq"},
+		{"git", "remote", "get-url", "upstream"},
+		{"git", "remote", "add", "upstream", "https://github.com/x-motemen/ghq"},
+	}
+	if !reflect.DeepEqual(_commands, expect) {
+		t.Errorf("got: %v, expect: %v", _commands, expect)
+	}
+}
+
+func TestUpdateStrategy(t *testing.T) {
+	defer gitconfig.WithConfig(t, `
+[ghq "update"]
+  strategy = rebase
+[ghq "https://corp.example.com/"]
+  updateStrategy = merge
+[ghq "https://invalid.example.com/"]
+  updateStrategy = squash
+`)()
+
+	testCases := []struct {
+		url     string
+		expect  string
+		wantErr bool
+	}{{
+		url:    "https://github.com/x-motemen/ghq",
+		expect: "rebase",
+	}, {
+		url:    "https://corp.example.com/x-motemen/ghq",
+		expect: "merge",
+	}, {
+		url:     "https://invalid.example.com/x-motemen/ghq",
+		wantErr: true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			got, err := updateStrategy(mustParseURL(tc.url))
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("error should be occurred but got: %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.expect {
+				t.Errorf("got: %s, expect: %s", got, tc.expect)
+			}
+		})
+	}
+
+	t.Run("default", func(t *testing.T) {
+		defer gitconfig.WithConfig(t, "")()
+		got, err := updateStrategy(mustParseURL("https://github.com/x-motemen/ghq"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "ff-only" {
+			t.Errorf("got: %s, expect: ff-only", got)
+		}
+	})
+}
+
+func TestPullRequestRefspec(t *testing.T) {
+	testCases := []struct {
+		hostname string
+		expect   string
+		wantErr  bool
+	}{{
+		hostname: "github.com",
+		expect:   "pull/123/head",
+	}, {
+		hostname: "gitlab.com",
+		expect:   "merge-requests/123/head",
+	}, {
+		hostname: "gitlab.example.com",
+		expect:   "merge-requests/123/head",
+	}, {
+		hostname: "bitbucket.org",
+		wantErr:  true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.hostname, func(t *testing.T) {
+			got, err := pullRequestRefspec(tc.hostname, 123)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.expect {
+				t.Errorf("got: %q, expect: %q", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestGetter_fetchPullRequest(t *testing.T) {
+	var _commands [][]string
+	defer func(orig func(cmd *exec.Cmd) error) {
+		cmdutil.CommandRunner = orig
+	}(cmdutil.CommandRunner)
+	cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+		_commands = append(_commands, cmd.Args)
+		return nil
+	}
+
+	testCases := []struct {
+		name   string
+		getter *getter
+		url    string
+		expect [][]string
+	}{{
+		name:   "github",
+		getter: &getter{pr: 123},
+		url:    "https://github.com/x-motemen/ghq",
+		expect: [][]string{
+			{"git", "fetch", "origin", "pull/123/head"},
+			{"git", "checkout", "-B", "pr-123", "FETCH_HEAD"},
+		},
+	}, {
+		name:   "gitlab with remote name template",
+		getter: &getter{pr: 4, remoteNameTemplate: "{host}"},
+		url:    "https://gitlab.com/gitlab-org/gitlab",
+		expect: [][]string{
+			{"git", "fetch", "gitlab", "merge-requests/4/head"},
+			{"git", "checkout", "-B", "pr-4", "FETCH_HEAD"},
+		},
+	}, {
+		name:   "not specified",
+		getter: &getter{},
+		url:    "https://github.com/x-motemen/ghq",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_commands = nil
+			u, _ := url.Parse(tc.url)
+			if err := tc.getter.fetchPullRequest(GitBackend, &vcsGetOption{}, u); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(_commands, tc.expect) {
+				t.Errorf("got: %v, expect: %v", _commands, tc.expect)
+			}
+		})
+	}
+
+	u, _ := url.Parse("https://hg.example.com/foo/bar")
+	if err := (&getter{pr: 1}).fetchPullRequest(MercurialBackend, &vcsGetOption{}, u); err == nil {
+		t.Errorf("error should be occurred for Mercurial")
+	}
+}
+
+func TestParseEnvSettings(t *testing.T) {
+	testCases := []struct {
+		name    string
+		vals    []string
+		expect  []string
+		wantErr bool
+	}{{
+		name:   "valid",
+		vals:   []string{"GIT_SSH_COMMAND=ssh -i key", "EMPTY="},
+		expect: []string{"GIT_SSH_COMMAND=ssh -i key", "EMPTY="},
+	}, {
+		name:    "without value",
+		vals:    []string{"GIT_TRACE"},
+		wantErr: true,
+	}, {
+		name:    "without key",
+		vals:    []string{"=1"},
+		wantErr: true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseEnvSettings(tc.vals)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.expect) {
+				t.Errorf("got: %v, expect: %v", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestGetter_setHooksPath(t *testing.T) {
+	var _commands [][]string
+	defer func(orig func(cmd *exec.Cmd) error) {
+		cmdutil.CommandRunner = orig
+	}(cmdutil.CommandRunner)
+	cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+		_commands = append(_commands, cmd.Args)
+		return nil
+	}
+
+	g := &getter{hooksDir: "/path/to/hooks"}
+	if err := g.setHooksPath(GitBackend, &vcsGetOption{dir: "repo"}); err != nil {
+		t.Fatal(err)
+	}
+	expect := [][]string{{"git", "config", "core.hooksPath", "/path/to/hooks"}}
+	if !reflect.DeepEqual(_commands, expect) {
+		t.Errorf("got: %v, expect: %v", _commands, expect)
+	}
+
+	_commands = nil
+	if err := g.setHooksPath(MercurialBackend, &vcsGetOption{dir: "repo"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&getter{}).setHooksPath(GitBackend, &vcsGetOption{dir: "repo"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(_commands) > 0 {
+		t.Errorf("nothing should be run, but: %v", _commands)
+	}
+}
+
+func TestGetter_cloneBundle(t *testing.T) {
+	var _commands [][]string
+	defer func(orig func(cmd *exec.Cmd) error) {
+		cmdutil.CommandRunner = orig
+	}(cmdutil.CommandRunner)
+	cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+		_commands = append(_commands, cmd.Args)
+		return nil
+	}
+
+	tmpdir := newTempDir(t)
+	defer os.RemoveAll(tmpdir)
+	localDir := filepath.Join(tmpdir, "repo")
+
+	g := &getter{bundle: "/path/to/ghq.bundle"}
+	vg := &vcsGetOption{
+		url:    mustParseURL("https://github.com/x-motemen/ghq"),
+		dir:    localDir,
+		origin: "upstream",
+	}
+	if err := g.cloneBundle(vg); err != nil {
+		t.Fatal(err)
+	}
+	expect := [][]string{
+		{"git", "bundle", "list-heads", "/path/to/ghq.bundle"},
+		{"git", "clone", "--origin", "upstream", "/path/to/ghq.bundle", localDir},
+		{"git", "remote", "set-url", "upstream", "https://github.com/x-motemen/ghq"},
+	}
+	if !reflect.DeepEqual(_commands, expect) {
+		t.Errorf("got: %v, expect: %v", _commands, expect)
+	}
+
+	cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+		return fmt.Errorf("[test] not a bundle")
+	}
+	if err := g.cloneBundle(vg); err == nil || !strings.HasPrefix(err.Error(), "invalid bundle") {
+		t.Errorf("error should be occurred for the invalid bundle, but: %v", err)
+	}
+}
+
+func TestGetter_gitOnlyOptions(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		for _, tc := range []struct {
+			g      *getter
+			expect string
+		}{
+			{&getter{vcs: "hg", sparse: []string{"docs"}}, "--sparse only supported for git repositories"},
+			{&getter{vcs: "hg", mirror: true}, "--mirror only supported for git repositories"},
+			{&getter{vcs: "hg", reference: "/path/to/upstream"}, "--reference only supported for git repositories"},
+		} {
+			err := tc.g.get(context.Background(), "https://example.com/motemen/ghq-test-repo")
+			if err == nil || err.Error() != tc.expect {
+				t.Errorf("error should be %q for non-git, but: %v", tc.expect, err)
+			}
+		}
+	})
+}
+
+func TestGetter_shallowUnsupported(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		var cloned *vcsGetOption
+		defer func(orig *VCSBackend) { vcsRegistry["hg"] = orig }(vcsRegistry["hg"])
+		vcsRegistry["hg"] = &VCSBackend{
+			Clone: func(vg *vcsGetOption) error {
+				cloned = vg
+				return nil
+			},
+		}
+		target := "https://example.com/motemen/ghq-test-repo"
+
+		g := &getter{vcs: "hg", shallow: true, strict: true}
+		expect := "--shallow is not supported for hg"
+		if err := g.get(context.Background(), target); err == nil || err.Error() != expect {
+			t.Errorf("error should be %q, but: %v", expect, err)
+		}
+		if cloned != nil {
+			t.Errorf("should not be cloned with --strict")
+		}
+
+		buf := &bytes.Buffer{}
+		logger.SetOutput(buf)
+		defer func() { logger.SetOutput(os.Stderr) }()
+		g = &getter{vcs: "hg", shallow: true}
+		if err := g.get(context.Background(), target); err != nil {
+			t.Fatalf("error should be nil but: %s", err)
+		}
+		if cloned == nil {
+			t.Fatalf("should be cloned")
+		}
+		if out := buf.String(); !strings.Contains(out, "--shallow is not supported for hg") {
+			t.Errorf("warning should be printed, but: %q", out)
+		}
+
+		vcsRegistry["hg"].SupportsShallow = true
+		cloned = nil
+		g = &getter{vcs: "hg", shallow: true, depth: 10, strict: true}
+		expect = "--depth is not supported for hg"
+		if err := g.get(context.Background(), target); err == nil || err.Error() != expect {
+			t.Errorf("error should be %q, but: %v", expect, err)
+		}
+		if cloned != nil {
+			t.Errorf("should not be cloned with --strict")
+		}
+	})
+}
+
+func TestGetter_timeout(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		defer func(orig *VCSBackend) { vcsRegistry["hg"] = orig }(vcsRegistry["hg"])
+		vcsRegistry["hg"] = &VCSBackend{
+			Clone: func(vg *vcsGetOption) error {
+				// hangs after creating the directory until it is killed
+				os.MkdirAll(filepath.Join(vg.dir, ".hg"), 0755)
+				<-vg.ctx.Done()
+				return vg.ctx.Err()
+			},
+		}
+		buf := &bytes.Buffer{}
+		logger.SetOutput(buf)
+		defer func() { logger.SetOutput(os.Stderr) }()
+
+		g := &getter{vcs: "hg", timeoutPerRepo: 10 * time.Millisecond}
+		err := g.get(context.Background(), "https://example.com/motemen/ghq-test-repo")
+		if !isTimeout(err) {
+			t.Errorf("timeout error should be occurred, but: %v", err)
+		}
+		localDir := filepath.Join(tmproot, "example.com", "motemen", "ghq-test-repo")
+		if _, err := os.Stat(localDir); !os.IsNotExist(err) {
+			t.Errorf("the partial clone should be removed, but: %v", err)
+		}
+		if out := buf.String(); !strings.Contains(out, "removed the partial clone "+localDir) {
+			t.Errorf("the removal should be logged, but: %q", out)
+		}
+	})
+}
+
+func TestDoGet_timeout(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		defer func(orig *VCSBackend) { vcsRegistry["hg"] = orig }(vcsRegistry["hg"])
+		var cloned []string
+		vcsRegistry["hg"] = &VCSBackend{
+			Clone: func(vg *vcsGetOption) error {
+				cloned = append(cloned, vg.url.String())
+				// hangs until it is killed
+				<-vg.ctx.Done()
+				return vg.ctx.Err()
+			},
+		}
+		buf := &bytes.Buffer{}
+		logger.SetOutput(buf)
+		defer func() { logger.SetOutput(os.Stderr) }()
+
+		err := newApp().Run([]string{"", "get", "--vcs", "hg", "--timeout", "10ms",
+			"https://example.com/motemen/ghq-test-repo", "https://example.com/motemen/ghq-test-repo2"})
+		expect := "failed to get 2 repositories (2 timed out)"
+		if err == nil || !strings.HasPrefix(err.Error(), expect) {
+			t.Errorf("error should start with %q, but: %v", expect, err)
+		}
+		// the second one is not started after the deadline of the batch
+		if len(cloned) != 1 {
+			t.Errorf("only the first repository should be cloned, but: %v", cloned)
+		}
+		if out := buf.String(); !strings.Contains(out, "timed out after 10ms") {
+			t.Errorf("the timeout should be logged, but: %q", out)
+		}
+	})
+}
+
+func TestGetter_githubAuth(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, cloneArgs *_cloneArgs, updateArgs *_updateArgs) {
+		defer gitconfig.WithConfig(t, "[ghq \"github.com\"]\ntoken = dummy-token")()
+		defer tmpEnv("GIT_CONFIG_COUNT", "")()
+		githubCloneTokenOnce = &sync.Once{}
+		defer func() { githubCloneTokenOnce = &sync.Once{} }()
+
+		hasAuth := func(env []string) bool {
+			for _, e := range env {
+				if strings.HasPrefix(e, "GIT_CONFIG_VALUE_0=Authorization: ") {
+					return true
+				}
+			}
+			return false
+		}
+		testCases := []struct {
+			name   string
+			rules  []urlRewrite
+			expect bool
+		}{{
+			name:   "github",
+			expect: true,
+		}, {
+			name: "rewritten to a mirror",
+			rules: []urlRewrite{{
+				prefix:      "https://github.com/",
+				replacement: "https://git.example.com/",
+			}},
+		}}
+		for i, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				g := &getter{urlRewrites: tc.rules, onExists: onExistsUpdate}
+				// the repositories differ not to be skipped as got already
+				if err := g.get(context.Background(), fmt.Sprintf("motemen/auth-clone-%d", i)); err != nil {
+					t.Fatal(err)
+				}
+				if got := hasAuth(cloneArgs.env); got != tc.expect {
+					t.Errorf("the token for cloning: got: %t, expect: %t", got, tc.expect)
+				}
+				name := fmt.Sprintf("motemen/auth-update-%d", i)
+				os.MkdirAll(filepath.Join(tmproot, "github.com", name, ".git"), 0755)
+				if err := g.get(context.Background(), name); err != nil {
+					t.Fatal(err)
+				}
+				if got := hasAuth(updateArgs.env); got != tc.expect {
+					t.Errorf("the token for updating: got: %t, expect: %t", got, tc.expect)
+				}
+			})
+		}
+	})
+}
+
+func TestGetter_partialClone(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		defer func(orig *VCSBackend) { vcsRegistry["hg"] = orig }(vcsRegistry["hg"])
+		vcsRegistry["hg"] = &VCSBackend{
+			Clone: func(vg *vcsGetOption) error {
+				// fails in the middle, e.g. by a network drop
+				os.MkdirAll(filepath.Join(vg.dir, ".hg"), 0755)
+				return errors.New("abort: connection reset by peer")
+			},
+		}
+		buf := &bytes.Buffer{}
+		logger.SetOutput(buf)
+		defer func() { logger.SetOutput(os.Stderr) }()
+
+		g := &getter{vcs: "hg"}
+		if err := g.get(context.Background(), "https://example.com/motemen/ghq-test-repo"); err == nil {
+			t.Fatal("error should be occurred")
+		}
+		localDir := filepath.Join(tmproot, "example.com", "motemen", "ghq-test-repo")
+		if _, err := os.Stat(localDir); !os.IsNotExist(err) {
+			t.Errorf("the partial clone should be removed, but: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(tmproot, "example.com")); !os.IsNotExist(err) {
+			t.Errorf("the empty parents should be removed, but: %v", err)
+		}
+		if _, err := os.Stat(tmproot); err != nil {
+			t.Errorf("the root should not be removed, but: %v", err)
+		}
+		if out := buf.String(); !strings.Contains(out, "removed the partial clone "+localDir) {
+			t.Errorf("the removal should be logged, but: %q", out)
+		}
+
+		// the parents having another repository are kept
+		other := filepath.Join(tmproot, "example.com", "motemen", "other")
+		os.MkdirAll(filepath.Join(other, ".hg"), 0755)
+		if err := g.get(context.Background(), "https://example.com/motemen/ghq-test-repo2"); err == nil {
+			t.Fatal("error should be occurred")
+		}
+		if _, err := os.Stat(other); err != nil {
+			t.Errorf("the other repository should not be removed, but: %v", err)
+		}
+	})
+}
+
+func TestSplitVCSPrefix(t *testing.T) {
+	testCases := []struct {
+		ref, vcs, rest string
+	}{
+		{"hg::https://example.com/repo", "hg", "https://example.com/repo"},
+		{"git::github.com/motemen/ghq", "git", "github.com/motemen/ghq"},
+		{"unknown::https://example.com/repo", "", "unknown::https://example.com/repo"},
+		{"codecommit::ap-northeast-1://repo", "", "codecommit::ap-northeast-1://repo"},
+		{"https://example.com/repo", "", "https://example.com/repo"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.ref, func(t *testing.T) {
+			vcs, rest := splitVCSPrefix(tc.ref)
+			if vcs != tc.vcs || rest != tc.rest {
+				t.Errorf("got: %q, %q, expect: %q, %q", vcs, rest, tc.vcs, tc.rest)
+			}
+		})
+	}
+}
+
+func TestExistingCloneDirs(t *testing.T) {
+	tmpdir := newTempDir(t)
+	defer os.RemoveAll(tmpdir)
+	base := filepath.Join(tmpdir, "svn.example.com", "repo")
+	os.MkdirAll(base, 0755)
+
+	got := existingCloneDirs(base + "/trunk")
+	if expect := map[string]bool{base: true}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("got: %v, expect: %v", got, expect)
+	}
+}
+
+func TestGitReferenceRepository(t *testing.T) {
+	tmpd := newTempDir(t)
+	defer os.RemoveAll(tmpd)
+	for _, d := range []string{"work/.git/objects", "work/.git/refs", "bare.git/objects", "bare.git/refs", "plain"} {
+		os.MkdirAll(filepath.Join(tmpd, d), 0755)
+	}
+	ioutil.WriteFile(filepath.Join(tmpd, "work/.git/HEAD"), []byte("ref: refs/heads/main\n"), 0644)
+	ioutil.WriteFile(filepath.Join(tmpd, "bare.git/HEAD"), []byte("ref: refs/heads/main\n"), 0644)
+
+	testCases := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "working tree", path: "work"},
+		{name: "bare", path: "bare.git"},
+		{name: "not a repository", path: "plain", wantErr: true},
+		{name: "missing", path: "missing", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := filepath.Join(tmpd, tc.path)
+			got, err := gitReferenceRepository(p)
+			if tc.wantErr {
+				if exitStatus(err) != exitStatusUsage {
+					t.Errorf("usage error should be occurred, but: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != p {
+				t.Errorf("got: %s, expect: %s", got, p)
+			}
+		})
+	}
+}
+
+func TestGetter_localPath(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, cloneArgs *_cloneArgs, _ *_updateArgs) {
+		src := newTempDir(t)
+		defer os.RemoveAll(src)
+		os.MkdirAll(filepath.Join(src, "repo", ".git"), 0755)
+
+		g := &getter{}
+		if err := g.get(context.Background(), filepath.Join(src, "repo")); err != nil {
+			t.Fatalf("error should be nil but: %s", err)
+		}
+		if expect := filepath.Join(tmproot, "localhost", "repo"); cloneArgs.local != expect {
+			t.Errorf("got: %s, expect: %s", cloneArgs.local, expect)
+		}
+		if cloneArgs.remote.Scheme != "file" {
+			t.Errorf("the repository should be cloned from the file URL, but: %s", cloneArgs.remote)
+		}
+	})
+}
+
+func TestGetter_progress(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		target := "https://github.com/motemen/ghq-test-repo"
+		rp := &repoProgress{}
+		g := &getter{progress: rp}
+		if err := g.get(context.Background(), target); err != nil {
+			t.Fatalf("error should be nil but: %s", err)
+		}
+		if rp.action != "clone" {
+			t.Errorf("got: %q, expect: %q", rp.action, "clone")
+		}
+
+		os.MkdirAll(filepath.Join(tmproot, "github.com/motemen/ghq-test-repo/.git"), 0755)
+		rp = &repoProgress{}
+		g = &getter{progress: rp, onExists: onExistsUpdate}
+		if err := g.get(context.Background(), target); err != nil {
+			t.Fatalf("error should be nil but: %s", err)
+		}
+		if rp.action != "update" {
+			t.Errorf("got: %q, expect: %q", rp.action, "update")
+		}
+	})
+}
+
+func TestRunAfterHook(t *testing.T) {
+	var _commands []*exec.Cmd
+	defer func(orig func(cmd *exec.Cmd) error) {
+		cmdutil.CommandRunner = orig
+	}(cmdutil.CommandRunner)
+	cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+		_commands = append(_commands, cmd)
+		return nil
+	}
+	defer gitconfig.WithConfig(t, `
+[ghq "get"]
+  afterHook = direnv allow {path}
+[ghq "github.com.get"]
+  afterHook = go mod download
+`)()
+
+	testCases := []struct {
+		name, kind, url string
+		expect          string
+	}{{
+		name:   "per host",
+		kind:   "get",
+		url:    "https://github.com/x-motemen/ghq",
+		expect: "go mod download",
+	}, {
+		name:   "default",
+		kind:   "get",
+		url:    "https://gitlab.com/x-motemen/ghq",
+		expect: "direnv allow /path/to/repo",
+	}, {
+		name: "update without ghq.update.afterHook",
+		kind: "update",
+		url:  "https://github.com/x-motemen/ghq",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_commands = nil
+			vg := &vcsGetOption{dir: "/path/to/repo", silent: true}
+			if err := runAfterHook(tc.kind, mustParseURL(tc.url), vg); err != nil {
+				t.Fatal(err)
+			}
+			if tc.expect == "" {
+				if len(_commands) > 0 {
+					t.Errorf("no hook should be run, but: %v", _commands[0].Args)
+				}
+				return
+			}
+			if len(_commands) != 1 {
+				t.Fatalf("the hook should be run once, but: %d", len(_commands))
+			}
+			c := _commands[0]
+			if got := c.Args[len(c.Args)-1]; got != tc.expect {
+				t.Errorf("got: %s, expect: %s", got, tc.expect)
+			}
+			if c.Dir != "/path/to/repo" {
+				t.Errorf("the hook should be run in the repository, but: %s", c.Dir)
+			}
+		})
+	}
+
+	cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+		return fmt.Errorf("[test] exit status 1")
+	}
+	err := runAfterHook("get", mustParseURL("https://github.com/x-motemen/ghq"), &vcsGetOption{dir: "/path/to/repo"})
+	if err == nil || !strings.HasPrefix(err.Error(), "ghq.get.afterHook failed") {
+		t.Errorf("error should be occurred for the failed hook, but: %v", err)
+	}
+}
+
+func TestGetter_clone(t *testing.T) {
+	defer func(orig time.Duration) { retryBackoff = orig }(retryBackoff)
+	retryBackoff = 0
+
+	exitErr := &cmdutil.RunError{Command: exec.Command("git"), ExecError: &exec.ExitError{ProcessState: &os.ProcessState{}}}
+	testCases := []struct {
+		name     string
+		retry    int
+		existing bool
+		errs     []error
+		attempts int
+		wantErr  bool
+	}{{
+		name:     "succeeded after retries",
+		retry:    3,
+		errs:     []error{exitErr, exitErr},
+		attempts: 3,
+	}, {
+		name:     "all attempts failed",
+		retry:    2,
+		errs:     []error{exitErr, exitErr, exitErr, exitErr},
+		attempts: 3,
+		wantErr:  true,
+	}, {
+		name:     "without retry",
+		errs:     []error{exitErr},
+		attempts: 1,
+		wantErr:  true,
+	}, {
+		name:     "not a failure of the command",
+		retry:    3,
+		errs:     []error{errors.New("--branch option is unavailable")},
+		attempts: 1,
+		wantErr:  true,
+	}, {
+		name:     "existing directory",
+		retry:    3,
+		existing: true,
+		errs:     []error{exitErr},
+		attempts: 1,
+		wantErr:  true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpd := newTempDir(t)
+			defer os.RemoveAll(tmpd)
+			dir := filepath.Join(tmpd, "repo")
+
+			if tc.existing {
+				os.MkdirAll(dir, 0755)
+			}
+			existing := existingCloneDirs(dir)
+
+			attempts := 0
+			backend := &VCSBackend{Clone: func(vg *vcsGetOption) error {
+				attempts++
+				// a partial clone left by the failure
+				if _, err := os.Stat(vg.dir); err == nil && !tc.existing {
+					t.Errorf("the directory of the failed attempt should be removed")
+				}
+				os.MkdirAll(vg.dir, 0755)
+				if attempts <= len(tc.errs) {
+					return tc.errs[attempts-1]
+				}
+				return nil
+			}}
+			err := (&getter{retry: tc.retry}).clone(backend, &vcsGetOption{url: remoteDummyURL, dir: dir, silent: true}, existing)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if attempts != tc.attempts {
+				t.Errorf("attempts: got: %d, expect: %d", attempts, tc.attempts)
+			}
+			if _, err := os.Stat(dir); tc.existing && err != nil {
+				t.Errorf("the existing directory should not be removed")
+			}
+		})
+	}
+}
+
+func TestGetter_verifyCleanAfterClone(t *testing.T) {
+	defer func(orig func(cmd *exec.Cmd) error) {
+		cmdutil.CommandRunner = orig
+	}(cmdutil.CommandRunner)
+	var status string
+	cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+		if !reflect.DeepEqual(cmd.Args, []string{"git", "status", "--porcelain"}) {
+			return fmt.Errorf("unexpected command: %v", cmd.Args)
+		}
+		fmt.Fprint(cmd.Stdout, status)
+		return nil
+	}
+	tmpd := newTempDir(t)
+	defer os.RemoveAll(tmpd)
+	vg := &vcsGetOption{dir: tmpd}
+
+	g := &getter{verifyClean: true}
+	if err := g.verifyCleanAfterClone(GitBackend, vg); err != nil {
+		t.Errorf("error should be nil for the clean tree, but: %s", err)
+	}
+	status = " M go.sum\n?? generated.go\n"
+	if err := g.verifyCleanAfterClone(GitBackend, vg); err == nil {
+		t.Errorf("error should be occurred for the dirty tree")
+	}
+	if err := g.verifyCleanAfterClone(MercurialBackend, vg); err != nil {
+		t.Errorf("other VCSs should be skipped, but: %s", err)
+	}
+	if err := (&getter{}).verifyCleanAfterClone(GitBackend, vg); err != nil {
+		t.Errorf("it should be skipped without --verify-clean-after, but: %s", err)
+	}
+}
+
+func TestGetter_cloneOption_defaultBranch(t *testing.T) {
+	var requested int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested++
+		fmt.Fprint(w, `{"full_name": "motemen/ghq", "default_branch": "main"}`)
+	}))
+	defer ts.Close()
+	defer func(orig string) { githubAPIBase = orig }(githubAPIBase)
+	githubAPIBase = ts.URL
+	defer tmpEnv("GITHUB_TOKEN", "dummy-token")()
+
+	testCases := []struct {
+		name   string
+		g      *getter
+		expect string
+	}{{
+		name:   "default",
+		g:      &getter{},
+		expect: "main",
+	}, {
+		name: "branch",
+		g:    &getter{branch: "develop"},
+	}, {
+		name: "pull request",
+		g:    &getter{pr: 123},
+	}, {
+		name: "dry-run",
+		g:    &getter{dryRun: true},
+	}}
+
+	u := mustParseURL("https://github.com/motemen/ghq")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requested = 0
+			vg, err := tc.g.cloneOption(context.Background(), GitBackend, u, u, "/path/to/ghq")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if vg.defaultBranch != tc.expect {
+				t.Errorf("got: %q, expect: %q", vg.defaultBranch, tc.expect)
+			}
+			expect := 0
+			if tc.expect != "" {
+				expect = 1
+			}
+			if requested != expect {
+				t.Errorf("API requests: got: %d, expect: %d", requested, expect)
+			}
+		})
+	}
+}
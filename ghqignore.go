@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ghqIgnoreFile is the file at a root listing the directories not walked into
+const ghqIgnoreFile = ".ghqignore"
+
+// ignorePatterns are the glob patterns of .ghqignore. As .gitignore, a
+// pattern containing a slash, except the trailing one, is matched against the
+// path relative to the root, and the other ones against the base name at any
+// depth.
+type ignorePatterns []string
+
+// loadIgnorePatterns reads the .ghqignore at the root. Empty lines and the
+// ones beginning with "#" are skipped.
+func loadIgnorePatterns(root string) (ignorePatterns, error) {
+	f, err := os.Open(filepath.Join(root, ghqIgnoreFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var ps ignorePatterns
+	scr := bufio.NewScanner(f)
+	for scr.Scan() {
+		p := strings.TrimSpace(scr.Text())
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+		// only the directories are matched anyway
+		p = strings.TrimSuffix(p, "/")
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, &os.PathError{Op: "parse", Path: f.Name(), Err: err}
+		}
+		ps = append(ps, p)
+	}
+	if err := scr.Err(); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+// match reports whether the directory at fpath under the root is ignored
+func (ps ignorePatterns) match(root, fpath string) bool {
+	if len(ps) == 0 {
+		return false
+	}
+	rel, err := filepath.Rel(root, fpath)
+	if err != nil || rel == "." {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	base := path.Base(rel)
+	for _, p := range ps {
+		var ok bool
+		if strings.Contains(p, "/") {
+			ok, _ = path.Match(strings.TrimPrefix(p, "/"), rel)
+		} else {
+			ok, _ = path.Match(p, base)
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
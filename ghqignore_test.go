@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestIgnorePatterns_match(t *testing.T) {
+	root := filepath.FromSlash("/ghq")
+	ps := ignorePatterns{"node_modules", "*.cache", "github.com/motemen/*", "/gitlab.com"}
+
+	testCases := []struct {
+		path   string
+		expect bool
+	}{
+		{"node_modules", true},
+		{"github.com/x-motemen/ghq/node_modules", true},
+		{"build.cache", true},
+		{"github.com/x-motemen/build.cache", true},
+		{"github.com/motemen/ghq", true},
+		{"github.com/motemen", false},
+		{"github.com/x-motemen/ghq", false},
+		{"gitlab.com", true},
+		{"example.com/gitlab.com", false},
+		{".", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			if got := ps.match(root, filepath.Join(root, filepath.FromSlash(tc.path))); got != tc.expect {
+				t.Errorf("got: %t, expect: %t", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestLoadIgnorePatterns(t *testing.T) {
+	root := newTempDir(t)
+	defer os.RemoveAll(root)
+
+	ps, err := loadIgnorePatterns(root)
+	if err != nil || ps != nil {
+		t.Errorf("got: %v, %v, expect: no patterns", ps, err)
+	}
+
+	content := "# build caches\n\nnode_modules/\n  .cache  \ngithub.com/motemen/*\n"
+	if err := ioutil.WriteFile(filepath.Join(root, ghqIgnoreFile), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ps, err = loadIgnorePatterns(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := (ignorePatterns{"node_modules", ".cache", "github.com/motemen/*"}); !reflect.DeepEqual(ps, expect) {
+		t.Errorf("got: %v, expect: %v", ps, expect)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(root, ghqIgnoreFile), []byte("[invalid\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadIgnorePatterns(root); err == nil {
+		t.Errorf("error should be occurred for the invalid pattern")
+	}
+}
+
+func TestWalkLocalRepositories_ghqignore(t *testing.T) {
+	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
+	root := newTempDir(t)
+	defer os.RemoveAll(root)
+	_localRepositoryRoots = []string{root}
+	for _, p := range []string{
+		"github.com/x-motemen/ghq",
+		"github.com/x-motemen/ghq/node_modules/dep", // a repository in the ignored directory
+		"cache/github.com/motemen/ghq",
+	} {
+		os.MkdirAll(filepath.Join(root, filepath.FromSlash(p), ".git"), 0755)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, ghqIgnoreFile), []byte("node_modules\n/cache\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		paths []string
+		mu    sync.Mutex
+	)
+	if err := walkAllLocalRepositories(func(repo *LocalRepository) {
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, filepath.ToSlash(repo.RelPath))
+	}); err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	sort.Strings(paths)
+	if expect := []string{"github.com/x-motemen/ghq"}; !reflect.DeepEqual(paths, expect) {
+		t.Errorf("got: %v, expect: %v", paths, expect)
+	}
+}
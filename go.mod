@@ -14,5 +14,5 @@ require (
 	github.com/urfave/cli/v2 v2.3.0
 	golang.org/x/net v0.0.0-20210224082022-3d97a244fca7
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
-	golang.org/x/sys v0.0.0-20210225014209-683adc9d29d7 // indirect
+	golang.org/x/sys v0.0.0-20210225014209-683adc9d29d7
 )
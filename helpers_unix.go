@@ -2,6 +2,12 @@
 
 package main
 
+import "golang.org/x/sys/unix"
+
 func toFullPath(s string) (string, error) {
 	return s, nil
 }
+
+func isWritable(path string) bool {
+	return unix.Access(path, unix.W_OK) == nil
+}
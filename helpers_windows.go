@@ -2,7 +2,10 @@
 
 package main
 
-import "syscall"
+import (
+	"os"
+	"syscall"
+)
 
 func toFullPath(s string) (string, error) {
 	p := syscall.StringToUTF16(s)
@@ -21,3 +24,11 @@ func toFullPath(s string) (string, error) {
 	b = b[:n]
 	return syscall.UTF16ToString(b), nil
 }
+
+func isWritable(path string) bool {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return fi.Mode().Perm()&0200 != 0
+}
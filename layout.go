@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/Songmu/gitconfig"
+)
+
+const (
+	rootLayoutDefault = "{{.Host}}/{{.Path}}"
+	rootLayoutNoHost  = "{{.Path}}"
+)
+
+// rootLayoutPresets are the names available for ghq.root.layout in place of
+// the templates.
+var rootLayoutPresets = map[string]string{
+	"default": rootLayoutDefault,
+	"no-host": rootLayoutNoHost,
+}
+
+// rootLayoutVars are the variables available in the template of
+// ghq.root.layout. For https://gitlab.com/group/subgroup/repo.git, Host is
+// "gitlab.com", User is "group", Repo is "repo" and Path is
+// "group/subgroup/repo".
+type rootLayoutVars struct {
+	Host, User, Repo, Path string
+}
+
+// rootLayout is the directory layout of the repositories under the roots.
+type rootLayout struct {
+	tmpl *template.Template
+	// hasHost is true when the first directory is the host, i.e. the
+	// template starts with "{{.Host}}/".
+	hasHost bool
+	// includePort appends the port of the URL to the host, e.g.
+	// "git.example.com_8443", configured by ghq.root.includePort
+	includePort bool
+}
+
+// defaultPorts are the ports omitted from the host even with includePort
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ssh":   "22",
+	"git":   "9418",
+}
+
+func parseRootLayout(s string) (*rootLayout, error) {
+	if s == "" {
+		s = rootLayoutDefault
+	}
+	if p, ok := rootLayoutPresets[s]; ok {
+		s = p
+	}
+	tmpl, err := template.New("layout").Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ghq.root.layout %q: %w", s, err)
+	}
+	return &rootLayout{
+		tmpl:    tmpl,
+		hasHost: strings.HasPrefix(s, "{{.Host}}/"),
+	}, nil
+}
+
+// relPath returns the path relative to the root where the repository of the
+// URL is placed.
+func (l *rootLayout) relPath(u *url.URL) (string, error) {
+	host := localHostname(u)
+	// the port is joined with "_" since ":" is not allowed in Windows paths
+	if port := u.Port(); l.includePort && port != "" && port != defaultPorts[u.Scheme] {
+		host += "_" + port
+	}
+	p := strings.TrimSuffix(strings.Trim(path.Clean("/"+u.Path), "/"), ".git")
+	if u.Scheme == "file" {
+		// the repositories cloned from local directories are placed as
+		// "localhost/<basename>"
+		host, p = "localhost", path.Base(p)
+	}
+	parts := strings.Split(p, "/")
+	vars := rootLayoutVars{
+		Host: host,
+		Repo: parts[len(parts)-1],
+		Path: p,
+	}
+	if len(parts) > 1 {
+		vars.User = parts[0]
+	}
+	var b strings.Builder
+	if err := l.tmpl.Execute(&b, vars); err != nil {
+		return "", fmt.Errorf("failed to render ghq.root.layout for %s: %w", u, err)
+	}
+	rel := path.Clean(b.String())
+	if rel == "." || path.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("ghq.root.layout renders an invalid path %q for %s", b.String(), u)
+	}
+	return filepath.FromSlash(rel), nil
+}
+
+var (
+	_rootLayout    *rootLayout
+	_rootLayoutErr error
+	rootLayoutOnce = &sync.Once{}
+)
+
+// getRootLayout returns the layout configured by ghq.root.layout and
+// ghq.root.includePort.
+func getRootLayout() (*rootLayout, error) {
+	rootLayoutOnce.Do(func() {
+		s, err := gitconfig.Get("ghq.root.layout")
+		if err != nil && !gitconfig.IsNotFound(err) {
+			_rootLayoutErr = err
+			return
+		}
+		if _rootLayout, _rootLayoutErr = parseRootLayout(s); _rootLayoutErr != nil {
+			return
+		}
+		_rootLayout.includePort, err = gitconfig.Bool("ghq.root.includePort")
+		if err != nil && !gitconfig.IsNotFound(err) {
+			_rootLayoutErr = err
+		}
+	})
+	return _rootLayout, _rootLayoutErr
+}
+
+// rootLayoutHasHost reports whether the repositories are placed under the
+// directories of the hosts. It is true unless ghq.root.layout says otherwise.
+func rootLayoutHasHost() bool {
+	l, err := getRootLayout()
+	return err != nil || l.hasHost
+}
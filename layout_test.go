@@ -0,0 +1,201 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/Songmu/gitconfig"
+)
+
+func TestRootLayout_relPath(t *testing.T) {
+	testCases := []struct {
+		name, layout, url, expect string
+		includePort               bool
+	}{{
+		name:   "default",
+		url:    "https://github.com/x-motemen/ghq.git",
+		expect: "github.com/x-motemen/ghq",
+	}, {
+		name:   "default preset",
+		layout: "default",
+		url:    "https://github.com/x-motemen/ghq",
+		expect: "github.com/x-motemen/ghq",
+	}, {
+		name:   "no-host",
+		layout: "no-host",
+		url:    "https://github.com/x-motemen/ghq",
+		expect: "x-motemen/ghq",
+	}, {
+		name:   "template",
+		layout: "{{.User}}/{{.Repo}}@{{.Host}}",
+		url:    "ssh://git@example.com/x-motemen/ghq.git",
+		expect: "x-motemen/ghq@example.com",
+	}, {
+		name:   "subgroups",
+		layout: "{{.Host}}/{{.Path}}",
+		url:    "https://gitlab.com/group/subgroup/repo.git",
+		expect: "gitlab.com/group/subgroup/repo",
+	}, {
+		name:   "no user",
+		layout: "{{.Host}}/{{.User}}/{{.Repo}}",
+		url:    "https://git.assembla.com/ghq.git",
+		expect: "git.assembla.com/ghq",
+	}, {
+		name:   "file",
+		url:    "file:///path/to/repo.git",
+		expect: "localhost/repo",
+	}, {
+		name:   "port",
+		url:    "https://git.corp.example:8443/team/proj",
+		expect: "git.corp.example/team/proj",
+	}, {
+		name:        "port included",
+		url:         "https://git.corp.example:8443/team/proj",
+		includePort: true,
+		expect:      "git.corp.example_8443/team/proj",
+	}, {
+		name:        "ssh port included",
+		url:         "ssh://git@git.corp.example:2222/team/proj.git",
+		includePort: true,
+		expect:      "git.corp.example_2222/team/proj",
+	}, {
+		name:        "default port",
+		url:         "https://git.corp.example:443/team/proj",
+		includePort: true,
+		expect:      "git.corp.example/team/proj",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l, err := parseRootLayout(tc.layout)
+			if err != nil {
+				t.Fatalf("error should be nil but: %s", err)
+			}
+			l.includePort = tc.includePort
+			got, err := l.relPath(mustParseURL(tc.url))
+			if err != nil {
+				t.Fatalf("error should be nil but: %s", err)
+			}
+			if got != filepath.FromSlash(tc.expect) {
+				t.Errorf("got: %s, expect: %s", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestRootLayout_err(t *testing.T) {
+	testCases := []struct {
+		name, layout string
+	}{
+		{"parse error", "{{.Host"},
+		{"unknown variable", "{{.Owner}}/{{.Repo}}"},
+		{"empty", "{{if false}}{{end}}"},
+		{"outside of the root", "../{{.Path}}"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l, err := parseRootLayout(tc.layout)
+			if err == nil {
+				_, err = l.relPath(mustParseURL("https://github.com/x-motemen/ghq"))
+			}
+			if err == nil {
+				t.Errorf("error should be occurred for %q", tc.layout)
+			}
+		})
+	}
+}
+
+func TestLocalRepositoryFromURL_layout(t *testing.T) {
+	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
+	tmproot := newTempDir(t)
+	defer os.RemoveAll(tmproot)
+	_localRepositoryRoots = []string{tmproot}
+
+	defer gitconfig.WithConfig(t, `
+[ghq "root"]
+  layout = no-host
+`)()
+	rootLayoutOnce = &sync.Once{}
+	defer func() { rootLayoutOnce = &sync.Once{} }()
+
+	// cloned before changing the layout
+	os.MkdirAll(filepath.Join(tmproot, "github.com/x-motemen/old/.git"), 0755)
+
+	r, err := localRepositoryFromURL(mustParseURL("https://github.com/x-motemen/old"), "", false)
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if expect := filepath.Join(tmproot, "github.com/x-motemen/old"); r.FullPath != expect {
+		t.Errorf("the repository in the former layout should be found, got: %s, expect: %s", r.FullPath, expect)
+	}
+
+	r, err = localRepositoryFromURL(mustParseURL("https://github.com/x-motemen/ghq"), "", false)
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if expect := filepath.Join(tmproot, "x-motemen/ghq"); r.FullPath != expect {
+		t.Errorf("got: %s, expect: %s", r.FullPath, expect)
+	}
+	if r.Host() != "" {
+		t.Errorf("Host should be empty, but: %s", r.Host())
+	}
+	if r.NonHostPath() != "x-motemen/ghq" {
+		t.Errorf("got: %s, expect: x-motemen/ghq", r.NonHostPath())
+	}
+	if !r.MatchesExactly("github.com/x-motemen/ghq") || !r.MatchesExactly("x-motemen/ghq") {
+		t.Errorf("the repository should match exactly with or without the host")
+	}
+}
+
+func TestLocalRepositoryFromURL_includePort(t *testing.T) {
+	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
+	tmproot := newTempDir(t)
+	defer os.RemoveAll(tmproot)
+	_localRepositoryRoots = []string{tmproot}
+
+	defer gitconfig.WithConfig(t, `
+[ghq "root"]
+  includePort = true
+`)()
+	rootLayoutOnce = &sync.Once{}
+	defer func() { rootLayoutOnce = &sync.Once{} }()
+
+	testCases := []struct {
+		url, expect string
+	}{
+		{"https://git.corp.example:8443/team/proj", "git.corp.example_8443/team/proj"},
+		{"ssh://git@git.corp.example:2222/team/proj.git", "git.corp.example_2222/team/proj"},
+		{"git@git.corp.example:team/proj.git", "git.corp.example/team/proj"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			u, err := newURL(tc.url, false, false)
+			if err != nil {
+				t.Fatalf("error should be nil but: %s", err)
+			}
+			if tc.url != "git@git.corp.example:team/proj.git" && u.String() != tc.url {
+				t.Errorf("the URL to clone from should keep the port, but: %s", u)
+			}
+			r, err := localRepositoryFromURL(u, "", false)
+			if err != nil {
+				t.Fatalf("error should be nil but: %s", err)
+			}
+			if expect := filepath.Join(tmproot, filepath.FromSlash(tc.expect)); r.FullPath != expect {
+				t.Errorf("got: %s, expect: %s", r.FullPath, expect)
+			}
+		})
+	}
+
+	// cloned before enabling ghq.root.includePort
+	os.MkdirAll(filepath.Join(tmproot, "git.corp.example/team/old/.git"), 0755)
+	r, err := localRepositoryFromURL(mustParseURL("https://git.corp.example:8443/team/old"), "", false)
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if expect := filepath.Join(tmproot, "git.corp.example/team/old"); r.FullPath != expect {
+		t.Errorf("the repository without the port should be found, got: %s, expect: %s", r.FullPath, expect)
+	}
+}
@@ -1,21 +1,42 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/Songmu/gitconfig"
 	"github.com/saracen/walker"
+	"github.com/x-motemen/ghq/cmdutil"
 	"github.com/x-motemen/ghq/logger"
 )
 
 const envGhqRoot = "GHQ_ROOT"
 
+// rootOverride is the root given by the global --root option, which takes
+// precedence over GHQ_ROOT without changing the environment of the commands
+// run by ghq.
+var rootOverride string
+
+// envRoot returns the roots given by --root or GHQ_ROOT
+func envRoot() string {
+	if rootOverride != "" {
+		return rootOverride
+	}
+	return os.Getenv(envGhqRoot)
+}
+
 // LocalRepository represents local repository
 type LocalRepository struct {
 	FullPath  string
@@ -71,52 +92,206 @@ func LocalRepositoryFromFullPath(fullPath string, backend *VCSBackend) (*LocalRe
 	}, nil
 }
 
+// localRepositoryFromPath returns the repository enclosing the path, which may
+// be a subdirectory deep in the repository, e.g. of a monorepo.
+func localRepositoryFromPath(p string) (*LocalRepository, error) {
+	fpath, err := filepath.Abs(p)
+	if err != nil {
+		return nil, err
+	}
+	// the roots have their symlinks evaluated
+	if fpath, err = filepath.EvalSymlinks(fpath); err != nil {
+		return nil, err
+	}
+	roots, err := localRepositoryRoots(true)
+	if err != nil {
+		return nil, err
+	}
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, fpath)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		repo := &LocalRepository{
+			FullPath:  fpath,
+			RelPath:   filepath.ToSlash(rel),
+			RootPath:  root,
+			PathParts: strings.Split(rel, string(filepath.Separator)),
+		}
+		backend, repoPath := repo.VCS()
+		if backend == nil {
+			continue
+		}
+		rel, _ = filepath.Rel(root, repoPath)
+		return &LocalRepository{
+			FullPath:   repoPath,
+			RelPath:    filepath.ToSlash(rel),
+			RootPath:   root,
+			PathParts:  strings.Split(rel, string(filepath.Separator)),
+			vcsBackend: backend,
+		}, nil
+	}
+	return nil, notFoundErrorf("%s is not inside any repository under the roots", p)
+}
+
 // LocalRepositoryFromURL resolve LocalRepository from URL
 func LocalRepositoryFromURL(remoteURL *url.URL) (*LocalRepository, error) {
-	pathParts := append(
-		[]string{remoteURL.Hostname()}, strings.Split(remoteURL.Path, "/")...,
-	)
-	relPath := strings.TrimSuffix(filepath.Join(pathParts...), ".git")
-	pathParts[len(pathParts)-1] = strings.TrimSuffix(pathParts[len(pathParts)-1], ".git")
+	return localRepositoryFromURL(remoteURL, "", false)
+}
+
+// localRepositoryFromURL resolves LocalRepository from URL. If root is not
+// empty, the repository is looked up or placed under the root.
+// When the repository is cloned under more than one root, the one under the
+// primary root (or the former root in the config) is used, or it is an error
+// if strict is true.
+func localRepositoryFromURL(remoteURL *url.URL, root string, strict bool) (*LocalRepository, error) {
+	layout, err := getRootLayout()
+	if err != nil {
+		return nil, err
+	}
+	relPath, err := layout.relPath(remoteURL)
+	if err != nil {
+		return nil, err
+	}
+	// the repositories cloned before changing ghq.root.layout are found in
+	// the default layout, though they are not moved
+	defaultLayout, _ := parseRootLayout(rootLayoutDefault)
+	defaultRelPath, err := defaultLayout.relPath(remoteURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if root != "" {
+		var err error
+		if root, err = findLocalRepositoryRoot(root); err != nil {
+			return nil, err
+		}
+	}
 
-	var (
-		localRepository *LocalRepository
-		mu              sync.Mutex
-	)
 	// Find existing local repository first
-	if err := walkAllLocalRepositories(func(repo *LocalRepository) {
-		if repo.RelPath == relPath {
-			mu.Lock()
-			localRepository = repo
-			mu.Unlock()
+	found, err := collectLocalRepositories("", func(repo *LocalRepository) bool {
+		if root != "" && repo.RootPath != root {
+			return false
 		}
-	}); err != nil {
+		p := filepath.FromSlash(repo.RelPath)
+		return p == relPath || p == defaultRelPath
+	})
+	if err != nil {
 		return nil, err
 	}
+	var localRepos, defaultLayoutRepos []*LocalRepository
+	for _, repo := range found {
+		if filepath.FromSlash(repo.RelPath) == relPath {
+			localRepos = append(localRepos, repo)
+		} else {
+			defaultLayoutRepos = append(defaultLayoutRepos, repo)
+		}
+	}
+	if len(localRepos) == 0 {
+		localRepos = defaultLayoutRepos
+	}
 
-	if localRepository != nil {
-		return localRepository, nil
+	if len(localRepos) > 1 {
+		if strict {
+			b := &strings.Builder{}
+			fmt.Fprintf(b, "%s is cloned under more than one root; Specify the root with --root\n", relPath)
+			for _, repo := range localRepos {
+				fmt.Fprintf(b, "       - %s\n", repo.FullPath)
+			}
+			return nil, errors.New(b.String())
+		}
+		if err := sortByRoots(localRepos); err != nil {
+			return nil, err
+		}
+	}
+	if len(localRepos) > 0 {
+		return localRepos[0], nil
 	}
-	var remoteURLStr = remoteURL.String()
-	if remoteURL.Scheme == "codecommit" {
-		remoteURLStr = remoteURL.Opaque
+
+	// No local repository found, returning new one
+	return newLocalRepositoryFromURL(remoteURL, root)
+}
+
+// newLocalRepositoryFromURL returns LocalRepository at the path where the
+// repository of URL is to be placed, without looking for existing ones.
+// If root is empty, it is determined by the URL.
+func newLocalRepositoryFromURL(remoteURL *url.URL, root string) (*LocalRepository, error) {
+	layout, err := getRootLayout()
+	if err != nil {
+		return nil, err
 	}
-	prim, err := getRoot(remoteURLStr)
+	relPath, err := layout.relPath(remoteURL)
 	if err != nil {
 		return nil, err
 	}
+	pathParts := strings.Split(filepath.ToSlash(relPath), "/")
 
-	// No local repository found, returning new one
+	if root == "" {
+		var remoteURLStr = remoteURL.String()
+		if remoteURL.Scheme == "codecommit" {
+			remoteURLStr = remoteURL.Opaque
+		}
+		root, err = getRoot(remoteURLStr)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return &LocalRepository{
-		FullPath:  filepath.Join(prim, relPath),
+		FullPath:  filepath.Join(root, relPath),
 		RelPath:   relPath,
-		RootPath:  prim,
+		RootPath:  root,
 		PathParts: pathParts,
 	}, nil
 }
 
+// findLocalRepositoryRoot returns the configured root which is the same
+// directory as the given one.
+func findLocalRepositoryRoot(root string) (string, error) {
+	p, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(p); err == nil {
+		if p, err = filepath.EvalSymlinks(p); err != nil {
+			return "", err
+		}
+	}
+	roots, err := localRepositoryRoots(true)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range roots {
+		if r == p {
+			return r, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not one of the roots: %s", root, strings.Join(roots, ", "))
+}
+
+// sortByRoots sorts the repositories in the order of the roots. The primary
+// root comes first.
+func sortByRoots(repos []*LocalRepository) error {
+	roots, err := localRepositoryRoots(true)
+	if err != nil {
+		return err
+	}
+	prim, err := primaryLocalRepositoryRoot()
+	if err != nil {
+		return err
+	}
+	order := make(map[string]int, len(roots))
+	for i, r := range roots {
+		order[r] = i + 1
+	}
+	order[prim] = 0
+	sort.SliceStable(repos, func(i, j int) bool {
+		return order[repos[i].RootPath] < order[repos[j].RootPath]
+	})
+	return nil
+}
+
 func getRoot(u string) (string, error) {
-	prim := os.Getenv(envGhqRoot)
+	prim := envRoot()
 	var err error
 	if prim != "" {
 		return prim, nil
@@ -148,8 +323,20 @@ func (repo *LocalRepository) Subpaths() []string {
 	return tails
 }
 
+// Host returns the host of the repository, i.e. the first part of the path,
+// or "" if the repositories are not placed under the hosts by ghq.root.layout.
+func (repo *LocalRepository) Host() string {
+	if !rootLayoutHasHost() {
+		return ""
+	}
+	return repo.PathParts[0]
+}
+
 // NonHostPath returns non host path
 func (repo *LocalRepository) NonHostPath() string {
+	if repo.Host() == "" {
+		return strings.Join(repo.PathParts, "/")
+	}
 	return strings.Join(repo.PathParts[1:], "/")
 }
 
@@ -158,8 +345,12 @@ func (repo *LocalRepository) NonHostPath() string {
 // - "$GHQ_ROOT/github.com/motemen/ghq"
 // - "$GHQ_ROOT/github.com/motemen
 func (repo *LocalRepository) repoRootCandidates() []string {
-	hostRoot := filepath.Join(repo.RootPath, repo.PathParts[0])
-	nonHostParts := repo.PathParts[1:]
+	hostRoot := repo.RootPath
+	nonHostParts := repo.PathParts
+	if repo.Host() != "" {
+		hostRoot = filepath.Join(repo.RootPath, repo.PathParts[0])
+		nonHostParts = repo.PathParts[1:]
+	}
 	candidates := make([]string, len(nonHostParts))
 	for i := 0; i < len(nonHostParts); i++ {
 		candidates[i] = filepath.Join(append(
@@ -174,7 +365,9 @@ func (repo *LocalRepository) IsUnderPrimaryRoot() bool {
 	if err != nil {
 		return false
 	}
-	return strings.HasPrefix(repo.FullPath, prim)
+	// not by the prefix, which "/home/ghq2" has for "/home/ghq"
+	rel, err := filepath.Rel(prim, repo.FullPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
 }
 
 // Matches checks if any subpath of the local repository equals the query.
@@ -188,6 +381,204 @@ func (repo *LocalRepository) Matches(pathQuery string) bool {
 	return false
 }
 
+// MatchesExactly reports whether the query equals the whole path of the
+// repository, unlike Matches accepting any tail of it. The query qualified by
+// the host is compared with the path including the host, e.g.
+// "github.com/x-motemen/ghq", and the others with NonHostPath, e.g.
+// "x-motemen/ghq".
+func (repo *LocalRepository) MatchesExactly(pathQuery string) bool {
+	paths := strings.Split(pathQuery, "/")
+	if len(paths) > 1 && looksLikeAuthorityPattern.MatchString(paths[0]) {
+		if repo.Host() == "" {
+			// the host is unknown in the layout without hosts
+			return repo.NonHostPath() == strings.Join(paths[1:], "/")
+		}
+		return strings.Join(repo.PathParts, "/") == pathQuery
+	}
+	return repo.NonHostPath() == pathQuery
+}
+
+// IsBare reports whether the repository is a bare or mirror one without working tree
+func (repo *LocalRepository) IsBare() bool {
+	_, dir := repo.VCS()
+	return isBareGitRepository(dir)
+}
+
+// HasUnpushedCommits reports whether the git repository has commits which are
+// not pushed to its upstream. When the upstream is not set, the commits after
+// the latest tag, or all the commits if there is no tag, are regarded as
+// unpushed. It is always false for other VCSs.
+func (repo *LocalRepository) HasUnpushedCommits() (bool, error) {
+	vcs, dir := repo.VCS()
+	if vcs != GitBackend {
+		return false, nil
+	}
+	if out, err := outputInDir(dir, "git", "rev-list", "--count", "@{upstream}..HEAD"); err == nil {
+		return out != "0", nil
+	}
+	revs := "HEAD"
+	if tag, err := outputInDir(dir, "git", "describe", "--tags", "--abbrev=0"); err == nil {
+		revs = tag + "..HEAD"
+	}
+	out, err := outputInDir(dir, "git", "rev-list", "--count", revs)
+	if err != nil {
+		// HEAD is unborn in the repository without any commit
+		if headErr := cmdutil.RunInDirSilently(dir, "git", "rev-parse", "--verify", "HEAD"); headErr != nil {
+			return false, nil
+		}
+		return false, err
+	}
+	return out != "0", nil
+}
+
+// GitStatus represents the status of the working tree of a git repository
+type GitStatus struct {
+	// Branch is empty for the detached HEAD
+	Branch string
+	Dirty  bool
+	// Ahead and Behind are meaningful only if HasUpstream is true
+	Ahead, Behind int
+	HasUpstream   bool
+}
+
+// GitStatus returns the status of the working tree of the git repository.
+// nil is returned for other VCSs and bare repositories.
+func (repo *LocalRepository) GitStatus() (*GitStatus, error) {
+	vcs, dir := repo.VCS()
+	if vcs != GitBackend || repo.IsBare() {
+		return nil, nil
+	}
+	out, err := outputInDir(dir, "git", "status", "--porcelain=v2", "--branch")
+	if err != nil {
+		return nil, err
+	}
+	return parseGitStatus(out), nil
+}
+
+// parseGitStatus parses the output of `git status --porcelain=v2 --branch`
+func parseGitStatus(out string) *GitStatus {
+	st := &GitStatus{}
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "# ") {
+			st.Dirty = true
+			continue
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 3 && fields[1] == "branch.head":
+			if fields[2] != "(detached)" {
+				st.Branch = fields[2]
+			}
+		case len(fields) == 4 && fields[1] == "branch.ab":
+			// "# branch.ab +<ahead> -<behind>"
+			ahead, err1 := strconv.Atoi(strings.TrimPrefix(fields[2], "+"))
+			behind, err2 := strconv.Atoi(strings.TrimPrefix(fields[3], "-"))
+			if err1 == nil && err2 == nil {
+				st.Ahead, st.Behind, st.HasUpstream = ahead, behind, true
+			}
+		}
+	}
+	return st
+}
+
+// RemoteURL returns the URL of the remote repository which the repository is
+// cloned from. The "origin" remote, or the first one if renamed, is used for
+// git and the "default" path for Mercurial. Other VCSs are not supported.
+func (repo *LocalRepository) RemoteURL() (string, error) {
+	vcs, dir := repo.VCS()
+	switch vcs {
+	case GitBackend:
+		name, err := gitRemoteName(dir)
+		if err != nil {
+			return "", err
+		}
+		return outputInDir(dir, "git", "remote", "get-url", name)
+	case MercurialBackend:
+		return outputInDir(dir, "hg", "paths", "default")
+	}
+	return "", fmt.Errorf("reading the remote URL is not supported for %s", dir)
+}
+
+var bzrRelatedBranchReg = regexp.MustCompile(`(?m)^\s*(parent|push|submit|public) branch: (.+)$`)
+
+// Remotes returns the URLs of the remotes by their names: the remotes of git,
+// the paths of Mercurial and the related branches of Bazaar. The empty map is
+// returned for the other VCSs, which have no remotes.
+func (repo *LocalRepository) Remotes() (map[string]string, error) {
+	remotes := map[string]string{}
+	vcs, dir := repo.VCS()
+	switch vcs {
+	case GitBackend:
+		out, err := outputInDir(dir, "git", "remote", "-v")
+		if err != nil {
+			return nil, err
+		}
+		// "<name>\t<url> (fetch)"
+		for _, line := range strings.Split(out, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 3 && fields[2] == "(fetch)" {
+				remotes[fields[0]] = fields[1]
+			}
+		}
+	case MercurialBackend:
+		out, err := outputInDir(dir, "hg", "paths")
+		if err != nil {
+			return nil, err
+		}
+		// "<name> = <url>"
+		for _, line := range strings.Split(out, "\n") {
+			kv := strings.SplitN(line, " = ", 2)
+			if len(kv) == 2 {
+				remotes[kv[0]] = kv[1]
+			}
+		}
+	case BazaarBackend:
+		out, err := outputInDir(dir, "bzr", "info")
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range bzrRelatedBranchReg.FindAllStringSubmatch(out, -1) {
+			remotes[m[1]] = strings.TrimSpace(m[2])
+		}
+	}
+	return remotes, nil
+}
+
+// gitRemoteName returns the name of the remote which RemoteURL reads, that is
+// "origin" or the first one if renamed.
+func gitRemoteName(dir string) (string, error) {
+	out, err := outputInDir(dir, "git", "remote")
+	if err != nil {
+		return "", err
+	}
+	remotes := strings.Fields(out)
+	if len(remotes) == 0 {
+		return "", fmt.Errorf("no remote is configured in %s", dir)
+	}
+	for _, r := range remotes {
+		if r == "origin" {
+			return r, nil
+		}
+	}
+	return remotes[0], nil
+}
+
+// outputInDir runs the command in dir silently and returns its trimmed output
+func outputInDir(dir, command string, args ...string) (string, error) {
+	buf := &bytes.Buffer{}
+	cmd := exec.Command(command, args...)
+	cmd.Dir = dir
+	cmd.Stdout = buf
+	cmd.Stderr = ioutil.Discard
+	if err := cmdutil.RunCommand(cmd, true); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
 // VCS returns VCSBackend of the repository
 func (repo *LocalRepository) VCS() (*VCSBackend, string) {
 	if repo.vcsBackend == nil {
@@ -211,10 +602,13 @@ var vcsContentsMap = map[string]*VCSBackend{
 	".bzr":           BazaarBackend,
 	".fslckout":      FossilBackend, // file
 	"_FOSSIL_":       FossilBackend, // file
+	".pijul":         PijulBackend,
 	"CVS/Repository": cvsDummyBackend,
 }
 
-var vcsContents = [...]string{
+// vcsContents is the list of the contents in the order of the detection. The
+// ones of the custom VCS backends are appended by loadCustomVCSBackends.
+var vcsContents = []string{
 	".git",
 	".hg",
 	".svn",
@@ -222,6 +616,7 @@ var vcsContents = [...]string{
 	".bzr",
 	".fslckout",
 	"._FOSSIL_",
+	".pijul",
 	"CVS/Repository",
 }
 
@@ -237,6 +632,9 @@ func findVCSBackend(fpath, vcs string) *VCSBackend {
 				return vcsBackend
 			}
 		}
+		if vcsBackend == GitBackend && isGitRepositoryWithoutDotGit(fpath) {
+			return vcsBackend
+		}
 		return nil
 	}
 	for _, d := range vcsContents {
@@ -244,24 +642,192 @@ func findVCSBackend(fpath, vcs string) *VCSBackend {
 			return vcsContentsMap[d]
 		}
 	}
+	if isGitRepositoryWithoutDotGit(fpath) {
+		return GitBackend
+	}
 	return nil
 }
 
+// isGitRepositoryWithoutDotGit detects git repositories having no .git in
+// the directory, i.e. bare ones and working trees whose git directory is
+// placed next to them.
+// (A working tree with a .git file, like `git clone --separate-git-dir` and
+// `git worktree add` make, is detected by the .git content as usual.)
+func isGitRepositoryWithoutDotGit(fpath string) bool {
+	return isBareGitRepository(fpath) || hasAdjacentGitDir(fpath)
+}
+
+var (
+	gitConfigBareReg     = regexp.MustCompile(`(?m)^\s*bare\s*=\s*true\s*$`)
+	gitConfigWorktreeReg = regexp.MustCompile(`(?m)^\s*worktree\s*=\s*"?(.+?)"?\s*$`)
+)
+
+// isGitDir reports whether fpath looks like a git directory, which has HEAD,
+// objects/ and refs/ directly under it.
+func isGitDir(fpath string) bool {
+	if fi, err := os.Stat(filepath.Join(fpath, "HEAD")); err != nil || !fi.Mode().IsRegular() {
+		return false
+	}
+	for _, d := range []string{"objects", "refs"} {
+		if fi, err := os.Stat(filepath.Join(fpath, d)); err != nil || !fi.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// isBareGitRepository reports whether fpath is a bare (or mirror) git
+// repository, which is a git directory configured with core.bare = true.
+func isBareGitRepository(fpath string) bool {
+	if !isGitDir(fpath) {
+		return false
+	}
+	b, err := ioutil.ReadFile(filepath.Join(fpath, "config"))
+	return err == nil && gitConfigBareReg.Match(b)
+}
+
+// hasAdjacentGitDir reports whether fpath is a working tree whose git
+// directory is placed next to it as "<fpath>.git" with core.worktree.
+func hasAdjacentGitDir(fpath string) bool {
+	gitDir := fpath + ".git"
+	if !isGitDir(gitDir) {
+		return false
+	}
+	b, err := ioutil.ReadFile(filepath.Join(gitDir, "config"))
+	if err != nil {
+		return false
+	}
+	m := gitConfigWorktreeReg.FindSubmatch(b)
+	if m == nil {
+		return false
+	}
+	worktree := string(m[1])
+	if !filepath.IsAbs(worktree) {
+		worktree = filepath.Join(gitDir, worktree)
+	}
+	return filepath.Clean(worktree) == filepath.Clean(fpath)
+}
+
 func walkAllLocalRepositories(callback func(*LocalRepository)) error {
-	return walkLocalRepositories("", callback)
+	return walkLocalRepositories("", walkOptions{}, callback)
+}
+
+// walkOptions changes how walkLocalRepositories walks the roots
+type walkOptions struct {
+	// noCache disables the repository index, e.g. by `ghq list --no-cache`
+	noCache bool
+	// followSymlinksDeep makes it descend into the directories linked by
+	// symlinks as well, e.g. by `ghq list --follow-symlinks-deep`. Only the
+	// symlinks to repositories are followed by default.
+	followSymlinksDeep bool
+}
+
+// collectLocalRepositories returns the local repositories of the VCS (all of
+// them if vcs is empty) accepted by the filter (or all if it is nil), sorted
+// by their full paths. The filter is called concurrently while walking as the
+// callback of walkLocalRepositories, which is for streaming them instead.
+func collectLocalRepositories(vcs string, filter func(*LocalRepository) bool) ([]*LocalRepository, error) {
+	var (
+		repos []*LocalRepository
+		mu    sync.Mutex
+	)
+	if err := walkLocalRepositories(vcs, walkOptions{}, func(repo *LocalRepository) {
+		if filter != nil && !filter(repo) {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		repos = append(repos, repo)
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].FullPath < repos[j].FullPath })
+	return repos, nil
 }
 
-func walkLocalRepositories(vcs string, callback func(*LocalRepository)) error {
+func walkLocalRepositories(vcs string, opts walkOptions, callback func(*LocalRepository)) error {
 	roots, err := localRepositoryRoots(true)
 	if err != nil {
 		return err
 	}
 
-	walkFn := func(fpath string, fi os.FileInfo) error {
+	maxDepth, err := walkMaxDepth()
+	if err != nil {
+		return err
+	}
+
+	// The index is used only for walking all the repositories since the
+	// directories walked into depend on the vcs. The directories linked by
+	// symlinks are not recorded, so it is not used to follow them either.
+	var idx *repositoryIndex
+	if vcs == "" && !opts.noCache && !opts.followSymlinksDeep {
+		if cached := loadRepositoryIndex(roots); cached != nil && cached.MaxDepth == maxDepth {
+			// the callback is called concurrently as well as walking
+			var (
+				wg       sync.WaitGroup
+				sem      = make(chan struct{}, runtime.NumCPU())
+				backends = indexedVCSBackends()
+			)
+			for _, r := range cached.Repos {
+				repo, err := LocalRepositoryFromFullPath(r.Path, backends[r.VCS])
+				if err != nil || repo == nil {
+					continue
+				}
+				wg.Add(1)
+				sem <- struct{}{}
+				go func() {
+					defer func() { <-sem; wg.Done() }()
+					callback(repo)
+				}()
+			}
+			wg.Wait()
+			return nil
+		}
+		idx = newRepositoryIndex(roots)
+		idx.MaxDepth = maxDepth
+	}
+
+	ignores := map[string]ignorePatterns{}
+	for _, root := range roots {
+		// the roots which are not directories are warned below
+		if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+			continue
+		}
+		if ignores[root], err = loadIgnorePatterns(root); err != nil {
+			return err
+		}
+	}
+
+	var (
+		visited   = map[string]bool{} // the directories followed for --follow-symlinks-deep
+		visitedMu sync.Mutex
+		walkFn    func(root, fpath string, fi os.FileInfo) error
+	)
+	// followSymlink walks the directory linked by the symlink at fpath, with
+	// the paths under fpath. The directories already walked are skipped not to
+	// loop.
+	followSymlink := func(root, fpath, realpath string) error {
+		visitedMu.Lock()
+		seen := visited[realpath]
+		visited[realpath] = true
+		visitedMu.Unlock()
+		if seen {
+			return nil
+		}
+		return walker.Walk(realpath, func(p string, fi os.FileInfo) error {
+			if p == realpath {
+				return nil
+			}
+			return walkFn(root, fpath+strings.TrimPrefix(p, realpath), fi)
+		})
+	}
+	walkFn = func(root, fpath string, fi os.FileInfo) error {
 		isSymlink := false
+		realpath := fpath
 		if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
 			isSymlink = true
-			realpath, err := filepath.EvalSymlinks(fpath)
+			var err error
+			realpath, err = filepath.EvalSymlinks(fpath)
 			if err != nil {
 				return nil
 			}
@@ -273,8 +839,26 @@ func walkLocalRepositories(vcs string, callback func(*LocalRepository)) error {
 		if !fi.IsDir() {
 			return nil
 		}
+		// the ignored directories are skipped even if they are repositories
+		if ignores[root].match(root, fpath) {
+			return filepath.SkipDir
+		}
 		vcsBackend := findVCSBackend(fpath, vcs)
 		if vcsBackend == nil {
+			// skip the contents of git directories of non-bare repositories
+			if isGitDir(fpath) {
+				return filepath.SkipDir
+			}
+			if !isSymlink {
+				idx.addDir(fpath, fi)
+			}
+			// the repositories are not looked for below the max depth
+			if maxDepth > 0 && walkDepth(root, fpath) >= maxDepth {
+				return filepath.SkipDir
+			}
+			if isSymlink && opts.followSymlinksDeep {
+				return followSymlink(root, fpath, realpath)
+			}
 			return nil
 		}
 
@@ -282,6 +866,7 @@ func walkLocalRepositories(vcs string, callback func(*LocalRepository)) error {
 		if err != nil || repo == nil {
 			return nil
 		}
+		idx.addRepo(fpath, vcsBackend)
 		callback(repo)
 
 		if isSymlink {
@@ -309,13 +894,43 @@ func walkLocalRepositories(vcs string, callback func(*LocalRepository)) error {
 			logger.Log("warning", fmt.Sprintf("%s: Permission denied", root))
 			continue
 		}
-		if err := walker.Walk(root, walkFn, errCb); err != nil {
+		root := root
+		fn := func(fpath string, fi os.FileInfo) error {
+			return walkFn(root, fpath, fi)
+		}
+		if err := walker.Walk(root, fn, errCb); err != nil {
 			return err
 		}
 	}
+	if err := idx.save(); err != nil {
+		logger.Log("warning", fmt.Sprintf("failed to save the repository index: %s", err))
+	}
 	return nil
 }
 
+// walkMaxDepth returns the maximum depth of the directories walked into
+// relative to each root, configured by ghq.root.maxDepth. 0 means unlimited.
+func walkMaxDepth() (int, error) {
+	n, err := gitconfig.Int("ghq.root.maxDepth")
+	if err != nil && !gitconfig.IsNotFound(err) {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("invalid ghq.root.maxDepth: %d", n)
+	}
+	return n, nil
+}
+
+// walkDepth returns the depth of the path relative to the root, e.g. 3 for
+// $root/github.com/x-motemen/ghq.
+func walkDepth(root, fpath string) int {
+	rel, err := filepath.Rel(root, fpath)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return len(strings.Split(rel, string(filepath.Separator)))
+}
+
 var (
 	_home    string
 	_homeErr error
@@ -346,9 +961,9 @@ var (
 func localRepositoryRoots(all bool) ([]string, error) {
 	localRepoOnce.Do(func() {
 		var roots []string
-		envRoot := os.Getenv(envGhqRoot)
-		if envRoot != "" {
-			roots = filepath.SplitList(envRoot)
+		envRoots := envRoot()
+		if envRoots != "" {
+			roots = filepath.SplitList(envRoots)
 		} else {
 			var err error
 			roots, err = gitconfig.PathAll("ghq.root")
@@ -373,7 +988,7 @@ func localRepositoryRoots(all bool) ([]string, error) {
 			roots = []string{filepath.Join(homeDir, "ghq")}
 		}
 
-		if all && envRoot == "" {
+		if all && envRoots == "" {
 			localRoots, err := urlMatchLocalRepositoryRoots()
 			if err != nil {
 				_localRepoErr = err
@@ -432,3 +1047,56 @@ func primaryLocalRepositoryRoot() (string, error) {
 	}
 	return roots[0], nil
 }
+
+// RootInfo represents a root directory and its status
+type RootInfo struct {
+	Path     string `json:"path"`
+	Exists   bool   `json:"exists"`
+	Writable bool   `json:"writable"`
+	Primary  bool   `json:"primary"`
+	// RepoCount is filled only when requested since it walks the roots
+	RepoCount *int `json:"repo_count,omitempty"`
+}
+
+// localRepositoryRootInfos returns all the roots with their status.
+// If withCounts is true, the repositories under each root are counted.
+func localRepositoryRootInfos(withCounts bool) ([]*RootInfo, error) {
+	roots, err := localRepositoryRoots(true)
+	if err != nil {
+		return nil, err
+	}
+	prim, err := primaryLocalRepositoryRoot()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]*RootInfo, len(roots))
+	for i, root := range roots {
+		info := &RootInfo{
+			Path:    root,
+			Primary: root == prim,
+		}
+		if fi, err := os.Stat(root); err == nil && fi.IsDir() {
+			info.Exists = true
+			info.Writable = isWritable(root)
+		}
+		infos[i] = info
+	}
+	if withCounts {
+		repos, err := collectLocalRepositories("", nil)
+		if err != nil {
+			return nil, err
+		}
+		counts := make([]int, len(infos))
+		for _, repo := range repos {
+			for i, info := range infos {
+				if repo.RootPath == info.Path {
+					counts[i]++
+				}
+			}
+		}
+		for i := range infos {
+			infos[i].RepoCount = &counts[i]
+		}
+	}
+	return infos, nil
+}
@@ -1,15 +1,21 @@
 package main
 
 import (
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
 	"runtime"
 	"sort"
+	"strings"
 	"sync"
 	"testing"
 
 	"github.com/Songmu/gitconfig"
+	"github.com/x-motemen/ghq/cmdutil"
 )
 
 func samePathSlice(lhss, rhss []string) bool {
@@ -122,6 +128,31 @@ func TestNewLocalRepository(t *testing.T) {
 	}
 }
 
+func TestLocalRepository_MatchesExactly(t *testing.T) {
+	repo := &LocalRepository{
+		RelPath:   filepath.Join("github.com", "x-motemen", "ghq"),
+		PathParts: []string{"github.com", "x-motemen", "ghq"},
+	}
+	testCases := []struct {
+		query  string
+		expect bool
+	}{
+		{"github.com/x-motemen/ghq", true},
+		{"x-motemen/ghq", true},
+		{"ghq", false},
+		{"motemen/ghq", false},
+		{"gitlab.com/x-motemen/ghq", false},
+		{"github.com/x-motemen", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			if got := repo.MatchesExactly(tc.query); got != tc.expect {
+				t.Errorf("got: %t, expect: %t", got, tc.expect)
+			}
+		})
+	}
+}
+
 func TestLocalRepositoryRoots(t *testing.T) {
 	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
 	defer func(orig string) { os.Setenv(envGhqRoot, orig) }(os.Getenv(envGhqRoot))
@@ -198,6 +229,168 @@ func TestList_Symlink(t *testing.T) {
 	}
 }
 
+func TestWalkLocalRepositories_maxDepth(t *testing.T) {
+	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
+	root := newTempDir(t)
+	defer os.RemoveAll(root)
+	_localRepositoryRoots = []string{root}
+	os.MkdirAll(filepath.Join(root, "github.com", "x-motemen", "ghq", ".git"), 0755)
+	os.MkdirAll(filepath.Join(root, "gitlab.com", "group", "subgroup", "repo", ".git"), 0755)
+
+	testCases := []struct {
+		name, config string
+		expect       []string
+	}{{
+		name:   "unlimited",
+		expect: []string{"github.com/x-motemen/ghq", "gitlab.com/group/subgroup/repo"},
+	}, {
+		name:   "3",
+		config: "[ghq \"root\"]\n  maxDepth = 3\n",
+		expect: []string{"github.com/x-motemen/ghq"},
+	}, {
+		name:   "4",
+		config: "[ghq \"root\"]\n  maxDepth = 4\n",
+		expect: []string{"github.com/x-motemen/ghq", "gitlab.com/group/subgroup/repo"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer gitconfig.WithConfig(t, tc.config)()
+			var (
+				paths []string
+				mu    sync.Mutex
+			)
+			if err := walkAllLocalRepositories(func(repo *LocalRepository) {
+				mu.Lock()
+				defer mu.Unlock()
+				paths = append(paths, filepath.ToSlash(repo.RelPath))
+			}); err != nil {
+				t.Fatalf("error should be nil but: %s", err)
+			}
+			sort.Strings(paths)
+			if !reflect.DeepEqual(paths, tc.expect) {
+				t.Errorf("got: %v, expect: %v", paths, tc.expect)
+			}
+		})
+	}
+
+	defer gitconfig.WithConfig(t, "[ghq \"root\"]\n  maxDepth = -1\n")()
+	if err := walkAllLocalRepositories(func(*LocalRepository) {}); err == nil {
+		t.Errorf("error should be occurred for the negative depth")
+	}
+}
+
+func TestCollectLocalRepositories(t *testing.T) {
+	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
+	root := newTempDir(t)
+	defer os.RemoveAll(root)
+	_localRepositoryRoots = []string{root}
+	for _, p := range []string{
+		"github.com/x-motemen/ghq/.git",
+		"github.com/motemen/gore/.git",
+		"github.com/motemen/ghq/.hg",
+		"example.com/motemen/ghq/.git",
+	} {
+		os.MkdirAll(filepath.Join(root, filepath.FromSlash(p)), 0755)
+	}
+
+	testCases := []struct {
+		name   string
+		vcs    string
+		filter func(*LocalRepository) bool
+		expect []string
+	}{{
+		name:   "all",
+		expect: []string{"example.com/motemen/ghq", "github.com/motemen/ghq", "github.com/motemen/gore", "github.com/x-motemen/ghq"},
+	}, {
+		name:   "vcs",
+		vcs:    "hg",
+		expect: []string{"github.com/motemen/ghq"},
+	}, {
+		name:   "filter",
+		filter: func(repo *LocalRepository) bool { return repo.Matches("ghq") },
+		expect: []string{"example.com/motemen/ghq", "github.com/motemen/ghq", "github.com/x-motemen/ghq"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repos, err := collectLocalRepositories(tc.vcs, tc.filter)
+			if err != nil {
+				t.Fatalf("error should be nil but: %s", err)
+			}
+			paths := make([]string, len(repos))
+			for i, repo := range repos {
+				paths[i] = filepath.ToSlash(repo.RelPath)
+			}
+			if !reflect.DeepEqual(paths, tc.expect) {
+				t.Errorf("got: %v, expect: %v", paths, tc.expect)
+			}
+		})
+	}
+}
+
+func TestWalkLocalRepositories_followSymlinksDeep(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.SkipNow()
+	}
+	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
+	root := newTempDir(t)
+	defer os.RemoveAll(root)
+	other := newTempDir(t)
+	defer os.RemoveAll(other)
+	_localRepositoryRoots = []string{root}
+
+	os.MkdirAll(filepath.Join(root, "github.com", "x-motemen", "ghq", ".git"), 0755)
+	os.MkdirAll(filepath.Join(other, "motemen", "gore", ".git"), 0755)
+	// the linked directory having the repositories, and a loop
+	os.Symlink(other, filepath.Join(root, "github.com", "linked"))
+	os.Symlink(other, filepath.Join(other, "loop"))
+
+	walk := func(opts walkOptions) []string {
+		var (
+			paths []string
+			mu    sync.Mutex
+		)
+		if err := walkLocalRepositories("", opts, func(repo *LocalRepository) {
+			mu.Lock()
+			defer mu.Unlock()
+			paths = append(paths, filepath.ToSlash(repo.RelPath))
+		}); err != nil {
+			t.Fatalf("error should be nil but: %s", err)
+		}
+		sort.Strings(paths)
+		return paths
+	}
+
+	if got, expect := walk(walkOptions{}), []string{"github.com/x-motemen/ghq"}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("got: %v, expect: %v", got, expect)
+	}
+	expect := []string{"github.com/linked/motemen/gore", "github.com/x-motemen/ghq"}
+	if got := walk(walkOptions{followSymlinksDeep: true}); !reflect.DeepEqual(got, expect) {
+		t.Errorf("got: %v, expect: %v", got, expect)
+	}
+}
+
+func TestWalkDepth(t *testing.T) {
+	root := filepath.FromSlash("/path/to/ghq")
+	testCases := []struct {
+		path   string
+		expect int
+	}{
+		{"/path/to/ghq", 0},
+		{"/path/to/ghq/github.com", 1},
+		{"/path/to/ghq/github.com/x-motemen/ghq", 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			if got := walkDepth(root, filepath.FromSlash(tc.path)); got != tc.expect {
+				t.Errorf("got: %d, expect: %d", got, tc.expect)
+			}
+		})
+	}
+}
+
 func TestList_Symlink_In_Same_Directory(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.SkipNow()
@@ -283,6 +476,26 @@ func TestFindVCSBackend(t *testing.T) {
 			}
 		},
 		expect: nil,
+	}, {
+		name: "pijul",
+		setup: func(t *testing.T) (string, string, func()) {
+			dir := newTempDir(t)
+			os.MkdirAll(filepath.Join(dir, ".pijul"), 0755)
+			return dir, "", func() {
+				os.RemoveAll(dir)
+			}
+		},
+		expect: PijulBackend,
+	}, {
+		name: "pijul with matched vcs",
+		setup: func(t *testing.T) (string, string, func()) {
+			dir := newTempDir(t)
+			os.MkdirAll(filepath.Join(dir, ".pijul"), 0755)
+			return dir, "pijul", func() {
+				os.RemoveAll(dir)
+			}
+		},
+		expect: PijulBackend,
 	}}
 
 	for _, tc := range testCases {
@@ -297,6 +510,29 @@ func TestFindVCSBackend(t *testing.T) {
 	}
 }
 
+func TestLocalRepository_IsUnderPrimaryRoot(t *testing.T) {
+	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
+	root := filepath.FromSlash("/home/ghq")
+	_localRepositoryRoots = []string{root}
+
+	testCases := []struct {
+		fpath  string
+		expect bool
+	}{
+		{"/home/ghq/github.com/motemen/ghq", true},
+		{"/home/ghq2/github.com/motemen/ghq", false},
+		{"/home/other/github.com/motemen/ghq", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.fpath, func(t *testing.T) {
+			repo := &LocalRepository{FullPath: filepath.FromSlash(tc.fpath)}
+			if got := repo.IsUnderPrimaryRoot(); got != tc.expect {
+				t.Errorf("got: %t, expect: %t", got, tc.expect)
+			}
+		})
+	}
+}
+
 func TestLocalRepository_VCS(t *testing.T) {
 	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
 	defer func(orig string) { os.Setenv(envGhqRoot, orig) }(os.Getenv(envGhqRoot))
@@ -375,3 +611,429 @@ func TestLocalRepositoryRoots_URLMatchLocalRepositoryRoots(t *testing.T) {
 		t.Errorf("localRepositoryRoots(true) = %+v, want: %+v", got, want)
 	}
 }
+
+func TestLocalRepositoryFromURL_multipleRoots(t *testing.T) {
+	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
+	tmp1 := newTempDir(t)
+	defer os.RemoveAll(tmp1)
+	tmp2 := newTempDir(t)
+	defer os.RemoveAll(tmp2)
+	_localRepositoryRoots = []string{tmp1, tmp2}
+	for _, root := range _localRepositoryRoots {
+		os.MkdirAll(filepath.Join(root, "github.com/motemen/ghq/.git"), 0755)
+	}
+	u := mustParseURL("https://github.com/motemen/ghq")
+
+	r, err := localRepositoryFromURL(u, "", false)
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if r.RootPath != tmp1 {
+		t.Errorf("repository under the primary root should be chosen, but: %s", r.FullPath)
+	}
+
+	r, err = localRepositoryFromURL(u, tmp2, false)
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if r.RootPath != tmp2 {
+		t.Errorf("repository under the specified root should be chosen, but: %s", r.FullPath)
+	}
+
+	if _, err := localRepositoryFromURL(u, "", true); err == nil {
+		t.Errorf("error should not be nil in strict mode")
+	}
+	if _, err := localRepositoryFromURL(u, tmp2, true); err != nil {
+		t.Errorf("error should be nil when the root is specified but: %s", err)
+	}
+	if _, err := localRepositoryFromURL(u, filepath.Join(tmp1, "unknown"), false); err == nil {
+		t.Errorf("error should not be nil for an unknown root")
+	}
+}
+
+func TestLocalRepository_subgroups(t *testing.T) {
+	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
+	tmproot := newTempDir(t)
+	defer os.RemoveAll(tmproot)
+	_localRepositoryRoots = []string{tmproot}
+
+	testCases := []struct {
+		name, url, expect string
+	}{{
+		name:   "two levels https",
+		url:    "https://gitlab.com/group/subgroup/project.git",
+		expect: "gitlab.com/group/subgroup/project",
+	}, {
+		name:   "two levels scp-like",
+		url:    "git@gitlab.com:group/subgroup/project.git",
+		expect: "gitlab.com/group/subgroup/project",
+	}, {
+		name:   "three levels https",
+		url:    "https://gitlab.com/group/subgroup/subsubgroup/project",
+		expect: "gitlab.com/group/subgroup/subsubgroup/project",
+	}, {
+		name:   "three levels scp-like",
+		url:    "git@gitlab.com:group/subgroup/subsubgroup/project.git",
+		expect: "gitlab.com/group/subgroup/subsubgroup/project",
+	}, {
+		name:   "three levels ssh",
+		url:    "ssh://git@gitlab.com/group/subgroup/subsubgroup/project.git",
+		expect: "gitlab.com/group/subgroup/subsubgroup/project",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := newURL(tc.url, false, false)
+			if err != nil {
+				t.Fatalf("error should be nil but: %s", err)
+			}
+			r, err := newLocalRepositoryFromURL(u, "")
+			if err != nil {
+				t.Fatalf("error should be nil but: %s", err)
+			}
+			expect := filepath.Join(tmproot, filepath.FromSlash(tc.expect))
+			if r.FullPath != expect {
+				t.Errorf("got: %s, expect: %s", r.FullPath, expect)
+			}
+			if parts := strings.Join(r.PathParts, "/"); parts != tc.expect {
+				t.Errorf("got: %s, expect: %s", parts, tc.expect)
+			}
+
+			// the repository root is found from its subdirectory
+			sub := filepath.Join(expect, "docs")
+			os.MkdirAll(filepath.Join(expect, ".git"), 0755)
+			os.MkdirAll(sub, 0755)
+			repo, err := LocalRepositoryFromFullPath(sub, nil)
+			if err != nil {
+				t.Fatalf("error should be nil but: %s", err)
+			}
+			vcs, repoPath := repo.VCS()
+			if vcs != GitBackend {
+				t.Errorf("repo.VCS() = %+v, expect: GitBackend", vcs)
+			}
+			if repoPath != expect {
+				t.Errorf("got: %s, expect: %s", repoPath, expect)
+			}
+		})
+	}
+}
+
+func TestFindVCSBackend_gitWithoutDotGit(t *testing.T) {
+	tmpdir := newTempDir(t)
+	defer os.RemoveAll(tmpdir)
+
+	makeGitDir := func(dir, config string) {
+		os.MkdirAll(filepath.Join(dir, "objects"), 0755)
+		os.MkdirAll(filepath.Join(dir, "refs"), 0755)
+		ioutil.WriteFile(filepath.Join(dir, "HEAD"), []byte("ref: refs/heads/master\n"), 0644)
+		ioutil.WriteFile(filepath.Join(dir, "config"), []byte(config), 0644)
+	}
+
+	bare := filepath.Join(tmpdir, "bare.git")
+	makeGitDir(bare, "[core]\n\tbare = true\n")
+
+	separated := filepath.Join(tmpdir, "separated")
+	os.MkdirAll(separated, 0755)
+	ioutil.WriteFile(filepath.Join(separated, ".git"),
+		[]byte("gitdir: "+filepath.Join(tmpdir, "separated-gitdir")+"\n"), 0644)
+
+	adjacent := filepath.Join(tmpdir, "adjacent")
+	os.MkdirAll(adjacent, 0755)
+	makeGitDir(adjacent+".git", "[core]\n\tbare = false\n\tworktree = ../adjacent\n")
+
+	headOnly := filepath.Join(tmpdir, "head-only")
+	os.MkdirAll(headOnly, 0755)
+	ioutil.WriteFile(filepath.Join(headOnly, "HEAD"), []byte("dummy\n"), 0644)
+
+	// the layout of git directories without core.bare, e.g. made by other tools
+	notBare := filepath.Join(tmpdir, "not-bare")
+	makeGitDir(notBare, "[core]\n\tbare = false\n")
+
+	objectsFile := filepath.Join(tmpdir, "objects-file")
+	makeGitDir(objectsFile, "[core]\n\tbare = true\n")
+	os.RemoveAll(filepath.Join(objectsFile, "objects"))
+	ioutil.WriteFile(filepath.Join(objectsFile, "objects"), []byte("dummy\n"), 0644)
+
+	testCases := []struct {
+		name   string
+		dir    string
+		detect bool
+	}{
+		{"bare", bare, true},
+		{"separate git dir", separated, true},
+		{"adjacent git dir", adjacent, true},
+		{"git dir of adjacent one", adjacent + ".git", false},
+		{"HEAD only", headOnly, false},
+		{"not bare", notBare, false},
+		{"objects is a file", objectsFile, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, vcs := range []string{"", "git"} {
+				backend := findVCSBackend(tc.dir, vcs)
+				if tc.detect && backend != GitBackend {
+					t.Errorf("findVCSBackend(%q, %q) should be GitBackend", tc.dir, vcs)
+				}
+				if !tc.detect && backend != nil {
+					t.Errorf("findVCSBackend(%q, %q) should be nil", tc.dir, vcs)
+				}
+			}
+		})
+	}
+}
+
+func TestLocalRepository_HasUnpushedCommits(t *testing.T) {
+	defer func(orig func(cmd *exec.Cmd) error) {
+		cmdutil.CommandRunner = orig
+	}(cmdutil.CommandRunner)
+
+	testCases := []struct {
+		name    string
+		vcs     *VCSBackend
+		outputs map[string]string // failing commands are omitted
+		expect  bool
+	}{{
+		name: "ahead of upstream",
+		vcs:  GitBackend,
+		outputs: map[string]string{
+			"git rev-list --count @{upstream}..HEAD": "2",
+		},
+		expect: true,
+	}, {
+		name: "up to date with upstream",
+		vcs:  GitBackend,
+		outputs: map[string]string{
+			"git rev-list --count @{upstream}..HEAD": "0",
+		},
+		expect: false,
+	}, {
+		name: "commits after the latest tag",
+		vcs:  GitBackend,
+		outputs: map[string]string{
+			"git describe --tags --abbrev=0":    "v1.0.0",
+			"git rev-list --count v1.0.0..HEAD": "1",
+		},
+		expect: true,
+	}, {
+		name: "tagged",
+		vcs:  GitBackend,
+		outputs: map[string]string{
+			"git describe --tags --abbrev=0":    "v1.0.0",
+			"git rev-list --count v1.0.0..HEAD": "0",
+		},
+		expect: false,
+	}, {
+		name: "no upstream and no tag",
+		vcs:  GitBackend,
+		outputs: map[string]string{
+			"git rev-list --count HEAD": "3",
+		},
+		expect: true,
+	}, {
+		name:    "no commit",
+		vcs:     GitBackend,
+		outputs: map[string]string{},
+		expect:  false,
+	}, {
+		name:    "mercurial",
+		vcs:     MercurialBackend,
+		outputs: map[string]string{},
+		expect:  false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+				out, ok := tc.outputs[strings.Join(cmd.Args, " ")]
+				if !ok {
+					return fmt.Errorf("[test] failed to %s", strings.Join(cmd.Args, " "))
+				}
+				_, err := io.WriteString(cmd.Stdout, out+"\n")
+				return err
+			}
+			repo := &LocalRepository{FullPath: "repo", repoPath: "repo", vcsBackend: tc.vcs}
+			got, err := repo.HasUnpushedCommits()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.expect {
+				t.Errorf("got: %t, expect: %t", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestLocalRepository_RemoteURL(t *testing.T) {
+	defer func(orig func(cmd *exec.Cmd) error) {
+		cmdutil.CommandRunner = orig
+	}(cmdutil.CommandRunner)
+
+	testCases := []struct {
+		name    string
+		vcs     *VCSBackend
+		outputs map[string]string
+		expect  string
+	}{{
+		name: "git origin",
+		vcs:  GitBackend,
+		outputs: map[string]string{
+			"git remote":                "mirror\norigin",
+			"git remote get-url origin": "https://github.com/x-motemen/ghq",
+		},
+		expect: "https://github.com/x-motemen/ghq",
+	}, {
+		name: "git renamed origin",
+		vcs:  GitBackend,
+		outputs: map[string]string{
+			"git remote":                "github",
+			"git remote get-url github": "https://github.com/x-motemen/ghq",
+		},
+		expect: "https://github.com/x-motemen/ghq",
+	}, {
+		name: "mercurial",
+		vcs:  MercurialBackend,
+		outputs: map[string]string{
+			"hg paths default": "https://hg.example.com/repo",
+		},
+		expect: "https://hg.example.com/repo",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+				out, ok := tc.outputs[strings.Join(cmd.Args, " ")]
+				if !ok {
+					return fmt.Errorf("[test] failed to %s", strings.Join(cmd.Args, " "))
+				}
+				_, err := io.WriteString(cmd.Stdout, out+"\n")
+				return err
+			}
+			repo := &LocalRepository{FullPath: "repo", repoPath: "repo", vcsBackend: tc.vcs}
+			got, err := repo.RemoteURL()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.expect {
+				t.Errorf("got: %s, expect: %s", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestLocalRepository_Remotes(t *testing.T) {
+	defer func(orig func(cmd *exec.Cmd) error) {
+		cmdutil.CommandRunner = orig
+	}(cmdutil.CommandRunner)
+
+	testCases := []struct {
+		name    string
+		vcs     *VCSBackend
+		outputs map[string]string
+		expect  map[string]string
+	}{{
+		name: "git",
+		vcs:  GitBackend,
+		outputs: map[string]string{
+			"git remote -v": "origin\thttps://github.com/x-motemen/ghq (fetch)\n" +
+				"origin\thttps://github.com/x-motemen/ghq (push)\n" +
+				"fork\tgit@github.com:motemen/ghq.git (fetch)\n" +
+				"fork\tgit@github.com:motemen/ghq.git (push)",
+		},
+		expect: map[string]string{
+			"origin": "https://github.com/x-motemen/ghq",
+			"fork":   "git@github.com:motemen/ghq.git",
+		},
+	}, {
+		name:    "git without remotes",
+		vcs:     GitBackend,
+		outputs: map[string]string{"git remote -v": ""},
+		expect:  map[string]string{},
+	}, {
+		name: "mercurial",
+		vcs:  MercurialBackend,
+		outputs: map[string]string{
+			"hg paths": "default = https://hg.example.com/repo\nfork = ssh://hg@hg.example.com/fork",
+		},
+		expect: map[string]string{
+			"default": "https://hg.example.com/repo",
+			"fork":    "ssh://hg@hg.example.com/fork",
+		},
+	}, {
+		name: "bazaar",
+		vcs:  BazaarBackend,
+		outputs: map[string]string{
+			"bzr info": "Standalone tree (format: 2a)\nLocation:\n  branch root: .\n\n" +
+				"Related branches:\n    push branch: bzr+ssh://bzr.example.com/push\n  parent branch: https://bzr.example.com/repo",
+		},
+		expect: map[string]string{
+			"push":   "bzr+ssh://bzr.example.com/push",
+			"parent": "https://bzr.example.com/repo",
+		},
+	}, {
+		name:   "darcs",
+		vcs:    DarcsBackend,
+		expect: map[string]string{},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+				out, ok := tc.outputs[strings.Join(cmd.Args, " ")]
+				if !ok {
+					return fmt.Errorf("[test] failed to %s", strings.Join(cmd.Args, " "))
+				}
+				_, err := io.WriteString(cmd.Stdout, out+"\n")
+				return err
+			}
+			repo := &LocalRepository{FullPath: "repo", repoPath: "repo", vcsBackend: tc.vcs}
+			got, err := repo.Remotes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.expect) {
+				t.Errorf("got: %v, expect: %v", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestParseGitStatus(t *testing.T) {
+	testCases := []struct {
+		name   string
+		out    string
+		expect *GitStatus
+	}{{
+		name: "clean with upstream",
+		out: "# branch.oid 0123456789abcdef0123456789abcdef01234567\n" +
+			"# branch.head master\n" +
+			"# branch.upstream origin/master\n" +
+			"# branch.ab +2 -1\n",
+		expect: &GitStatus{Branch: "master", Ahead: 2, Behind: 1, HasUpstream: true},
+	}, {
+		name: "dirty without upstream",
+		out: "# branch.oid 0123456789abcdef0123456789abcdef01234567\n" +
+			"# branch.head feature\n" +
+			"1 .M N... 100644 100644 100644 0123456 0123456 README.md\n" +
+			"? new.txt\n",
+		expect: &GitStatus{Branch: "feature", Dirty: true},
+	}, {
+		name: "detached",
+		out: "# branch.oid 0123456789abcdef0123456789abcdef01234567\n" +
+			"# branch.head (detached)\n",
+		expect: &GitStatus{},
+	}, {
+		name: "unborn",
+		out: "# branch.oid (initial)\n" +
+			"# branch.head master\n",
+		expect: &GitStatus{Branch: "master"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parseGitStatus(tc.out)
+			if !reflect.DeepEqual(got, tc.expect) {
+				t.Errorf("got: %+v, expect: %+v", got, tc.expect)
+			}
+		})
+	}
+}
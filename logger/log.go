@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"sync"
 
 	"github.com/motemen/go-colorine"
 )
@@ -16,12 +18,17 @@ var logger = colorine.NewLogger(
 		"darcs":    colorine.Verbose,
 		"bzr":      colorine.Verbose,
 		"fossil":   colorine.Verbose,
+		"pijul":    colorine.Verbose,
 		"skip":     colorine.Verbose,
 		"cd":       colorine.Verbose,
 		"resolved": colorine.Verbose,
 
 		"open":    colorine.Warn,
 		"exists":  colorine.Warn,
+		"replace": colorine.Warn,
+		"remove":  colorine.Warn,
+		"rewrite": colorine.Warn,
+		"retry":   colorine.Warn,
 		"warning": colorine.Warn,
 
 		"authorized": colorine.Notice,
@@ -38,9 +45,39 @@ func SetOutput(w io.Writer) {
 	logger.SetOutput(w)
 }
 
+var (
+	secrets   []string
+	secretsMu sync.RWMutex
+)
+
+// AddSecret registers the secret, e.g. a token, to be masked in the logs
+func AddSecret(secret string) {
+	if secret == "" {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, s := range secrets {
+		if s == secret {
+			return
+		}
+	}
+	secrets = append(secrets, secret)
+}
+
+// Redact masks the registered secrets in the message
+func Redact(message string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for _, s := range secrets {
+		message = strings.ReplaceAll(message, s, "***")
+	}
+	return message
+}
+
 // Log output
 func Log(prefix, message string) {
-	logger.Log(prefix, message)
+	logger.Log(prefix, Redact(message))
 }
 
 // Logf output log with format
@@ -10,3 +10,27 @@ func TestLog(t *testing.T) {
 	Log("skip", "shows this color")
 	Log("git", "shows this color")
 }
+
+func TestRedact(t *testing.T) {
+	defer func(orig []string) { secrets = orig }(secrets)
+	AddSecret("")
+	AddSecret("s3cr3t")
+	AddSecret("s3cr3t")
+	if len(secrets) != 1 {
+		t.Errorf("secrets should be registered once, but: %v", secrets)
+	}
+
+	testCases := []struct {
+		message string
+		expect  string
+	}{
+		{"git -c http.extraHeader=Authorization: Basic s3cr3t clone", "git -c http.extraHeader=Authorization: Basic *** clone"},
+		{"s3cr3t and s3cr3t", "*** and ***"},
+		{"nothing secret", "nothing secret"},
+	}
+	for _, tc := range testCases {
+		if got := Redact(tc.message); got != tc.expect {
+			t.Errorf("got: %q, expect: %q", got, tc.expect)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/urfave/cli/v2"
 	"github.com/x-motemen/ghq/logger"
@@ -14,11 +15,11 @@ var revision = "HEAD"
 
 func main() {
 	if err := newApp().Run(os.Args); err != nil {
-		exitCode := 1
-		if excoder, ok := err.(cli.ExitCoder); ok {
-			exitCode = excoder.ExitCode()
+		exitCode := exitStatus(err)
+		// the error without message only conveys the exit code, e.g. of ghq look
+		if msg := err.Error(); msg != "" {
+			logger.Log("error", msg)
 		}
-		logger.Log("error", err.Error())
 		os.Exit(exitCode)
 	}
 }
@@ -35,6 +36,55 @@ func newApp() *cli.App {
 		Name:  "Songmu",
 		Email: "y.songmu@gmail.com",
 	}}
+	app.Flags = []cli.Flag{
+		&cli.StringFlag{Name: "root", Usage: "Use `path` as the only root, overriding GHQ_ROOT and ghq.root"},
+	}
+	app.Before = func(c *cli.Context) error {
+		if err := overrideRoot(c.String("root")); err != nil {
+			return err
+		}
+		return loadCustomVCSBackends()
+	}
 	app.Commands = commands
+	// the exit status is decided by main, not to exit in the middle of Run
+	app.ExitErrHandler = func(*cli.Context, error) {}
+	app.OnUsageError = onUsageError
+	for _, cmd := range app.Commands {
+		cmd.OnUsageError = onUsageError
+	}
 	return app
 }
+
+// onUsageError shows the help as usual for invalid flags, and makes them
+// exit with exitStatusUsage.
+func onUsageError(c *cli.Context, err error, _ bool) error {
+	fmt.Fprintf(c.App.Writer, "Incorrect Usage: %s\n\n", err)
+	if c.Command.Name == "" {
+		cli.ShowAppHelp(c)
+	} else {
+		cli.ShowCommandHelp(c, c.Command.Name)
+	}
+	return &exitError{status: exitStatusUsage, err: err}
+}
+
+// overrideRoot makes the root the only one for this invocation, taking
+// precedence over GHQ_ROOT and the configuration. It is reset without root.
+func overrideRoot(root string) error {
+	rootOverride = ""
+	if root == "" {
+		return nil
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(root)
+	if err != nil {
+		return usageErrorf("invalid --root: %w", err)
+	}
+	if !fi.IsDir() {
+		return usageErrorf("invalid --root: %s is not a directory", root)
+	}
+	rootOverride = root
+	return nil
+}
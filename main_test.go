@@ -1,7 +1,10 @@
 package main
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/Songmu/gitconfig"
@@ -9,7 +12,52 @@ import (
 
 func TestMain(m *testing.M) {
 	teardown := gitconfig.WithConfig(nil, "")
+	// not to touch the repository index of the user
+	tmpd, err := ioutil.TempDir("", "ghq-cache-")
+	if err != nil {
+		panic(err)
+	}
+	repositoryIndexPath = func() (string, error) {
+		return filepath.Join(tmpd, "index"), nil
+	}
 	code := m.Run()
+	os.RemoveAll(tmpd)
 	teardown()
 	os.Exit(code)
 }
+
+func TestGlobalRoot(t *testing.T) {
+	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
+	defer func(orig *sync.Once) { localRepoOnce = orig }(localRepoOnce)
+	defer tmpEnv(envGhqRoot, "/path/to/ghqroot")()
+	defer func() { rootOverride = "" }()
+	tmpd := newTempDir(t)
+	defer os.RemoveAll(tmpd)
+	os.MkdirAll(filepath.Join(tmpd, "github.com", "x-motemen", "ghq", ".git"), 0755)
+
+	_localRepositoryRoots = nil
+	localRepoOnce = &sync.Once{}
+	out, _, _ := capture(func() {
+		newApp().Run([]string{"", "--root", tmpd, "root", "--all"})
+	})
+	if out != tmpd+"\n" {
+		t.Errorf("got: %q, expect: %q", out, tmpd+"\n")
+	}
+	out, _, _ = capture(func() {
+		newApp().Run([]string{"", "--root", tmpd, "list"})
+	})
+	if expect := "github.com/x-motemen/ghq\n"; out != expect {
+		t.Errorf("got: %q, expect: %q", out, expect)
+	}
+	if got := os.Getenv(envGhqRoot); got != "/path/to/ghqroot" {
+		t.Errorf("%s should not be changed, but: %q", envGhqRoot, got)
+	}
+
+	file := filepath.Join(tmpd, "file")
+	ioutil.WriteFile(file, nil, 0644)
+	for _, root := range []string{file, filepath.Join(tmpd, "unknown")} {
+		if err := newApp().Run([]string{"", "--root", root, "root"}); err == nil {
+			t.Errorf("error should be occurred for %s", root)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/x-motemen/ghq/cmdutil"
+)
+
+// batchNotifier sends a notification summarizing a batch, e.g. of
+// `ghq get --notify`, once it finishes.
+type batchNotifier struct {
+	title     string
+	enabled   bool
+	succeeded int32
+	once      sync.Once
+}
+
+func (n *batchNotifier) succeed() {
+	atomic.AddInt32(&n.succeeded, 1)
+}
+
+// finish sends the notification with the error of the batch if any. It does
+// nothing when disabled or called more than once.
+func (n *batchNotifier) finish(err error) {
+	if !n.enabled {
+		return
+	}
+	n.once.Do(func() {
+		msg := fmt.Sprintf("Got %d repositories", atomic.LoadInt32(&n.succeeded))
+		if err != nil {
+			msg += ", but " + err.Error()
+		}
+		notify(n.title, msg)
+	})
+}
+
+// notify sends a desktop notification. Failures are ignored silently since
+// the notification is just a nicety. It is a variable to be replaced in the
+// tests.
+var notify = func(title, message string) {
+	args := notifyCommand(runtime.GOOS, title, message)
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return
+	}
+	cmdutil.RunSilently(args[0], args[1:]...)
+}
+
+// notifyCommand returns the command line to send a notification on the OS:
+// osascript on macOS, PowerShell on Windows and notify-send on the others.
+func notifyCommand(goos, title, message string) []string {
+	switch goos {
+	case "darwin":
+		return []string{"osascript", "-e", fmt.Sprintf("display notification %s with title %s",
+			appleScriptString(message), appleScriptString(title))}
+	case "windows":
+		return []string{"powershell", "-NoProfile", "-NonInteractive", "-Command", windowsToastScript(title, message)}
+	}
+	return []string{"notify-send", title, message}
+}
+
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func windowsToastScript(title, message string) string {
+	quote := func(s string) string {
+		return "'" + strings.Replace(s, "'", "''", -1) + "'"
+	}
+	return `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null;` +
+		`$t = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02);` +
+		`$n = $t.GetElementsByTagName('text');` +
+		`$n.Item(0).AppendChild($t.CreateTextNode(` + quote(title) + `)) > $null;` +
+		`$n.Item(1).AppendChild($t.CreateTextNode(` + quote(message) + `)) > $null;` +
+		`[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('ghq').Show([Windows.UI.Notifications.ToastNotification]::new($t))`
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBatchNotifier(t *testing.T) {
+	defer func(orig func(string, string)) { notify = orig }(notify)
+	var notified []string
+	notify = func(title, message string) {
+		notified = append(notified, title+": "+message)
+	}
+
+	n := &batchNotifier{title: "ghq get"}
+	n.succeed()
+	n.finish(nil)
+	if len(notified) != 0 {
+		t.Errorf("nothing should be notified when disabled, but: %v", notified)
+	}
+
+	n = &batchNotifier{title: "ghq get", enabled: true}
+	n.succeed()
+	n.succeed()
+	n.finish(errors.New("failed to get 1 repositories"))
+	n.finish(nil)
+	expect := []string{"ghq get: Got 2 repositories, but failed to get 1 repositories"}
+	if !reflect.DeepEqual(notified, expect) {
+		t.Errorf("got: %v, expect: %v", notified, expect)
+	}
+}
+
+func TestNotifyCommand(t *testing.T) {
+	testCases := []struct {
+		goos   string
+		expect string
+	}{{
+		goos:   "linux",
+		expect: "notify-send",
+	}, {
+		goos:   "darwin",
+		expect: `osascript -e display notification "failed to get \"a\\b\"" with title "ghq get"`,
+	}, {
+		goos:   "windows",
+		expect: "powershell",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.goos, func(t *testing.T) {
+			got := strings.Join(notifyCommand(tc.goos, "ghq get", `failed to get "a\b"`), " ")
+			if !strings.HasPrefix(got, tc.expect) {
+				t.Errorf("got: %s, expect to start with: %s", got, tc.expect)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// progress prints a line per repository for `ghq get --silent` in place of
+// the outputs of the VCS commands, e.g. "[3/50] clone github.com/foo/bar ... done".
+// The lines are printed as a whole when the repositories are done, so that
+// they do not interleave in parallel.
+type progress struct {
+	w     io.Writer
+	total int // 0 if unknown, e.g. reading the repositories from stdin
+	done  int
+	mu    sync.Mutex
+}
+
+// repoProgress records what is done to a repository for the progress line
+type repoProgress struct {
+	action string
+}
+
+func (p *progress) report(target string, rp *repoProgress, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	count := strconv.Itoa(p.done)
+	if p.total > 0 {
+		count += "/" + strconv.Itoa(p.total)
+	}
+	action := rp.action
+	if action == "" {
+		action = "get"
+	}
+	result := "done"
+	if err != nil {
+		result = "failed"
+	}
+	fmt.Fprintf(p.w, "[%s] %s %s ... %s\n", count, action, target, result)
+}
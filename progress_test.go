@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestProgress_report(t *testing.T) {
+	testCases := []struct {
+		name   string
+		total  int
+		expect string
+	}{{
+		name:  "total",
+		total: 2,
+		expect: "[1/2] clone github.com/x-motemen/ghq ... done\n" +
+			"[2/2] get github.com/x-motemen/unknown ... failed\n",
+	}, {
+		name:  "stdin",
+		total: 0,
+		expect: "[1] clone github.com/x-motemen/ghq ... done\n" +
+			"[2] get github.com/x-motemen/unknown ... failed\n",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			p := &progress{w: buf, total: tc.total}
+			p.report("github.com/x-motemen/ghq", &repoProgress{action: "clone"}, nil)
+			p.report("github.com/x-motemen/unknown", &repoProgress{}, errors.New("not found"))
+			if got := buf.String(); got != tc.expect {
+				t.Errorf("got: %q, expect: %q", got, tc.expect)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"net/url"
+	"os"
 	"regexp"
 	"strings"
 
@@ -114,6 +115,41 @@ func (repo *CodeCommitRepository) VCS() (*VCSBackend, *url.URL, error) {
 	return GitBackend, &u, nil
 }
 
+// A LocalPathRepository represents a repository in the local filesystem given
+// by a file URL or a path. Implements RemoteRepository.
+type LocalPathRepository struct {
+	url *url.URL
+}
+
+// URL returns URL of the repository
+func (repo *LocalPathRepository) URL() *url.URL {
+	return repo.url
+}
+
+// IsValid determine if the repository is valid or not
+func (repo *LocalPathRepository) IsValid() bool {
+	fi, err := os.Stat(fileURLPath(repo.url))
+	return err == nil && fi.IsDir()
+}
+
+// VCS detects VCSBackend from the contents of the directory. The URL to clone
+// from is the path itself except for Git and Subversion, since the other VCSs
+// do not necessarily accept file URLs.
+func (repo *LocalPathRepository) VCS() (*VCSBackend, *url.URL, error) {
+	dir := fileURLPath(repo.url)
+	vcs := findVCSBackend(dir, "")
+	switch vcs {
+	case nil:
+		return nil, nil, fmt.Errorf("no repository found in %s", dir)
+	case GitBackend, SubversionBackend:
+		return vcs, repo.URL(), nil
+	case GitsvnBackend:
+		// the clone of git-svn is a git repository itself
+		return GitBackend, repo.URL(), nil
+	}
+	return vcs, &url.URL{Path: dir}, nil
+}
+
 // OtherRepository represents other repository
 type OtherRepository struct {
 	url *url.URL
@@ -190,6 +226,9 @@ func NewRemoteRepository(u *url.URL) (RemoteRepository, error) {
 		if u.Scheme == "codecommit" {
 			return &CodeCommitRepository{u}
 		}
+		if u.Scheme == "file" {
+			return &LocalPathRepository{u}
+		}
 		switch u.Host {
 		case "github.com":
 			return &GitHubRepository{u}
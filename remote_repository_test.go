@@ -1,6 +1,10 @@
 package main
 
 import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -117,3 +121,85 @@ func TestNewRemoteRepository_error(t *testing.T) {
 		})
 	}
 }
+
+func TestLocalPathRepository(t *testing.T) {
+	tmpd := newTempDir(t)
+	defer os.RemoveAll(tmpd)
+	for _, d := range []string{"git/.git", "gitsvn/.git/svn", "hg/.hg", "darcs/_darcs", "plain"} {
+		os.MkdirAll(filepath.Join(tmpd, d), 0755)
+	}
+
+	testCases := []struct {
+		name       string
+		path       string
+		valid      bool
+		vcsBackend *VCSBackend
+		fileURL    bool
+	}{{
+		name:       "git",
+		path:       "git",
+		valid:      true,
+		vcsBackend: GitBackend,
+		fileURL:    true,
+	}, {
+		name:       "git-svn",
+		path:       "gitsvn",
+		valid:      true,
+		vcsBackend: GitBackend,
+		fileURL:    true,
+	}, {
+		name:       "mercurial",
+		path:       "hg",
+		valid:      true,
+		vcsBackend: MercurialBackend,
+	}, {
+		name:       "darcs",
+		path:       "darcs",
+		valid:      true,
+		vcsBackend: DarcsBackend,
+	}, {
+		name:  "not a repository",
+		path:  "plain",
+		valid: true,
+	}, {
+		name: "missing",
+		path: "missing",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := filepath.Join(tmpd, tc.path)
+			u := &url.URL{Scheme: "file", Path: filepath.ToSlash(dir)}
+			if !strings.HasPrefix(u.Path, "/") {
+				u.Path = "/" + u.Path
+			}
+			repo, err := NewRemoteRepository(u)
+			if !tc.valid {
+				if err == nil {
+					t.Errorf("error should be occurred for %s", dir)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			vcs, repoURL, err := repo.VCS()
+			if vcs != tc.vcsBackend {
+				t.Errorf("got: %+v, expect: %+v", vcs, tc.vcsBackend)
+			}
+			if vcs == nil {
+				if err == nil {
+					t.Errorf("error should be occurred for %s", dir)
+				}
+				return
+			}
+			expect := dir
+			if tc.fileURL {
+				expect = u.String()
+			}
+			if repoURL.String() != expect {
+				t.Errorf("repoURL: got: %s, expect: %s", repoURL, expect)
+			}
+		})
+	}
+}
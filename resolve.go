@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// findLocalRepositories returns the local repositories matching the name. If
+// none matches, the name is resolved as a URL to the existing local path.
+func findLocalRepositories(name string) ([]*LocalRepository, error) {
+	reposFound, err := collectLocalRepositories("", func(repo *LocalRepository) bool {
+		return repo.Matches(name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(reposFound) == 0 {
+		if url, err := newURL(name, false, false); err == nil {
+			repo, err := LocalRepositoryFromURL(url)
+			if err != nil {
+				return nil, err
+			}
+			_, err = os.Stat(repo.FullPath)
+
+			// if the directory exists
+			if err == nil {
+				reposFound = append(reposFound, repo)
+			}
+		}
+	}
+	return reposFound, nil
+}
+
+// isInteractive reports whether the user can answer the prompts. It is a
+// variable to be replaced in the tests.
+var isInteractive = func() bool {
+	for _, f := range []*os.File{os.Stdin, os.Stderr} {
+		if !isatty.IsTerminal(f.Fd()) && !isatty.IsCygwinTerminal(f.Fd()) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveLocalRepository resolves the name to exactly one local repository,
+// which is shared by the commands taking a repository like look and rm. When
+// more than one matches, the one under the primary root is chosen if
+// preferPrimary is true, which is only for the commands changing nothing like
+// look. Otherwise the user is prompted to choose one of them if interactive,
+// or it is an error listing the candidates.
+func resolveLocalRepository(name string, preferPrimary bool) (*LocalRepository, error) {
+	reposFound, err := findLocalRepositories(name)
+	if err != nil {
+		return nil, err
+	}
+	if preferPrimary && len(reposFound) > 1 {
+		var primary []*LocalRepository
+		for _, repo := range reposFound {
+			if repo.IsUnderPrimaryRoot() {
+				primary = append(primary, repo)
+			}
+		}
+		if len(primary) == 1 {
+			return primary[0], nil
+		}
+	}
+
+	switch len(reposFound) {
+	case 0:
+		return nil, notFoundErrorf("No repository found")
+	case 1:
+		return reposFound[0], nil
+	}
+	// the walk is concurrent, so sort them to list in a stable order
+	sort.Slice(reposFound, func(i, j int) bool {
+		return reposFound[i].FullPath < reposFound[j].FullPath
+	})
+	if err := sortByRoots(reposFound); err != nil {
+		return nil, err
+	}
+	if isInteractive() {
+		return chooseLocalRepository(reposFound)
+	}
+	b := &strings.Builder{}
+	b.WriteString("More than one repositories are found; Try more precise name\n")
+	for _, repo := range reposFound {
+		b.WriteString(fmt.Sprintf("       - %s\n", repo.FullPath))
+	}
+	return nil, errors.New(b.String())
+}
+
+// chooseLocalRepository prompts the user to choose one of the repositories
+func chooseLocalRepository(repos []*LocalRepository) (*LocalRepository, error) {
+	fmt.Fprintln(os.Stderr, "More than one repositories are found:")
+	for i, repo := range repos {
+		fmt.Fprintf(os.Stderr, "  %d) %s\n", i+1, repo.FullPath)
+	}
+	fmt.Fprintf(os.Stderr, "Choose one [1-%d]: ", len(repos))
+	ans, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && ans == "" {
+		return nil, fmt.Errorf("aborted")
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(ans))
+	if err != nil || n < 1 || n > len(repos) {
+		return nil, fmt.Errorf("invalid choice: %q", strings.TrimSpace(ans))
+	}
+	return repos[n-1], nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveLocalRepository(t *testing.T) {
+	withFakeGitBackend(t, func(t *testing.T, tmproot string, _ *_cloneArgs, _ *_updateArgs) {
+		secondary := newTempDir(t)
+		defer os.RemoveAll(secondary)
+		_localRepositoryRoots = []string{tmproot, secondary}
+		for _, p := range []string{
+			filepath.Join(tmproot, "github.com/motemen/ghq"),
+			filepath.Join(secondary, "github.com/motemen/ghq"),
+			filepath.Join(tmproot, "github.com/motemen/gobump"),
+			filepath.Join(tmproot, "github.com/Songmu/gobump"),
+		} {
+			os.MkdirAll(filepath.Join(p, ".git"), 0755)
+		}
+		defer func(orig func() bool) { isInteractive = orig }(isInteractive)
+		isInteractive = func() bool { return false }
+
+		// the one under the primary root is preferred
+		repo, err := resolveLocalRepository("motemen/ghq", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := filepath.Join(tmproot, "github.com/motemen/ghq"); repo.FullPath != expect {
+			t.Errorf("got: %s, expect: %s", repo.FullPath, expect)
+		}
+		// but not for the commands like rm
+		_, err = resolveLocalRepository("motemen/ghq", false)
+		if err == nil || !strings.HasPrefix(err.Error(), "More than one repositories are found") {
+			t.Errorf("the candidates should be listed, but: %v", err)
+		}
+
+		_, err = resolveLocalRepository("gobump", true)
+		if err == nil || !strings.HasPrefix(err.Error(), "More than one repositories are found") {
+			t.Errorf("the candidates should be listed, but: %v", err)
+		}
+
+		if _, err := resolveLocalRepository("_unknown", true); err == nil {
+			t.Errorf("error should be occurred for no match")
+		}
+
+		isInteractive = func() bool { return true }
+		var chosen *LocalRepository
+		_, stderr, _ := captureWithInput([]string{"2"}, func() {
+			chosen, err = resolveLocalRepository("gobump", true)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(stderr, "1) ") || !strings.Contains(stderr, "2) ") {
+			t.Errorf("the candidates should be prompted, but: %s", stderr)
+		}
+		// the candidates are sorted by the roots and then the paths
+		if expect := filepath.Join(tmproot, "github.com/motemen/gobump"); chosen.FullPath != expect {
+			t.Errorf("got: %s, expect: %s", chosen.FullPath, expect)
+		}
+
+		captureWithInput([]string{"3"}, func() {
+			_, err = resolveLocalRepository("gobump", true)
+		})
+		if err == nil {
+			t.Errorf("error should be occurred for the invalid choice")
+		}
+	})
+}
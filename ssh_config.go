@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	_sshConfig    []byte
+	sshConfigOnce = &sync.Once{}
+)
+
+func readSSHConfig() []byte {
+	sshConfigOnce.Do(func() {
+		home, err := getHome()
+		if err != nil {
+			return
+		}
+		f, err := os.Open(filepath.Join(home, ".ssh", "config"))
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		_sshConfig, _ = ioutil.ReadAll(f)
+	})
+	return _sshConfig
+}
+
+// localHostname returns the host name of the URL used for the local path.
+// A host alias in ~/.ssh/config is resolved to its HostName for ssh URLs, so
+// that "ghwork:foo/bar" with "Host ghwork" aliasing to github.com is placed
+// under github.com/foo/bar while cloning via the alias.
+func localHostname(u *url.URL) string {
+	host := u.Hostname()
+	if u.Scheme != "ssh" {
+		return host
+	}
+	conf := readSSHConfig()
+	if len(conf) == 0 {
+		return host
+	}
+	if hostName := sshConfigHostName(strings.NewReader(string(conf)), host); hostName != "" {
+		return hostName
+	}
+	return host
+}
+
+// sshConfigHostName returns the HostName for the host from the ssh config.
+// As ssh does, the first obtained value is used. Match blocks and Include
+// directives are not supported.
+func sshConfigHostName(r io.Reader, host string) string {
+	matched := true // directives before the first Host apply to all hosts
+	scr := bufio.NewScanner(r)
+	for scr.Scan() {
+		line := strings.TrimSpace(scr.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(strings.Replace(line, "=", " ", 1))
+		if len(fields) < 2 {
+			continue
+		}
+		switch strings.ToLower(fields[0]) {
+		case "host":
+			matched = sshHostMatches(fields[1:], host)
+		case "match":
+			matched = false
+		case "hostname":
+			if matched {
+				return strings.ReplaceAll(fields[1], "%h", host)
+			}
+		}
+	}
+	return ""
+}
+
+func sshHostMatches(patterns []string, host string) bool {
+	matched := false
+	for _, p := range patterns {
+		negated := strings.HasPrefix(p, "!")
+		p = strings.TrimPrefix(p, "!")
+		if ok, _ := path.Match(p, host); ok {
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSSHConfigHostName(t *testing.T) {
+	conf := `# comment
+Host ghwork ghwork2
+    HostName github.com
+    User git
+
+Host *.internal !skip.internal
+    HostName %h.example.com
+
+Host=gl
+    HostName=gitlab.com
+
+Match host other
+    HostName other.example.com
+`
+	testCases := []struct {
+		name, host, expect string
+	}{{
+		name:   "alias",
+		host:   "ghwork",
+		expect: "github.com",
+	}, {
+		name:   "second alias",
+		host:   "ghwork2",
+		expect: "github.com",
+	}, {
+		name:   "pattern with token",
+		host:   "git.internal",
+		expect: "git.internal.example.com",
+	}, {
+		name:   "negated pattern",
+		host:   "skip.internal",
+		expect: "",
+	}, {
+		name:   "with equal sign",
+		host:   "gl",
+		expect: "gitlab.com",
+	}, {
+		name:   "match block is ignored",
+		host:   "other",
+		expect: "",
+	}, {
+		name:   "unknown",
+		host:   "github.com",
+		expect: "",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := sshConfigHostName(strings.NewReader(conf), tc.host); got != tc.expect {
+				t.Errorf("got: %q, expect: %q", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestLocalHostname(t *testing.T) {
+	defer func(orig []byte) {
+		_sshConfig = orig
+		sshConfigOnce = &sync.Once{}
+	}(_sshConfig)
+	sshConfigOnce = &sync.Once{}
+	sshConfigOnce.Do(func() {})
+	_sshConfig = []byte("Host ghwork\n  HostName github.com\n")
+
+	testCases := []struct {
+		url, expect string
+	}{{
+		url:    "ssh://git@ghwork/motemen/ghq",
+		expect: "github.com",
+	}, {
+		url:    "https://ghwork/motemen/ghq",
+		expect: "ghwork",
+	}, {
+		url:    "ssh://git@gitlab.com/motemen/ghq",
+		expect: "gitlab.com",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			u, err := url.Parse(tc.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := localHostname(u); got != tc.expect {
+				t.Errorf("got: %q, expect: %q", got, tc.expect)
+			}
+		})
+	}
+}
@@ -54,6 +54,16 @@ func newURL(ref string, ssh, forceMe bool) (*url.URL, error) {
 		}
 	}
 
+	// A path of a local directory, e.g. "/path/to/repo", is cloned from
+	if p, ok := localRepositoryPath(ref); ok {
+		return &url.URL{Scheme: "file", Path: p}, nil
+	}
+
+	ref, err := resolveInsteadOf(ref)
+	if err != nil {
+		return nil, err
+	}
+
 	if codecommitLikeURLPattern.MatchString(ref) {
 		// SEE ALSO:
 		// https://github.com/aws/git-remote-codecommit/blob/master/git_remote_codecommit/__init__.py#L68
@@ -106,6 +116,8 @@ func newURL(ref string, ssh, forceMe bool) (*url.URL, error) {
 		}, nil
 	}
 
+	// whether the scheme is chosen by ghq, which ghq.protocol can change
+	var noScheme bool
 	if !hasSchemePattern.MatchString(ref) {
 		if scpLikeURLPattern.MatchString(ref) {
 			matched := scpLikeURLPattern.FindStringSubmatch(ref)
@@ -123,6 +135,7 @@ func newURL(ref string, ssh, forceMe bool) (*url.URL, error) {
 			paths := strings.Split(ref, "/")
 			if len(paths) > 1 && looksLikeAuthorityPattern.MatchString(paths[0]) {
 				ref = "https://" + ref
+				noScheme = true
 			}
 		}
 	}
@@ -132,19 +145,35 @@ func newURL(ref string, ssh, forceMe bool) (*url.URL, error) {
 		return nil, err
 	}
 	if !u.IsAbs() {
+		host, err := defaultHost()
+		if err != nil {
+			return nil, err
+		}
 		if !strings.Contains(u.Path, "/") {
+			// the user completion is for GitHub, and only guessing for the others
+			if host != "github.com" {
+				return nil, fmt.Errorf("%q is ambiguous: specify <user>/<project> for the default host %s", ref, host)
+			}
 			u.Path, err = fillUsernameToPath(u.Path, forceMe)
 			if err != nil {
 				return nil, err
 			}
 		}
 		u.Scheme = "https"
-		u.Host = "github.com"
+		u.Host = host
 		if u.Path[0] != '/' {
 			u.Path = "/" + u.Path
 		}
+		noScheme = true
 	}
 
+	if !ssh && noScheme {
+		proto, err := preferredProtocol(u.Host)
+		if err != nil {
+			return nil, err
+		}
+		ssh = proto == "ssh"
+	}
 	if ssh {
 		// Assume Git repository if `-p` is given.
 		if u, err = convertGitURLHTTPToSSH(u); err != nil {
@@ -155,6 +184,64 @@ func newURL(ref string, ssh, forceMe bool) (*url.URL, error) {
 	return u, nil
 }
 
+// localRepositoryPath returns the absolute path, slash-separated for a file
+// URL, if ref is a local path: an absolute one or a relative one beginning
+// with "./" or "../", resolved against the current directory.
+func localRepositoryPath(ref string) (string, bool) {
+	parts := strings.Split(ref, "/")
+	if !filepath.IsAbs(filepath.FromSlash(ref)) && parts[0] != "." && parts[0] != ".." {
+		return "", false
+	}
+	p, err := filepath.Abs(filepath.FromSlash(ref))
+	if err != nil {
+		return "", false
+	}
+	p = filepath.ToSlash(p)
+	if !strings.HasPrefix(p, "/") {
+		// e.g. "C:/path/to/repo" on Windows
+		p = "/" + p
+	}
+	return p, true
+}
+
+// fileURLPath returns the local path of the file URL
+func fileURLPath(u *url.URL) string {
+	p := u.Path
+	if runtime.GOOS == "windows" {
+		p = strings.TrimPrefix(p, "/")
+	}
+	return filepath.FromSlash(p)
+}
+
+// resolveInsteadOf rewrites the URL by "url.<base>.insteadOf" in gitconfig as
+// git does, so that the local path is the same as the URL which git actually
+// clones from. The longest matching value wins like git.
+func resolveInsteadOf(ref string) (string, error) {
+	out, err := gitconfig.Do("--get-regexp", `^url\..*\.insteadof$`)
+	if err != nil {
+		if gitconfig.IsNotFound(err) {
+			return ref, nil
+		}
+		return "", err
+	}
+	var base, longest string
+	for _, kvStr := range strings.Split(out, "\x00") {
+		kv := strings.SplitN(kvStr, "\n", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			continue
+		}
+		if strings.HasPrefix(ref, kv[1]) && len(kv[1]) > len(longest) {
+			// the key is like "url.https://github.com/.insteadof"
+			base = strings.TrimSuffix(strings.TrimPrefix(kv[0], "url."), ".insteadof")
+			longest = kv[1]
+		}
+	}
+	if longest == "" {
+		return ref, nil
+	}
+	return base + strings.TrimPrefix(ref, longest), nil
+}
+
 func convertGitURLHTTPToSSH(u *url.URL) (*url.URL, error) {
 	user := "git"
 	if u.User != nil {
@@ -164,6 +251,45 @@ func convertGitURLHTTPToSSH(u *url.URL) (*url.URL, error) {
 	return u.Parse(sshURL)
 }
 
+// preferredProtocol returns the protocol, "ssh" or "https", to clone the
+// repositories of the host given without a scheme. "ghq.<host>.protocol"
+// takes precedence over "ghq.protocol", and it is "https" by default.
+func preferredProtocol(host string) (string, error) {
+	for _, key := range []string{
+		fmt.Sprintf("ghq.%s.protocol", host),
+		"ghq.protocol",
+	} {
+		proto, err := gitconfig.Get(key)
+		if err != nil && !gitconfig.IsNotFound(err) {
+			return "", err
+		}
+		switch proto {
+		case "":
+			continue
+		case "ssh", "https":
+			return proto, nil
+		}
+		return "", fmt.Errorf("invalid %s %q: ssh or https is available", key, proto)
+	}
+	return "https", nil
+}
+
+// defaultHost returns the host for the names without one like "user/project",
+// which is "ghq.defaultHost" or github.com.
+func defaultHost() (string, error) {
+	host, err := gitconfig.Get("ghq.defaultHost")
+	if err != nil && !gitconfig.IsNotFound(err) {
+		return "", err
+	}
+	if host == "" {
+		return "github.com", nil
+	}
+	if !looksLikeAuthorityPattern.MatchString(host) || strings.ContainsAny(host, "/@") {
+		return "", fmt.Errorf("invalid ghq.defaultHost %q: a host name like github.com is expected", host)
+	}
+	return host, nil
+}
+
 func detectUserName() (string, error) {
 	user, err := gitconfig.Get("ghq.user")
 	if (err != nil && !gitconfig.IsNotFound(err)) || user != "" {
@@ -204,3 +330,55 @@ func fillUsernameToPath(path string, forceMe bool) (string, error) {
 	}
 	return user + "/" + path, nil
 }
+
+// urlRewrite is a rule of "ghq.rewriteURL" to rewrite the URL to clone from,
+// e.g. to a mirror, without changing the local path.
+type urlRewrite struct {
+	prefix      string
+	re          *regexp.Regexp
+	replacement string
+}
+
+// loadURLRewrites reads the rules from "ghq.rewriteURL", each of which is
+// "<pattern> <replacement>". The pattern beginning with "^" is a regular
+// expression and the replacement can refer to its submatches like "$1".
+// Otherwise, it is a prefix of the URL to be replaced.
+func loadURLRewrites() ([]urlRewrite, error) {
+	vals, err := gitconfig.GetAll("ghq.rewriteURL")
+	if err != nil && !gitconfig.IsNotFound(err) {
+		return nil, err
+	}
+	var rules []urlRewrite
+	for _, v := range vals {
+		fields := strings.Fields(v)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid ghq.rewriteURL %q: \"<pattern> <replacement>\" is expected", v)
+		}
+		rule := urlRewrite{replacement: fields[1]}
+		if strings.HasPrefix(fields[0], "^") {
+			if rule.re, err = regexp.Compile(fields[0]); err != nil {
+				return nil, fmt.Errorf("invalid ghq.rewriteURL %q: %w", v, err)
+			}
+		} else {
+			rule.prefix = fields[0]
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// rewriteURL returns the URL rewritten by the first matching rule, or the URL
+// itself if none matches.
+func rewriteURL(u *url.URL, rules []urlRewrite) (*url.URL, error) {
+	s := u.String()
+	for _, rule := range rules {
+		if rule.re != nil {
+			if rule.re.MatchString(s) {
+				return url.Parse(rule.re.ReplaceAllString(s, rule.replacement))
+			}
+		} else if strings.HasPrefix(s, rule.prefix) {
+			return url.Parse(rule.replacement + strings.TrimPrefix(s, rule.prefix))
+		}
+	}
+	return u, nil
+}
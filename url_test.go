@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 
@@ -70,6 +72,33 @@ completeUser = false`)
 		url:    "peco",
 		expect: "https://github.com/peco/peco",
 		host:   "github.com",
+	}, {
+		name: "ghq.defaultHost",
+		setup: func() func() {
+			return gitconfig.WithConfig(t, `[ghq]
+defaultHost = gitlab.com`)
+		},
+		url:    "motemen/pusheen-explorer",
+		expect: "https://gitlab.com/motemen/pusheen-explorer",
+		host:   "gitlab.com",
+	}, {
+		name: "ghq.defaultHost with host",
+		setup: func() func() {
+			return gitconfig.WithConfig(t, `[ghq]
+defaultHost = gitlab.com`)
+		},
+		url:    "github.com/motemen/pusheen-explorer",
+		expect: "https://github.com/motemen/pusheen-explorer",
+		host:   "github.com",
+	}, {
+		name: "ghq.defaultHost with scheme",
+		setup: func() func() {
+			return gitconfig.WithConfig(t, `[ghq]
+defaultHost = gitlab.com`)
+		},
+		url:    "ssh://git@example.com/motemen/pusheen-explorer",
+		expect: "ssh://git@example.com/motemen/pusheen-explorer",
+		host:   "example.com",
 	}}
 
 	for _, tc := range testCases {
@@ -91,6 +120,121 @@ completeUser = false`)
 	}
 }
 
+func TestNewURL_protocol(t *testing.T) {
+	testCases := []struct {
+		name, config, url, expect string
+	}{{
+		name: "global",
+		config: `[ghq]
+protocol = ssh`,
+		url:    "github.com/motemen/ghq",
+		expect: "ssh://git@github.com/motemen/ghq",
+	}, {
+		name: "without host",
+		config: `[ghq]
+protocol = ssh`,
+		url:    "motemen/ghq",
+		expect: "ssh://git@github.com/motemen/ghq",
+	}, {
+		name: "host",
+		config: `[ghq "github.com"]
+protocol = ssh`,
+		url:    "github.com/motemen/ghq",
+		expect: "ssh://git@github.com/motemen/ghq",
+	}, {
+		name: "another host",
+		config: `[ghq "github.com"]
+protocol = ssh`,
+		url:    "git.example.com/motemen/ghq",
+		expect: "https://git.example.com/motemen/ghq",
+	}, {
+		name: "host overrides global",
+		config: `[ghq]
+protocol = ssh
+[ghq "git.example.com"]
+protocol = https`,
+		url:    "git.example.com/motemen/ghq",
+		expect: "https://git.example.com/motemen/ghq",
+	}, {
+		name: "host overrides global ssh",
+		config: `[ghq]
+protocol = https
+[ghq "github.com"]
+protocol = ssh`,
+		url:    "github.com/motemen/ghq",
+		expect: "ssh://git@github.com/motemen/ghq",
+	}, {
+		name: "explicit scheme",
+		config: `[ghq]
+protocol = ssh`,
+		url:    "https://github.com/motemen/ghq",
+		expect: "https://github.com/motemen/ghq",
+	}, {
+		name: "scp-like",
+		config: `[ghq]
+protocol = https`,
+		url:    "git@github.com:motemen/ghq.git",
+		expect: "ssh://git@github.com/motemen/ghq.git",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer gitconfig.WithConfig(t, tc.config)()
+			u, err := newURL(tc.url, false, false)
+			if err != nil {
+				t.Fatalf("error should be nil but: %s", err)
+			}
+			if u.String() != tc.expect {
+				t.Errorf("got: %s, expect: %s", u, tc.expect)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		defer gitconfig.WithConfig(t, `[ghq "github.com"]
+protocol = git`)()
+		_, err := newURL("github.com/motemen/ghq", false, false)
+		if err == nil || !strings.Contains(err.Error(), "invalid ghq.github.com.protocol") {
+			t.Errorf("error should be occurred for the invalid protocol, but: %v", err)
+		}
+	})
+}
+
+func TestNewURL_localPath(t *testing.T) {
+	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
+	_localRepositoryRoots = []string{"/path/to/unknown-ghq"}
+	tmpd := newTempDir(t)
+	defer os.RemoveAll(tmpd)
+	repo := filepath.Join(tmpd, "src", "repo")
+	os.MkdirAll(repo, 0755)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	os.Chdir(filepath.Join(tmpd, "src"))
+
+	expect := "file://" + filepath.ToSlash(repo)
+	if !strings.HasPrefix(filepath.ToSlash(repo), "/") {
+		expect = "file:///" + filepath.ToSlash(repo)
+	}
+	for _, ref := range []string{repo, "./repo", "../src/repo"} {
+		t.Run(ref, func(t *testing.T) {
+			u, err := newURL(ref, false, false)
+			if err != nil {
+				t.Fatalf("error should be nil but: %s", err)
+			}
+			if u.String() != expect {
+				t.Errorf("got: %s, expect: %s", u, expect)
+			}
+			if fileURLPath(u) != repo {
+				t.Errorf("fileURLPath: got: %s, expect: %s", fileURLPath(u), repo)
+			}
+		})
+	}
+}
+
 func TestConvertGitURLHTTPToSSH(t *testing.T) {
 	testCases := []struct {
 		url, expect string
@@ -138,6 +282,32 @@ func TestNewURL_err(t *testing.T) {
 	}
 }
 
+func TestNewURL_defaultHost_err(t *testing.T) {
+	testCases := []struct {
+		name, config, url, wantSub string
+	}{{
+		name:    "single name",
+		config:  "defaultHost = gitlab.com",
+		url:     "peco",
+		wantSub: "is ambiguous",
+	}, {
+		name:    "invalid host",
+		config:  "defaultHost = https://gitlab.com",
+		url:     "peco/peco",
+		wantSub: "invalid ghq.defaultHost",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer gitconfig.WithConfig(t, "[ghq]\n"+tc.config)()
+			_, err := newURL(tc.url, false, false)
+			if got := fmt.Sprint(err); !strings.Contains(got, tc.wantSub) {
+				t.Errorf("newURL(%q) error = %q; want substring %q", tc.url, got, tc.wantSub)
+			}
+		})
+	}
+}
+
 func TestFillUsernameToPath_err(t *testing.T) {
 	for _, envStr := range []string{"GITHUB_USER", "GITHUB_TOKEN", "USER", "USERNAME"} {
 		defer tmpEnv(envStr, "")()
@@ -151,3 +321,113 @@ func TestFillUsernameToPath_err(t *testing.T) {
 		t.Errorf("fillUsernameToPath(peco, false) error = %q; want substring %q", got, wantSub)
 	}
 }
+
+func TestRewriteURL(t *testing.T) {
+	rules := []urlRewrite{{
+		prefix:      "https://github.com/motemen/",
+		replacement: "https://git.example.com/motemen/",
+	}, {
+		re:          regexp.MustCompile(`^https://github\.com/([^/]+)/(.+)$`),
+		replacement: "ssh://git@mirror.example.com/$1-$2",
+	}}
+	testCases := []struct {
+		url    string
+		expect string
+	}{{
+		url:    "https://github.com/motemen/ghq",
+		expect: "https://git.example.com/motemen/ghq",
+	}, {
+		url:    "https://github.com/x-motemen/ghq",
+		expect: "ssh://git@mirror.example.com/x-motemen-ghq",
+	}, {
+		url:    "https://gitlab.com/x-motemen/ghq",
+		expect: "https://gitlab.com/x-motemen/ghq",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			got, err := rewriteURL(mustParseURL(tc.url), rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tc.expect {
+				t.Errorf("got: %s, expect: %s", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestLoadURLRewrites(t *testing.T) {
+	defer gitconfig.WithConfig(t, `
+[ghq]
+  rewriteURL = "https://github.com/ https://git.example.com/"
+  rewriteURL = "^https://gitlab\\.com/(.+)$ https://git.example.com/gitlab/$1"
+`)()
+	rules, err := loadURLRewrites()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].prefix != "https://github.com/" || rules[1].re == nil {
+		t.Errorf("unexpected rules: %+v", rules)
+	}
+
+	defer gitconfig.WithConfig(t, `
+[ghq]
+  rewriteURL = "https://github.com/"
+`)()
+	if _, err := loadURLRewrites(); err == nil {
+		t.Errorf("error should be occurred for the rule without replacement")
+	}
+}
+
+func TestResolveInsteadOf(t *testing.T) {
+	defer gitconfig.WithConfig(t, `
+[url "https://github.com/"]
+  insteadOf = gh:
+[url "git@github.com:"]
+  insteadOf = https://github.com/
+[url "https://git.example.com/mirror/"]
+  insteadOf = https://github.com/x-motemen/
+`)()
+
+	testCases := []struct {
+		ref    string
+		expect string
+	}{{
+		ref:    "gh:motemen/gore",
+		expect: "https://github.com/motemen/gore",
+	}, {
+		ref:    "https://github.com/motemen/gore",
+		expect: "git@github.com:motemen/gore",
+	}, {
+		// the longest match wins
+		ref:    "https://github.com/x-motemen/ghq",
+		expect: "https://git.example.com/mirror/ghq",
+	}, {
+		ref:    "https://gitlab.com/motemen/gore",
+		expect: "https://gitlab.com/motemen/gore",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.ref, func(t *testing.T) {
+			got, err := resolveInsteadOf(tc.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.expect {
+				t.Errorf("got: %s, expect: %s", got, tc.expect)
+			}
+		})
+	}
+
+	// the local path is the same regardless of the form typed
+	for _, ref := range []string{"gh:motemen/gore", "https://github.com/motemen/gore", "git@github.com:motemen/gore"} {
+		u, err := newURL(ref, false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := localHostname(u) + u.Path; got != "github.com/motemen/gore" {
+			t.Errorf("%s: got: %s, expect: github.com/motemen/gore", ref, got)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -10,23 +11,125 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/x-motemen/ghq/cmdutil"
+	"github.com/x-motemen/ghq/logger"
 )
 
-func run(silent bool) func(command string, args ...string) error {
-	if silent {
-		return cmdutil.RunSilently
+func (vg *vcsGetOption) run(command string, args ...string) error {
+	return vg.runInDir("", command, args...)
+}
+
+func (vg *vcsGetOption) runInDir(dir, command string, args ...string) error {
+	return vg.runCommand(vg.command(dir, command, args...), vg.silent)
+}
+
+func (vg *vcsGetOption) runInDirSilently(dir, command string, args ...string) error {
+	return vg.runCommand(vg.command(dir, command, args...), true)
+}
+
+// command builds the command bound to the context of the option, so that
+// it is killed when the context is canceled or timed out.
+func (vg *vcsGetOption) command(dir, command string, args ...string) *exec.Cmd {
+	ctx := vg.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Dir = dir
+	if len(vg.env) > 0 {
+		cmd.Env = append(os.Environ(), vg.env...)
+	}
+	return cmd
+}
+
+func (vg *vcsGetOption) runCommand(cmd *exec.Cmd, silent bool) error {
+	if vg.commands != nil {
+		line := shellQuote(cmd.Args...)
+		if cmd.Dir != "" {
+			line = "cd " + shellQuote(cmd.Dir) + " && " + line
+		}
+		*vg.commands = append(*vg.commands, logger.Redact(line))
+		return nil
+	}
+	if !silent {
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		return cmdutil.RunCommand(cmd, silent)
 	}
-	return cmdutil.Run
+	// the error output is kept to tell why the command failed
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = ioutil.Discard
+	cmd.Stderr = stderr
+	err := cmdutil.RunCommand(cmd, silent)
+	if msg := strings.TrimSpace(stderr.String()); err != nil && msg != "" {
+		return fmt.Errorf("%w\n%s", err, logger.Redact(msg))
+	}
+	return err
 }
 
-func runInDir(silent bool) func(dir, command string, args ...string) error {
+// runInit runs the command to create a repository in dir
+func runInit(dir string, silent bool, command string, args ...string) error {
 	if silent {
-		return cmdutil.RunInDirSilently
+		return cmdutil.RunInDirSilently(dir, command, args...)
 	}
-	return cmdutil.RunInDir
+	return cmdutil.RunInDir(dir, command, args...)
+}
+
+// mkdirAll creates the directory unless the commands are only recorded
+func (vg *vcsGetOption) mkdirAll(dir string) error {
+	if vg.commands != nil {
+		return nil
+	}
+	return os.MkdirAll(dir, 0755)
+}
+
+var shellSafeReg = regexp.MustCompile(`^[\w@%+=:,./-]+$`)
+
+// shellQuote joins the arguments into a command line, quoting them for the
+// shell if needed.
+func shellQuote(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if shellSafeReg.MatchString(a) {
+			quoted[i] = a
+		} else {
+			quoted[i] = "'" + strings.Replace(a, "'", `'\''`, -1) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// quoteShellArg quotes the value as an argument for the shell run by
+// shellCommand. The empty value is left empty to be omitted.
+func quoteShellArg(s string) string {
+	if s == "" {
+		return ""
+	}
+	if runtime.GOOS == "windows" {
+		return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+	}
+	return shellQuote(s)
+}
+
+// shallowDepth returns the depth of the shallow clone
+func (vg *vcsGetOption) shallowDepth() int {
+	if vg.depth > 0 {
+		return vg.depth
+	}
+	return 1
+}
+
+// shellCommand returns the shell and its flag to run a command line
+func shellCommand() (string, string) {
+	if runtime.GOOS == "windows" {
+		return "cmd", "/c"
+	}
+	return "sh", "-c"
 }
 
 // A VCSBackend represents a VCS backend.
@@ -35,67 +138,318 @@ type VCSBackend struct {
 	Clone func(*vcsGetOption) error
 	// Updates a cloned local repository.
 	Update func(*vcsGetOption) error
-	Init   func(dir string) error
+	// Creates a new repository in the existing directory.
+	Init func(dir string, silent bool) error
 	// Returns VCS specific files
 	Contents []string
+	// Whether Clone honors vcsGetOption.shallow
+	SupportsShallow bool
+	// Whether Clone honors vcsGetOption.depth, the number of the commits of
+	// the shallow clone. The others clone as shallow as they can.
+	SupportsDepth bool
 }
 
 type vcsGetOption struct {
+	ctx                        context.Context
 	url                        *url.URL
 	dir                        string
 	recursive, shallow, silent bool
-	branch                     string
+	noHardlinks, autoDeepen    bool
+	unshallow, prune, mirror   bool
+	alsoFilterSubmodules       bool
+	submoduleJobs              int // 0 means the default of git
+	branch, username           string
+	// depth is the number of the commits of the shallow clone, which is 1
+	// if it is 0
+	depth int
+	// commands records the command lines instead of running them if it is
+	// not nil, for ghq get --dry-run. It is shared by the copies of the option.
+	commands *[]string
+	// defaultBranch is the branch to check out, without making the clone
+	// single-branch unlike branch (git only)
+	defaultBranch string
+	// ref is the branch or tag to check out, and preferRef decides which one
+	// wins when both exist: "tag", "branch" or empty to leave it to git (git only)
+	ref, preferRef string
+	// updateStrategy is how to pull for updating: "ff-only" (default),
+	// "rebase" or "merge" (git only)
+	updateStrategy string
+	// origin is the name of the remote of the clone instead of "origin" (git only)
+	origin string
+	// insecure skips the verification of the TLS certificate of the server.
+	// For git, it is given as "http.sslVerify=false" in gitConfig instead.
+	insecure bool
+	// gitConfig is a list of "key=value" passed to git via "-c" to scope the
+	// configuration to the subprocess
+	gitConfig []string
+	// env is a list of "KEY=VALUE" added to the environment of the subprocesses
+	env []string
+	// sparse is the directories checked out by sparse-checkout after a
+	// partial clone without blobs (git only)
+	sparse []string
+	// reference is the local repository to borrow the objects from, and
+	// dissociate copies them to make the clone independent of it (git only)
+	reference  string
+	dissociate bool
+}
+
+// gitArgs prepends the scoped configuration to the git arguments
+func (vg *vcsGetOption) gitArgs(args ...string) []string {
+	var ret []string
+	for _, c := range vg.gitConfig {
+		ret = append(ret, "-c", c)
+	}
+	return append(ret, args...)
+}
+
+// the values of ghq.update.strategy
+const (
+	updateStrategyFFOnly = "ff-only"
+	updateStrategyRebase = "rebase"
+	updateStrategyMerge  = "merge"
+)
+
+// gitPullArgs returns the arguments of git to pull by the update strategy
+func (vg *vcsGetOption) gitPullArgs() []string {
+	var args []string
+	switch vg.updateStrategy {
+	case updateStrategyRebase:
+		args = vg.gitArgs("pull", "--rebase")
+	case updateStrategyMerge:
+		args = vg.gitArgs("pull", "--no-rebase")
+	default:
+		args = vg.gitArgs("pull", "--ff-only")
+	}
+	if vg.prune {
+		args = append(args, "--prune")
+	}
+	return args
+}
+
+// submoduleUpdateArgs returns the arguments of git to update the submodules
+// recursively
+func (vg *vcsGetOption) submoduleUpdateArgs() []string {
+	args := []string{"submodule", "update", "--init", "--recursive"}
+	if vg.submoduleJobs > 0 {
+		args = append(args, "--jobs", strconv.Itoa(vg.submoduleJobs))
+	}
+	return args
+}
+
+// the values of --prefer
+const (
+	preferRefTag    = "tag"
+	preferRefBranch = "branch"
+)
+
+// checkoutPreferringTag checks out the tag of vg.ref detaching HEAD, or the
+// branch of the same name if there is no such tag.
+func (vg *vcsGetOption) checkoutPreferringTag() error {
+	remote := vg.origin
+	if remote == "" {
+		remote = "origin"
+	}
+	tag := "refs/tags/" + vg.ref
+	args := vg.gitArgs("fetch")
+	if vg.shallow {
+		args = append(args, "--depth", strconv.Itoa(vg.shallowDepth()))
+	}
+	args = append(args, remote, "+"+tag+":"+tag)
+	if err := vg.runInDirSilently(vg.dir, "git", args...); err != nil {
+		logger.Log("warning", fmt.Sprintf("tag %q is not found, checking out the branch instead", vg.ref))
+		if err := vg.runInDir(vg.dir, "git", "checkout", vg.ref); err != nil {
+			return err
+		}
+	} else if err := vg.runInDir(vg.dir, "git", "checkout", "--detach", tag); err != nil {
+		return err
+	}
+	if vg.recursive {
+		return vg.runInDir(vg.dir, "git", vg.submoduleUpdateArgs()...)
+	}
+	return nil
+}
+
+const (
+	autoDeepenStart = 100
+	autoDeepenMax   = 3200
+)
+
+// pullDeepening fetches more history of the shallow repository and retries
+// the fast-forward pull, doubling the depth up to autoDeepenMax.
+func (vg *vcsGetOption) pullDeepening() error {
+	var err error
+	for depth := autoDeepenStart; depth <= autoDeepenMax; depth *= 2 {
+		logger.Log("deepen", fmt.Sprintf("%s by %d commits", vg.dir, depth))
+		if err := vg.runInDir(vg.dir, "git", vg.gitArgs("fetch", fmt.Sprintf("--deepen=%d", depth))...); err != nil {
+			return err
+		}
+		if err = vg.runInDir(vg.dir, "git", vg.gitPullArgs()...); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+var gitVersionReg = regexp.MustCompile(`(\d+)\.(\d+)`)
+
+// checkGitVersion returns an error if the version of git is older than
+// major.minor, which is required for the feature.
+func checkGitVersion(major, minor int, feature string) error {
+	out, err := outputInDir("", "git", "version")
+	if err != nil {
+		return err
+	}
+	ok, err := isGitVersionAtLeast(out, major, minor)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s requires git %d.%d or later, but %s", feature, major, minor, out)
+	}
+	return nil
+}
+
+func isGitVersionAtLeast(versionOutput string, major, minor int) (bool, error) {
+	m := gitVersionReg.FindStringSubmatch(versionOutput)
+	if m == nil {
+		return false, fmt.Errorf("failed to detect git version from %q", versionOutput)
+	}
+	ma, _ := strconv.Atoi(m[1])
+	mi, _ := strconv.Atoi(m[2])
+	return ma > major || (ma == major && mi >= minor), nil
+}
+
+func isShallowGitRepository(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git", "shallow"))
+	return err == nil
 }
 
 // GitBackend is the VCSBackend of git
 var GitBackend = &VCSBackend{
-	// support submodules?
 	Clone: func(vg *vcsGetOption) error {
 		dir, _ := filepath.Split(vg.dir)
-		err := os.MkdirAll(dir, 0755)
+		err := vg.mkdirAll(dir)
 		if err != nil {
 			return err
 		}
 
-		args := []string{"clone"}
+		args := vg.gitArgs("clone")
+		if vg.mirror {
+			// a mirror has all the refs without the working tree
+			args = append(args, "--mirror")
+		}
 		if vg.shallow {
-			args = append(args, "--depth", "1")
+			args = append(args, "--depth", strconv.Itoa(vg.shallowDepth()))
 		}
 		if vg.branch != "" {
 			args = append(args, "--branch", vg.branch, "--single-branch")
+		} else if vg.ref != "" && vg.preferRef != preferRefTag {
+			// git itself resolves the branch first
+			args = append(args, "--branch", vg.ref)
+		} else if vg.defaultBranch != "" {
+			args = append(args, "--branch", vg.defaultBranch)
 		}
 		if vg.recursive {
 			args = append(args, "--recursive")
+			if vg.shallow {
+				args = append(args, "--shallow-submodules")
+			}
+			if vg.submoduleJobs > 0 {
+				args = append(args, "--jobs", strconv.Itoa(vg.submoduleJobs))
+			}
+		}
+		if len(vg.sparse) > 0 {
+			args = append(args, "--sparse")
+		}
+		if vg.alsoFilterSubmodules {
+			args = append(args, "--filter=blob:none", "--also-filter-submodules")
+		} else if len(vg.sparse) > 0 {
+			args = append(args, "--filter=blob:none")
+		}
+		if vg.origin != "" {
+			args = append(args, "--origin", vg.origin)
+		}
+		if vg.noHardlinks {
+			args = append(args, "--no-hardlinks")
+		}
+		if vg.reference != "" {
+			args = append(args, "--reference", vg.reference)
+			if vg.dissociate {
+				args = append(args, "--dissociate")
+			}
 		}
 		args = append(args, vg.url.String(), vg.dir)
 
-		return run(vg.silent)("git", args...)
+		if err := vg.run("git", args...); err != nil {
+			return err
+		}
+		if len(vg.sparse) > 0 {
+			sparseArgs := append([]string{"sparse-checkout", "set"}, vg.sparse...)
+			if err := vg.runInDir(vg.dir, "git", vg.gitArgs(sparseArgs...)...); err != nil {
+				return err
+			}
+		}
+		if vg.ref != "" && vg.preferRef == preferRefTag {
+			return vg.checkoutPreferringTag()
+		}
+		return nil
 	},
 	Update: func(vg *vcsGetOption) error {
 		if _, err := os.Stat(filepath.Join(vg.dir, ".git/svn")); err == nil {
 			return GitsvnBackend.Update(vg)
 		}
-		err := runInDir(true)(vg.dir, "git", "rev-parse", "@{upstream}")
+		if isBareGitRepository(vg.dir) {
+			// bare and mirror repositories are updated by fetching all the remotes
+			return vg.runInDir(vg.dir, "git", vg.gitArgs("remote", "update", "--prune")...)
+		}
+		if vg.unshallow && isShallowGitRepository(vg.dir) {
+			logger.Log("unshallow", vg.dir)
+			if err := vg.runInDir(vg.dir, "git", vg.gitArgs("fetch", "--unshallow")...); err != nil {
+				return err
+			}
+		}
+		err := vg.runInDirSilently(vg.dir, "git", "rev-parse", "@{upstream}")
 		if err != nil {
-			err := runInDir(vg.silent)(vg.dir, "git", "fetch")
+			args := vg.gitArgs("fetch")
+			if vg.prune {
+				args = append(args, "--prune")
+			}
+			err := vg.runInDir(vg.dir, "git", args...)
 			if err != nil {
 				return err
 			}
 			return nil
 		}
-		err = runInDir(vg.silent)(vg.dir, "git", "pull", "--ff-only")
+		err = vg.runInDir(vg.dir, "git", vg.gitPullArgs()...)
+		if err != nil && vg.autoDeepen && isShallowGitRepository(vg.dir) {
+			err = vg.pullDeepening()
+		}
 		if err != nil {
 			return err
 		}
 		if vg.recursive {
-			return runInDir(vg.silent)(vg.dir, "git", "submodule", "update", "--init", "--recursive")
+			return vg.runInDir(vg.dir, "git", vg.submoduleUpdateArgs()...)
 		}
 		return nil
 	},
-	Init: func(dir string) error {
-		return cmdutil.RunInDir(dir, "git", "init")
+	Init: func(dir string, silent bool) error {
+		return runInit(dir, silent, "git", "init")
 	},
-	Contents: []string{".git"},
+	Contents:        []string{".git"},
+	SupportsShallow: true,
+	SupportsDepth:   true,
+}
+
+var lfsFilterReg = regexp.MustCompile(`(?m)\sfilter=lfs(?:\s|$)`)
+
+// usesGitLFS reports whether the .gitattributes of the working tree assigns
+// any paths to the Git LFS filter.
+func usesGitLFS(dir string) bool {
+	b, err := ioutil.ReadFile(filepath.Join(dir, ".gitattributes"))
+	if err != nil {
+		return false
+	}
+	return lfsFilterReg.Match(b)
 }
 
 /*
@@ -134,17 +488,35 @@ func svnBase(p string) string {
 	return replaceOnce(svnReg, p, "")
 }
 
+// svnAuthArgs returns the authentication arguments for svn. When running
+// silently, nobody can answer the prompts, so svn is made non-interactive to
+// use only the stored credentials.
+func svnAuthArgs(vg *vcsGetOption) []string {
+	var args []string
+	if vg.username != "" {
+		args = append(args, "--username", vg.username)
+	}
+	if vg.silent || vg.insecure {
+		args = append(args, "--non-interactive")
+	}
+	if vg.insecure {
+		args = append(args, "--trust-server-cert-failures=unknown-ca,cn-mismatch,expired,not-yet-valid,other")
+	}
+	return args
+}
+
 // SubversionBackend is the VCSBackend for subversion
 var SubversionBackend = &VCSBackend{
 	Clone: func(vg *vcsGetOption) error {
 		vg.dir = svnBase(vg.dir)
 		dir, _ := filepath.Split(vg.dir)
-		err := os.MkdirAll(dir, 0755)
+		err := vg.mkdirAll(dir)
 		if err != nil {
 			return err
 		}
 
 		args := []string{"checkout"}
+		args = append(args, svnAuthArgs(vg)...)
 		if vg.shallow {
 			args = append(args, "--depth", "immediates")
 		}
@@ -157,18 +529,23 @@ var SubversionBackend = &VCSBackend{
 		} else if !strings.HasSuffix(remote.Path, trunk) {
 			copied := *vg.url
 			copied.Path += trunk
-			if err := cmdutil.RunSilently("svn", "info", copied.String()); err == nil {
+			if err := vg.runInDirSilently("", "svn", "info", copied.String()); err == nil {
 				remote = &copied
 			}
 		}
 		args = append(args, remote.String(), vg.dir)
 
-		return run(vg.silent)("svn", args...)
+		return vg.run("svn", args...)
 	},
 	Update: func(vg *vcsGetOption) error {
-		return runInDir(vg.silent)(vg.dir, "svn", "update")
+		args := append([]string{"update"}, svnAuthArgs(vg)...)
+		return vg.runInDir(vg.dir, "svn", args...)
 	},
-	Contents: []string{".svn"},
+	Init: func(dir string, silent bool) error {
+		return errors.New("Subversion init is not supported: create the repository on the server and get it")
+	},
+	Contents:        []string{".svn"},
+	SupportsShallow: true,
 }
 
 var svnLastRevReg = regexp.MustCompile(`(?m)^Last Changed Rev: (\d+)$`)
@@ -181,14 +558,14 @@ var GitsvnBackend = &VCSBackend{
 		standard := orig == vg.dir
 
 		dir, _ := filepath.Split(vg.dir)
-		err := os.MkdirAll(dir, 0755)
+		err := vg.mkdirAll(dir)
 		if err != nil {
 			return err
 		}
 
 		var getSvnInfo = func(u string) (string, error) {
 			buf := &bytes.Buffer{}
-			cmd := exec.Command("svn", "info", u)
+			cmd := vg.command("", "svn", "info", u)
 			cmd.Stdout = buf
 			cmd.Stderr = ioutil.Discard
 			err := cmdutil.RunCommand(cmd, true)
@@ -196,6 +573,9 @@ var GitsvnBackend = &VCSBackend{
 		}
 		var svnInfo string
 		args := []string{"svn", "clone"}
+		if vg.username != "" {
+			args = append(args, "--username", vg.username)
+		}
 		remote := vg.url
 		if vg.branch != "" {
 			copied := *remote
@@ -227,15 +607,26 @@ var GitsvnBackend = &VCSBackend{
 			if len(m) < 2 {
 				return fmt.Errorf("no revisions are taken from svn info output: %s", svnInfo)
 			}
-			args = append(args, fmt.Sprintf("-r%s:HEAD", m[1]))
+			// the revisions are counted back from the last changed one, though
+			// the ones not touching the path are skipped by git-svn
+			rev, _ := strconv.Atoi(m[1])
+			if rev -= vg.shallowDepth() - 1; rev < 1 {
+				rev = 1
+			}
+			args = append(args, fmt.Sprintf("-r%d:HEAD", rev))
 		}
 		args = append(args, remote.String(), vg.dir)
-		return run(vg.silent)("git", args...)
+		return vg.run("git", args...)
 	},
 	Update: func(vg *vcsGetOption) error {
-		return runInDir(vg.silent)(vg.dir, "git", "svn", "rebase")
+		return vg.runInDir(vg.dir, "git", "svn", "rebase")
+	},
+	Init: func(dir string, silent bool) error {
+		return errors.New("git-svn init is not supported: create the repository on the Subversion server and get it")
 	},
-	Contents: []string{".git/svn"},
+	Contents:        []string{".git/svn"},
+	SupportsShallow: true,
+	SupportsDepth:   true,
 }
 
 // MercurialBackend is the VCSBackend for mercurial
@@ -243,7 +634,7 @@ var MercurialBackend = &VCSBackend{
 	// Mercurial seems not supporting shallow clone currently.
 	Clone: func(vg *vcsGetOption) error {
 		dir, _ := filepath.Split(vg.dir)
-		err := os.MkdirAll(dir, 0755)
+		err := vg.mkdirAll(dir)
 		if err != nil {
 			return err
 		}
@@ -251,15 +642,22 @@ var MercurialBackend = &VCSBackend{
 		if vg.branch != "" {
 			args = append(args, "--branch", vg.branch)
 		}
+		if vg.insecure {
+			args = append(args, "--insecure")
+		}
 		args = append(args, vg.url.String(), vg.dir)
 
-		return run(vg.silent)("hg", args...)
+		return vg.run("hg", args...)
 	},
 	Update: func(vg *vcsGetOption) error {
-		return runInDir(vg.silent)(vg.dir, "hg", "pull", "--update")
+		args := []string{"pull", "--update"}
+		if vg.insecure {
+			args = append(args, "--insecure")
+		}
+		return vg.runInDir(vg.dir, "hg", args...)
 	},
-	Init: func(dir string) error {
-		return cmdutil.RunInDir(dir, "hg", "init")
+	Init: func(dir string, silent bool) error {
+		return runInit(dir, silent, "hg", "init")
 	},
 	Contents: []string{".hg"},
 }
@@ -272,7 +670,7 @@ var DarcsBackend = &VCSBackend{
 		}
 
 		dir, _ := filepath.Split(vg.dir)
-		err := os.MkdirAll(dir, 0755)
+		err := vg.mkdirAll(dir)
 		if err != nil {
 			return err
 		}
@@ -283,15 +681,16 @@ var DarcsBackend = &VCSBackend{
 		}
 		args = append(args, vg.url.String(), vg.dir)
 
-		return run(vg.silent)("darcs", args...)
+		return vg.run("darcs", args...)
 	},
 	Update: func(vg *vcsGetOption) error {
-		return runInDir(vg.silent)(vg.dir, "darcs", "pull")
+		return vg.runInDir(vg.dir, "darcs", "pull")
 	},
-	Init: func(dir string) error {
-		return cmdutil.RunInDir(dir, "darcs", "init")
+	Init: func(dir string, silent bool) error {
+		return runInit(dir, silent, "darcs", "init")
 	},
-	Contents: []string{"_darcs"},
+	Contents:        []string{"_darcs"},
+	SupportsShallow: true,
 }
 
 var cvsDummyBackend = &VCSBackend{
@@ -301,6 +700,9 @@ var cvsDummyBackend = &VCSBackend{
 	Update: func(vg *vcsGetOption) error {
 		return errors.New("CVS update is not supported")
 	},
+	Init: func(dir string, silent bool) error {
+		return errors.New("CVS init is not supported")
+	},
 	Contents: []string{"CVS/Repository"},
 }
 
@@ -312,23 +714,23 @@ var FossilBackend = &VCSBackend{
 		if vg.branch != "" {
 			return errors.New("Fossil does not support cloning specific branch")
 		}
-		if err := os.MkdirAll(vg.dir, 0755); err != nil {
+		if err := vg.mkdirAll(vg.dir); err != nil {
 			return err
 		}
 
-		if err := run(vg.silent)("fossil", "clone", vg.url.String(), filepath.Join(vg.dir, fossilRepoName)); err != nil {
+		if err := vg.run("fossil", "clone", vg.url.String(), filepath.Join(vg.dir, fossilRepoName)); err != nil {
 			return err
 		}
-		return runInDir(vg.silent)(vg.dir, "fossil", "open", fossilRepoName)
+		return vg.runInDir(vg.dir, "fossil", "open", fossilRepoName)
 	},
 	Update: func(vg *vcsGetOption) error {
-		return runInDir(vg.silent)(vg.dir, "fossil", "update")
+		return vg.runInDir(vg.dir, "fossil", "update")
 	},
-	Init: func(dir string) error {
-		if err := cmdutil.RunInDir(dir, "fossil", "init", fossilRepoName); err != nil {
+	Init: func(dir string, silent bool) error {
+		if err := runInit(dir, silent, "fossil", "init", fossilRepoName); err != nil {
 			return err
 		}
-		return cmdutil.RunInDir(dir, "fossil", "open", fossilRepoName)
+		return runInit(dir, silent, "fossil", "open", fossilRepoName)
 	},
 	Contents: []string{".fslckout", "_FOSSIL_"},
 }
@@ -341,22 +743,73 @@ var BazaarBackend = &VCSBackend{
 			return errors.New("--branch option is unavailable for Bazaar since branch is included in remote URL")
 		}
 		dir, _ := filepath.Split(vg.dir)
-		err := os.MkdirAll(dir, 0755)
+		err := vg.mkdirAll(dir)
 		if err != nil {
 			return err
 		}
-		return run(vg.silent)("bzr", "branch", vg.url.String(), vg.dir)
+		return vg.run("bzr", "branch", vg.url.String(), vg.dir)
 	},
 	Update: func(vg *vcsGetOption) error {
 		// Without --overwrite bzr will not pull tags that changed.
-		return runInDir(vg.silent)(vg.dir, "bzr", "pull", "--overwrite")
+		return vg.runInDir(vg.dir, "bzr", "pull", "--overwrite")
 	},
-	Init: func(dir string) error {
-		return cmdutil.RunInDir(dir, "bzr", "init")
+	Init: func(dir string, silent bool) error {
+		return runInit(dir, silent, "bzr", "init")
 	},
 	Contents: []string{".bzr"},
 }
 
+// PijulBackend is the VCSBackend for pijul
+var PijulBackend = &VCSBackend{
+	// pijul does not support shallow clone.
+	Clone: func(vg *vcsGetOption) error {
+		dir, _ := filepath.Split(vg.dir)
+		err := vg.mkdirAll(dir)
+		if err != nil {
+			return err
+		}
+
+		args := []string{"clone"}
+		if vg.branch != "" {
+			// the branches of pijul are called channels
+			args = append(args, "--channel", vg.branch)
+		}
+		args = append(args, vg.url.String(), vg.dir)
+
+		return vg.run("pijul", args...)
+	},
+	Update: func(vg *vcsGetOption) error {
+		// --all not to prompt to choose the changes to pull
+		return vg.runInDir(vg.dir, "pijul", "pull", "--all")
+	},
+	Init: func(dir string, silent bool) error {
+		return runInit(dir, silent, "pijul", "init")
+	},
+	Contents: []string{".pijul"},
+}
+
+// vcsNames returns the sorted names of vcsRegistry including the aliases
+func vcsNames() []string {
+	names := make([]string, 0, len(vcsRegistry))
+	for name := range vcsRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// vcsName returns the shortest name of the backend in vcsRegistry, e.g. "git"
+// rather than "github", or "" for the unknown one.
+func vcsName(backend *VCSBackend) string {
+	var name string
+	for _, n := range vcsNames() {
+		if vcsRegistry[n] == backend && (name == "" || len(n) < len(name)) {
+			name = n
+		}
+	}
+	return name
+}
+
 var vcsRegistry = map[string]*VCSBackend{
 	"git":        GitBackend,
 	"github":     GitBackend,
@@ -370,4 +823,5 @@ var vcsRegistry = map[string]*VCSBackend{
 	"fossil":     FossilBackend,
 	"bzr":        BazaarBackend,
 	"bazaar":     BazaarBackend,
+	"pijul":      PijulBackend,
 }
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/x-motemen/ghq/cmdutil"
@@ -30,6 +33,11 @@ func TestVCSBackend(t *testing.T) {
 	tempDir := newTempDir(t)
 	defer os.RemoveAll(tempDir)
 	localDir := filepath.Join(tempDir, "repo")
+	mirrorDir := filepath.Join(tempDir, "mirror")
+	os.MkdirAll(filepath.Join(mirrorDir, "objects"), 0755)
+	os.MkdirAll(filepath.Join(mirrorDir, "refs"), 0755)
+	ioutil.WriteFile(filepath.Join(mirrorDir, "HEAD"), []byte("ref: refs/heads/master\n"), 0644)
+	ioutil.WriteFile(filepath.Join(mirrorDir, "config"), []byte("[core]\n\tbare = true\n"), 0644)
 	_commands := []*exec.Cmd{}
 	lastCommand := func() *exec.Cmd { return _commands[len(_commands)-1] }
 	defer func(orig func(cmd *exec.Cmd) error) {
@@ -68,6 +76,17 @@ func TestVCSBackend(t *testing.T) {
 			})
 		},
 		expect: []string{"git", "clone", "--depth", "1", remoteDummyURL.String(), localDir},
+	}, {
+		name: "[git] shallow clone with depth",
+		f: func() error {
+			return GitBackend.Clone(&vcsGetOption{
+				url:     remoteDummyURL,
+				dir:     localDir,
+				shallow: true,
+				depth:   10,
+			})
+		},
+		expect: []string{"git", "clone", "--depth", "10", remoteDummyURL.String(), localDir},
 	}, {
 		name: "[git] clone specific branch",
 		f: func() error {
@@ -78,6 +97,80 @@ func TestVCSBackend(t *testing.T) {
 			})
 		},
 		expect: []string{"git", "clone", "--branch", "hello", "--single-branch", remoteDummyURL.String(), localDir},
+	}, {
+		name: "[git] shallow clone specific branch",
+		f: func() error {
+			return GitBackend.Clone(&vcsGetOption{
+				url:     remoteDummyURL,
+				dir:     localDir,
+				shallow: true,
+				branch:  "release-2.0",
+			})
+		},
+		expect: []string{"git", "clone", "--depth", "1", "--branch", "release-2.0", "--single-branch", remoteDummyURL.String(), localDir},
+	}, {
+		name: "[git] clone with scoped config",
+		f: func() error {
+			return GitBackend.Clone(&vcsGetOption{
+				url:       remoteDummyURL,
+				dir:       localDir,
+				gitConfig: []string{"lfs.fetchinclude=images/**"},
+			})
+		},
+		expect: []string{"git", "-c", "lfs.fetchinclude=images/**", "clone", remoteDummyURL.String(), localDir},
+	}, {
+		name: "[git] clone default branch",
+		f: func() error {
+			return GitBackend.Clone(&vcsGetOption{
+				url:           remoteDummyURL,
+				dir:           localDir,
+				defaultBranch: "main",
+			})
+		},
+		expect: []string{"git", "clone", "--branch", "main", remoteDummyURL.String(), localDir},
+	}, {
+		name: "[git] clone recursively with submodule jobs",
+		f: func() error {
+			return GitBackend.Clone(&vcsGetOption{
+				url:           remoteDummyURL,
+				dir:           localDir,
+				recursive:     true,
+				submoduleJobs: 4,
+			})
+		},
+		expect: []string{"git", "clone", "--recursive", "--jobs", "4", remoteDummyURL.String(), localDir},
+	}, {
+		name: "[git] clone ref",
+		f: func() error {
+			return GitBackend.Clone(&vcsGetOption{
+				url:           remoteDummyURL,
+				dir:           localDir,
+				ref:           "v1",
+				preferRef:     preferRefBranch,
+				defaultBranch: "main",
+			})
+		},
+		expect: []string{"git", "clone", "--branch", "v1", remoteDummyURL.String(), localDir},
+	}, {
+		name: "[git] clone without hardlinks",
+		f: func() error {
+			return GitBackend.Clone(&vcsGetOption{
+				url:         mustParseURL("file:///path/to/repo"),
+				dir:         localDir,
+				noHardlinks: true,
+			})
+		},
+		expect: []string{"git", "clone", "--no-hardlinks", "file:///path/to/repo", localDir},
+	}, {
+		name: "[git] clone with remote name",
+		f: func() error {
+			return GitBackend.Clone(&vcsGetOption{
+				url:    remoteDummyURL,
+				dir:    localDir,
+				origin: "github",
+			})
+		},
+		expect: []string{"git", "clone", "--origin", "github", remoteDummyURL.String(), localDir},
 	}, {
 		name: "[git] update",
 		f: func() error {
@@ -87,6 +180,36 @@ func TestVCSBackend(t *testing.T) {
 		},
 		expect: []string{"git", "pull", "--ff-only"},
 		dir:    localDir,
+	}, {
+		name: "[git] update with prune",
+		f: func() error {
+			return GitBackend.Update(&vcsGetOption{
+				dir:   localDir,
+				prune: true,
+			})
+		},
+		expect: []string{"git", "pull", "--ff-only", "--prune"},
+		dir:    localDir,
+	}, {
+		name: "[git] update with rebase",
+		f: func() error {
+			return GitBackend.Update(&vcsGetOption{
+				dir:            localDir,
+				updateStrategy: "rebase",
+			})
+		},
+		expect: []string{"git", "pull", "--rebase"},
+		dir:    localDir,
+	}, {
+		name: "[git] update with merge",
+		f: func() error {
+			return GitBackend.Update(&vcsGetOption{
+				dir:            localDir,
+				updateStrategy: "merge",
+			})
+		},
+		expect: []string{"git", "pull", "--no-rebase"},
+		dir:    localDir,
 	}, {
 		name: "[git] fetch",
 		f: func() error {
@@ -116,6 +239,28 @@ func TestVCSBackend(t *testing.T) {
 			})
 		},
 		expect: []string{"git", "clone", "--recursive", remoteDummyURL.String(), localDir},
+	}, {
+		name: "[git] shallow recursive",
+		f: func() error {
+			return GitBackend.Clone(&vcsGetOption{
+				url:       remoteDummyURL,
+				dir:       localDir,
+				recursive: true,
+				shallow:   true,
+			})
+		},
+		expect: []string{"git", "clone", "--depth", "1", "--recursive", "--shallow-submodules", remoteDummyURL.String(), localDir},
+	}, {
+		name: "[git] recursive partial clone",
+		f: func() error {
+			return GitBackend.Clone(&vcsGetOption{
+				url:                  remoteDummyURL,
+				dir:                  localDir,
+				recursive:            true,
+				alsoFilterSubmodules: true,
+			})
+		},
+		expect: []string{"git", "clone", "--recursive", "--filter=blob:none", "--also-filter-submodules", remoteDummyURL.String(), localDir},
 	}, {
 		name: "[git] update recursive",
 		f: func() error {
@@ -126,6 +271,17 @@ func TestVCSBackend(t *testing.T) {
 		},
 		expect: []string{"git", "submodule", "update", "--init", "--recursive"},
 		dir:    localDir,
+	}, {
+		name: "[git] update recursive with submodule jobs",
+		f: func() error {
+			return GitBackend.Update(&vcsGetOption{
+				dir:           localDir,
+				recursive:     true,
+				submoduleJobs: 4,
+			})
+		},
+		expect: []string{"git", "submodule", "update", "--init", "--recursive", "--jobs", "4"},
+		dir:    localDir,
 	}, {
 		name: "[git] switch git-svn on update",
 		f: func() error {
@@ -149,6 +305,18 @@ func TestVCSBackend(t *testing.T) {
 			})
 		},
 		expect: []string{"svn", "checkout", remoteDummyURL.String(), localDir},
+	}, {
+		name: "[svn] checkout insecure",
+		f: func() error {
+			return SubversionBackend.Clone(&vcsGetOption{
+				url:      remoteDummyURL,
+				dir:      localDir,
+				insecure: true,
+			})
+		},
+		expect: []string{"svn", "checkout", "--non-interactive",
+			"--trust-server-cert-failures=unknown-ca,cn-mismatch,expired,not-yet-valid,other",
+			remoteDummyURL.String(), localDir},
 	}, {
 		name: "[svn] checkout shallow",
 		f: func() error {
@@ -196,8 +364,19 @@ func TestVCSBackend(t *testing.T) {
 				silent: true,
 			})
 		},
-		expect: []string{"svn", "update"},
+		expect: []string{"svn", "update", "--non-interactive"},
 		dir:    localDir,
+	}, {
+		name: "[svn] checkout with username",
+		f: func() error {
+			return SubversionBackend.Clone(&vcsGetOption{
+				url:      remoteDummyURL,
+				dir:      localDir,
+				branch:   "hello",
+				username: "motemen",
+			})
+		},
+		expect: []string{"svn", "checkout", "--username", "motemen", remoteDummyURL.String() + "/branches/hello", localDir},
 	}, {
 		name: "[git-svn] clone",
 		f: func() error {
@@ -236,6 +415,27 @@ func TestVCSBackend(t *testing.T) {
 			})
 		},
 		expect: []string{"git", "svn", "clone", "-s", "-r1872031:HEAD", remoteDummyURL.String(), localDir},
+	}, {
+		name: "[git-svn] clone shallow with depth",
+		f: func() error {
+			defer func(orig func(cmd *exec.Cmd) error) {
+				cmdutil.CommandRunner = orig
+			}(cmdutil.CommandRunner)
+			cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+				_commands = append(_commands, cmd)
+				if reflect.DeepEqual(cmd.Args, []string{"svn", "info", "https://example.com/git/repo/trunk"}) {
+					cmd.Stdout.Write(dummySvnInfo)
+				}
+				return nil
+			}
+			return GitsvnBackend.Clone(&vcsGetOption{
+				url:     remoteDummyURL,
+				dir:     localDir,
+				shallow: true,
+				depth:   10,
+			})
+		},
+		expect: []string{"git", "svn", "clone", "-s", "-r1872022:HEAD", remoteDummyURL.String(), localDir},
 	}, {
 		name: "[git-svn] clone specific branch",
 		f: func() error {
@@ -246,6 +446,17 @@ func TestVCSBackend(t *testing.T) {
 			})
 		},
 		expect: []string{"git", "svn", "clone", remoteDummyURL.String() + "/branches/hello", localDir},
+	}, {
+		name: "[git-svn] clone with username",
+		f: func() error {
+			return GitsvnBackend.Clone(&vcsGetOption{
+				url:      remoteDummyURL,
+				dir:      localDir,
+				branch:   "hello",
+				username: "motemen",
+			})
+		},
+		expect: []string{"git", "svn", "clone", "--username", "motemen", remoteDummyURL.String() + "/branches/hello", localDir},
 	}, {
 		name: "[git-svn] clone specific branch from tagged URL with shallow",
 		f: func() error {
@@ -281,6 +492,26 @@ func TestVCSBackend(t *testing.T) {
 			})
 		},
 		expect: []string{"hg", "clone", remoteDummyURL.String(), localDir},
+	}, {
+		name: "[hg] clone insecure",
+		f: func() error {
+			return MercurialBackend.Clone(&vcsGetOption{
+				url:      remoteDummyURL,
+				dir:      localDir,
+				insecure: true,
+			})
+		},
+		expect: []string{"hg", "clone", "--insecure", remoteDummyURL.String(), localDir},
+	}, {
+		name: "[hg] update insecure",
+		f: func() error {
+			return MercurialBackend.Update(&vcsGetOption{
+				dir:      localDir,
+				insecure: true,
+			})
+		},
+		expect: []string{"hg", "pull", "--update", "--insecure"},
+		dir:    localDir,
 	}, {
 		name: "[hg] update",
 		f: func() error {
@@ -338,6 +569,94 @@ func TestVCSBackend(t *testing.T) {
 		},
 		expect: []string{"darcs", "pull"},
 		dir:    localDir,
+	}, {
+		name: "[git] clone sparse",
+		f: func() error {
+			if err := GitBackend.Clone(&vcsGetOption{
+				url:    remoteDummyURL,
+				dir:    localDir,
+				sparse: []string{"path/to/dir", "docs"},
+			}); err != nil {
+				return err
+			}
+			clone := _commands[len(_commands)-2].Args
+			expect := []string{"git", "clone", "--sparse", "--filter=blob:none", remoteDummyURL.String(), localDir}
+			if !reflect.DeepEqual(clone, expect) {
+				return fmt.Errorf("got: %v, expect: %v", clone, expect)
+			}
+			return nil
+		},
+		expect: []string{"git", "sparse-checkout", "set", "path/to/dir", "docs"},
+		dir:    localDir,
+	}, {
+		name: "[git] clone mirror",
+		f: func() error {
+			return GitBackend.Clone(&vcsGetOption{
+				url:    remoteDummyURL,
+				dir:    localDir,
+				mirror: true,
+			})
+		},
+		expect: []string{"git", "clone", "--mirror", remoteDummyURL.String(), localDir},
+	}, {
+		name: "[git] clone with reference",
+		f: func() error {
+			return GitBackend.Clone(&vcsGetOption{
+				url:        remoteDummyURL,
+				dir:        localDir,
+				reference:  "/path/to/upstream",
+				dissociate: true,
+			})
+		},
+		expect: []string{"git", "clone", "--reference", "/path/to/upstream", "--dissociate", remoteDummyURL.String(), localDir},
+	}, {
+		name: "[git] update mirror",
+		f: func() error {
+			return GitBackend.Update(&vcsGetOption{
+				dir:       mirrorDir,
+				recursive: true,
+			})
+		},
+		expect: []string{"git", "remote", "update", "--prune"},
+		dir:    mirrorDir,
+	}, {
+		name: "[pijul] clone",
+		f: func() error {
+			return PijulBackend.Clone(&vcsGetOption{
+				url: remoteDummyURL,
+				dir: localDir,
+			})
+		},
+		expect: []string{"pijul", "clone", remoteDummyURL.String(), localDir},
+	}, {
+		name: "[pijul] clone shallow",
+		f: func() error {
+			return PijulBackend.Clone(&vcsGetOption{
+				url:     remoteDummyURL,
+				dir:     localDir,
+				shallow: true,
+			})
+		},
+		expect: []string{"pijul", "clone", remoteDummyURL.String(), localDir},
+	}, {
+		name: "[pijul] clone specific channel",
+		f: func() error {
+			return PijulBackend.Clone(&vcsGetOption{
+				url:    remoteDummyURL,
+				dir:    localDir,
+				branch: "hello",
+			})
+		},
+		expect: []string{"pijul", "clone", "--channel", "hello", remoteDummyURL.String(), localDir},
+	}, {
+		name: "[pijul] update",
+		f: func() error {
+			return PijulBackend.Update(&vcsGetOption{
+				dir: localDir,
+			})
+		},
+		expect: []string{"pijul", "pull", "--all"},
+		dir:    localDir,
 	}, {
 		name: "[bzr] clone",
 		f: func() error {
@@ -459,3 +778,409 @@ func TestBranchOptionIgnoredErrors(t *testing.T) {
 		t.Error("error should be occurred, but nil")
 	}
 }
+
+func TestUsesGitLFS(t *testing.T) {
+	tempDir := newTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	testCases := []struct {
+		name       string
+		attributes string
+		expect     bool
+	}{{
+		name:       "lfs",
+		attributes: "*.psd filter=lfs diff=lfs merge=lfs -text\n",
+		expect:     true,
+	}, {
+		name:       "no lfs",
+		attributes: "*.go text eol=lf\n",
+		expect:     false,
+	}, {
+		name:   "no .gitattributes",
+		expect: false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := filepath.Join(tempDir, tc.name)
+			os.MkdirAll(dir, 0755)
+			if tc.attributes != "" {
+				ioutil.WriteFile(filepath.Join(dir, ".gitattributes"), []byte(tc.attributes), 0644)
+			}
+			if got := usesGitLFS(dir); got != tc.expect {
+				t.Errorf("usesGitLFS: got: %t, expect: %t", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestGitBackend_autoDeepen(t *testing.T) {
+	tempDir := newTempDir(t)
+	defer os.RemoveAll(tempDir)
+	os.MkdirAll(filepath.Join(tempDir, ".git"), 0755)
+	ioutil.WriteFile(filepath.Join(tempDir, ".git", "shallow"), []byte{}, 0644)
+
+	var _commands [][]string
+	defer func(orig func(cmd *exec.Cmd) error) {
+		cmdutil.CommandRunner = orig
+	}(cmdutil.CommandRunner)
+	pulls := 0
+	cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+		_commands = append(_commands, cmd.Args)
+		if reflect.DeepEqual(cmd.Args, []string{"git", "pull", "--ff-only"}) {
+			pulls++
+			if pulls < 3 {
+				return fmt.Errorf("[test] not possible to fast-forward")
+			}
+		}
+		return nil
+	}
+
+	err := GitBackend.Update(&vcsGetOption{
+		dir:        tempDir,
+		autoDeepen: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := [][]string{
+		{"git", "rev-parse", "@{upstream}"},
+		{"git", "pull", "--ff-only"},
+		{"git", "fetch", "--deepen=100"},
+		{"git", "pull", "--ff-only"},
+		{"git", "fetch", "--deepen=200"},
+		{"git", "pull", "--ff-only"},
+	}
+	if !reflect.DeepEqual(_commands, expect) {
+		t.Errorf("\ngot:    %+v\nexpect: %+v", _commands, expect)
+	}
+
+	_commands, pulls = nil, 0
+	err = GitBackend.Update(&vcsGetOption{dir: tempDir})
+	if err == nil {
+		t.Errorf("error should be occurred without autoDeepen")
+	}
+	if len(_commands) != 2 {
+		t.Errorf("should not deepen without autoDeepen: %+v", _commands)
+	}
+}
+
+func TestIsGitVersionAtLeast(t *testing.T) {
+	testCases := []struct {
+		out    string
+		expect bool
+	}{
+		{"git version 2.36.0", true},
+		{"git version 2.39.2 (Apple Git-143)", true},
+		{"git version 3.0.0", true},
+		{"git version 2.35.1.windows.2", false},
+		{"git version 1.8.5", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.out, func(t *testing.T) {
+			got, err := isGitVersionAtLeast(tc.out, 2, 36)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.expect {
+				t.Errorf("got: %t, expect: %t", got, tc.expect)
+			}
+		})
+	}
+
+	if _, err := isGitVersionAtLeast("unknown", 2, 36); err == nil {
+		t.Errorf("error should be occurred for unknown output")
+	}
+}
+
+func TestGitBackend_preferTag(t *testing.T) {
+	tempDir := newTempDir(t)
+	defer os.RemoveAll(tempDir)
+	localDir := filepath.Join(tempDir, "repo")
+
+	var _commands [][]string
+	defer func(orig func(cmd *exec.Cmd) error) {
+		cmdutil.CommandRunner = orig
+	}(cmdutil.CommandRunner)
+	tagExists := true
+	cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+		_commands = append(_commands, cmd.Args)
+		if cmd.Args[1] == "fetch" && !tagExists {
+			return fmt.Errorf("[test] couldn't find remote ref")
+		}
+		return nil
+	}
+
+	testCases := []struct {
+		name      string
+		vg        *vcsGetOption
+		tagExists bool
+		expect    [][]string
+	}{{
+		name:      "tag",
+		vg:        &vcsGetOption{ref: "v1"},
+		tagExists: true,
+		expect: [][]string{
+			{"git", "clone", remoteDummyURL.String(), localDir},
+			{"git", "fetch", "origin", "+refs/tags/v1:refs/tags/v1"},
+			{"git", "checkout", "--detach", "refs/tags/v1"},
+		},
+	}, {
+		name:      "shallow recursive with renamed remote",
+		vg:        &vcsGetOption{ref: "v1", shallow: true, recursive: true, origin: "upstream"},
+		tagExists: true,
+		expect: [][]string{
+			{"git", "clone", "--depth", "1", "--recursive", "--shallow-submodules", "--origin", "upstream", remoteDummyURL.String(), localDir},
+			{"git", "fetch", "--depth", "1", "upstream", "+refs/tags/v1:refs/tags/v1"},
+			{"git", "checkout", "--detach", "refs/tags/v1"},
+			{"git", "submodule", "update", "--init", "--recursive"},
+		},
+	}, {
+		name:      "fall back to branch",
+		vg:        &vcsGetOption{ref: "v1"},
+		tagExists: false,
+		expect: [][]string{
+			{"git", "clone", remoteDummyURL.String(), localDir},
+			{"git", "fetch", "origin", "+refs/tags/v1:refs/tags/v1"},
+			{"git", "checkout", "v1"},
+		},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_commands, tagExists = nil, tc.tagExists
+			tc.vg.url, tc.vg.dir, tc.vg.preferRef = remoteDummyURL, localDir, preferRefTag
+			if err := GitBackend.Clone(tc.vg); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(_commands, tc.expect) {
+				t.Errorf("\ngot:    %+v\nexpect: %+v", _commands, tc.expect)
+			}
+		})
+	}
+}
+
+func TestGitBackend_unshallow(t *testing.T) {
+	tempDir := newTempDir(t)
+	defer os.RemoveAll(tempDir)
+	os.MkdirAll(filepath.Join(tempDir, ".git"), 0755)
+
+	var _commands [][]string
+	defer func(orig func(cmd *exec.Cmd) error) {
+		cmdutil.CommandRunner = orig
+	}(cmdutil.CommandRunner)
+	cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+		_commands = append(_commands, cmd.Args)
+		return nil
+	}
+
+	vg := &vcsGetOption{dir: tempDir, unshallow: true}
+	if err := GitBackend.Update(vg); err != nil {
+		t.Fatal(err)
+	}
+	expect := [][]string{
+		{"git", "rev-parse", "@{upstream}"},
+		{"git", "pull", "--ff-only"},
+	}
+	if !reflect.DeepEqual(_commands, expect) {
+		t.Errorf("should not unshallow a complete repository\ngot:    %+v\nexpect: %+v", _commands, expect)
+	}
+
+	ioutil.WriteFile(filepath.Join(tempDir, ".git", "shallow"), []byte{}, 0644)
+	_commands = nil
+	if err := GitBackend.Update(vg); err != nil {
+		t.Fatal(err)
+	}
+	expect = [][]string{
+		{"git", "fetch", "--unshallow"},
+		{"git", "rev-parse", "@{upstream}"},
+		{"git", "pull", "--ff-only"},
+	}
+	if !reflect.DeepEqual(_commands, expect) {
+		t.Errorf("\ngot:    %+v\nexpect: %+v", _commands, expect)
+	}
+
+	_commands = nil
+	vg.unshallow = false
+	if err := GitBackend.Update(vg); err != nil {
+		t.Fatal(err)
+	}
+	if len(_commands) != 2 {
+		t.Errorf("should not unshallow without the option: %+v", _commands)
+	}
+}
+
+func TestVCSGetOption_env(t *testing.T) {
+	cmd := (&vcsGetOption{}).command("", "git", "version")
+	if cmd.Env != nil {
+		t.Errorf("environment should be inherited as it is, but: %v", cmd.Env)
+	}
+
+	env := []string{"GIT_SSH_COMMAND=ssh -i ~/.ssh/id_work", "GIT_TRACE=1"}
+	cmd = (&vcsGetOption{env: env}).command("", "git", "version")
+	if got := cmd.Env[len(cmd.Env)-2:]; !reflect.DeepEqual(got, env) {
+		t.Errorf("got: %v, expect: %v", got, env)
+	}
+	if len(cmd.Env) != len(os.Environ())+2 {
+		t.Errorf("the environment of ghq should be inherited as well")
+	}
+}
+
+func TestVCSGetOption_silentError(t *testing.T) {
+	defer func(orig func(cmd *exec.Cmd) error) {
+		cmdutil.CommandRunner = orig
+	}(cmdutil.CommandRunner)
+	cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+		fmt.Fprintln(cmd.Stdout, "Cloning into 'repo'...")
+		fmt.Fprintln(cmd.Stderr, "fatal: repository not found")
+		return &exec.ExitError{}
+	}
+
+	err := (&vcsGetOption{silent: true}).run("git", "clone", remoteDummyURL.String())
+	if err == nil || !strings.HasSuffix(err.Error(), "\nfatal: repository not found") {
+		t.Errorf("the error output should be kept, but: %v", err)
+	}
+	if strings.Contains(err.Error(), "Cloning") {
+		t.Errorf("the standard output should be discarded, but: %v", err)
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Errorf("the error should wrap *exec.ExitError, but: %#v", err)
+	}
+}
+
+func TestVCSBackend_Init(t *testing.T) {
+	defer func(orig func(cmd *exec.Cmd) error) {
+		cmdutil.CommandRunner = orig
+	}(cmdutil.CommandRunner)
+	var lastCmd *exec.Cmd
+	cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+		lastCmd = cmd
+		return nil
+	}
+
+	if err := GitBackend.Init("repo", true); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lastCmd.Args, []string{"git", "init"}) || lastCmd.Dir != "repo" {
+		t.Errorf("unexpected command: %v in %s", lastCmd.Args, lastCmd.Dir)
+	}
+	if lastCmd.Stdout != ioutil.Discard {
+		t.Errorf("git init should be run silently")
+	}
+
+	for name, backend := range map[string]*VCSBackend{
+		"svn":     SubversionBackend,
+		"git-svn": GitsvnBackend,
+		"cvs":     cvsDummyBackend,
+	} {
+		lastCmd = nil
+		if err := backend.Init("repo", false); err == nil {
+			t.Errorf("%s: error should be occurred", name)
+		}
+		if lastCmd != nil {
+			t.Errorf("%s: nothing should be run, but: %v", name, lastCmd.Args)
+		}
+	}
+}
+
+func TestVCSName(t *testing.T) {
+	testCases := []struct {
+		backend *VCSBackend
+		expect  string
+	}{
+		{GitBackend, "git"},
+		{GitsvnBackend, "git-svn"},
+		{SubversionBackend, "svn"},
+		{MercurialBackend, "hg"},
+		{BazaarBackend, "bzr"},
+		{cvsDummyBackend, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expect, func(t *testing.T) {
+			if got := vcsName(tc.backend); got != tc.expect {
+				t.Errorf("got: %q, expect: %q", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestVCSBackend_SupportsShallow(t *testing.T) {
+	testCases := []struct {
+		vcs    *VCSBackend
+		expect bool
+	}{
+		{GitBackend, true},
+		{SubversionBackend, true},
+		{GitsvnBackend, true},
+		{DarcsBackend, true},
+		{MercurialBackend, false},
+		{FossilBackend, false},
+		{BazaarBackend, false},
+		{PijulBackend, false},
+	}
+	for _, tc := range testCases {
+		t.Run(vcsName(tc.vcs), func(t *testing.T) {
+			if tc.vcs.SupportsShallow != tc.expect {
+				t.Errorf("got: %t, expect: %t", tc.vcs.SupportsShallow, tc.expect)
+			}
+		})
+	}
+}
+
+func TestVCSGetOption_commands(t *testing.T) {
+	defer func(orig func(cmd *exec.Cmd) error) {
+		cmdutil.CommandRunner = orig
+	}(cmdutil.CommandRunner)
+	cmdutil.CommandRunner = func(cmd *exec.Cmd) error {
+		t.Errorf("nothing should be run, but: %v", cmd.Args)
+		return nil
+	}
+	tempDir := newTempDir(t)
+	defer os.RemoveAll(tempDir)
+	localDir := filepath.Join(tempDir, "github.com", "motemen", "repo")
+
+	var commands []string
+	err := GitBackend.Clone(&vcsGetOption{
+		url:      remoteDummyURL,
+		dir:      localDir,
+		shallow:  true,
+		branch:   "release-2.0",
+		sparse:   []string{"docs"},
+		commands: &commands,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		"git clone --depth 1 --branch release-2.0 --single-branch --sparse --filter=blob:none " + remoteDummyURL.String() + " " + localDir,
+		"cd " + localDir + " && git sparse-checkout set docs",
+	}
+	if !reflect.DeepEqual(commands, expect) {
+		t.Errorf("got: %v, expect: %v", commands, expect)
+	}
+	if _, err := os.Stat(filepath.Dir(localDir)); !os.IsNotExist(err) {
+		t.Errorf("the parent directory should not be created")
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	testCases := []struct {
+		args   []string
+		expect string
+	}{
+		{[]string{"git", "clone", "https://example.com/foo/bar", "/path/to/bar"}, "git clone https://example.com/foo/bar /path/to/bar"},
+		{[]string{"git", "-c", "http.extraHeader=Authorization: Basic ***"}, "git -c 'http.extraHeader=Authorization: Basic ***'"},
+		{[]string{"sh", "-c", "echo 'hi'"}, `sh -c 'echo '\''hi'\'''`},
+		{[]string{"git", ""}, "git ''"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expect, func(t *testing.T) {
+			if got := shellQuote(tc.args...); got != tc.expect {
+				t.Errorf("got: %s, expect: %s", got, tc.expect)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const repositoryIndexVersion = 1
+
+// repositoryIndexPath returns the path of the cache file of the repository
+// index. It is a variable to be replaced in the tests.
+var repositoryIndexPath = func() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ghq", "index"), nil
+}
+
+// repositoryIndex is the on-disk cache of the repositories found by walking
+// the roots. Cloning or removing a repository modifies the directory
+// containing it, so the index stays valid as long as none of the directories
+// walked through are modified. Modification times are used instead of a TTL
+// to reflect such changes immediately.
+type repositoryIndex struct {
+	Version  int                 `json:"version"`
+	Roots    []string            `json:"roots"`
+	MaxDepth int                 `json:"max_depth,omitempty"` // ghq.root.maxDepth
+	Dirs     map[string]int64    `json:"dirs"`                // path to mtime in UnixNano
+	Repos    []indexedRepository `json:"repos"`
+
+	mu      sync.Mutex
+	started time.Time
+}
+
+type indexedRepository struct {
+	Path string `json:"path"`
+	VCS  string `json:"vcs"`
+}
+
+// indexedVCSBackends returns the backends by their names in the index. It is
+// built on each call since the backends are replaced in the tests.
+func indexedVCSBackends() map[string]*VCSBackend {
+	backends := map[string]*VCSBackend{
+		"git":     GitBackend,
+		"svn":     SubversionBackend,
+		"git-svn": GitsvnBackend,
+		"hg":      MercurialBackend,
+		"darcs":   DarcsBackend,
+		"fossil":  FossilBackend,
+		"bzr":     BazaarBackend,
+		"pijul":   PijulBackend,
+		"cvs":     cvsDummyBackend,
+	}
+	// the custom backends defined by ghq.vcs.<name>
+	builtin := map[*VCSBackend]bool{}
+	for _, b := range backends {
+		builtin[b] = true
+	}
+	for name, b := range vcsRegistry {
+		if _, ok := backends[name]; !ok && !builtin[b] {
+			backends[name] = b
+		}
+	}
+	return backends
+}
+
+func newRepositoryIndex(roots []string) *repositoryIndex {
+	idx := &repositoryIndex{
+		Version: repositoryIndexVersion,
+		Roots:   roots,
+		Dirs:    map[string]int64{},
+		started: time.Now(),
+	}
+	// the roots not existing yet are recorded as well to notice their creation,
+	// and .ghqignore to notice its modification
+	for _, root := range roots {
+		idx.Dirs[root] = dirModTime(root)
+		ignore := filepath.Join(root, ghqIgnoreFile)
+		idx.Dirs[ignore] = dirModTime(ignore)
+	}
+	return idx
+}
+
+// dirModTime returns the modification time of the directory (or the file), or
+// -1 if it does not exist.
+func dirModTime(dir string) int64 {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return -1
+	}
+	return fi.ModTime().UnixNano()
+}
+
+// addDir records the directory walked through. It does nothing for the nil
+// index, as well as addRepo.
+func (idx *repositoryIndex) addDir(dir string, fi os.FileInfo) {
+	if idx == nil {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.Dirs[dir] = fi.ModTime().UnixNano()
+}
+
+func (idx *repositoryIndex) addRepo(repoPath string, backend *VCSBackend) {
+	if idx == nil {
+		return
+	}
+	for name, b := range indexedVCSBackends() {
+		if b == backend {
+			idx.mu.Lock()
+			defer idx.mu.Unlock()
+			idx.Repos = append(idx.Repos, indexedRepository{Path: repoPath, VCS: name})
+			return
+		}
+	}
+}
+
+// loadRepositoryIndex returns the cached index for the roots. nil is
+// returned when it is unavailable or out of date.
+func loadRepositoryIndex(roots []string) *repositoryIndex {
+	p, err := repositoryIndexPath()
+	if err != nil {
+		return nil
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil
+	}
+	idx := &repositoryIndex{}
+	if err := json.Unmarshal(b, idx); err != nil {
+		return nil
+	}
+	if idx.Version != repositoryIndexVersion || !reflect.DeepEqual(idx.Roots, roots) {
+		return nil
+	}
+	for dir, mtime := range idx.Dirs {
+		if dirModTime(dir) != mtime {
+			return nil
+		}
+	}
+	// removing the VCS directory does not modify the directories walked through
+	backends := indexedVCSBackends()
+	for _, r := range idx.Repos {
+		if findVCSBackend(r.Path, "") != backends[r.VCS] {
+			return nil
+		}
+	}
+	return idx
+}
+
+// save writes the index to the cache file. It does nothing for the nil index
+// and the index which may miss the changes during the walk.
+func (idx *repositoryIndex) save() error {
+	if idx == nil {
+		return nil
+	}
+	// A directory modified around the walk may be modified again within the
+	// resolution of its modification time, which could not be detected.
+	threshold := idx.started.Add(-2 * time.Second).UnixNano()
+	for _, mtime := range idx.Dirs {
+		if mtime >= threshold {
+			return nil
+		}
+	}
+	p, err := repositoryIndexPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	// write to a temporary file and rename it not to leave a broken one
+	f, err := ioutil.TempFile(filepath.Dir(p), filepath.Base(p))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
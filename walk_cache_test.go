@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// backdateDirs sets the modification times of the directories under root to
+// the past so that the index is saved.
+func backdateDirs(t *testing.T, root string) {
+	past := time.Now().Add(-time.Hour)
+	err := filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.IsDir() {
+			return err
+		}
+		return os.Chtimes(p, past, past)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWalkLocalRepositories_cache(t *testing.T) {
+	defer func(orig []string) { _localRepositoryRoots = orig }(_localRepositoryRoots)
+	tmproot := newTempDir(t)
+	defer os.RemoveAll(tmproot)
+	_localRepositoryRoots = []string{tmproot}
+
+	for _, p := range []string{"github.com/motemen/ghq", "github.com/x-motemen/gore"} {
+		os.MkdirAll(filepath.Join(tmproot, p, ".git"), 0755)
+	}
+	backdateDirs(t, tmproot)
+
+	walk := func() []string {
+		var (
+			paths []string
+			mu    sync.Mutex
+		)
+		if err := walkAllLocalRepositories(func(repo *LocalRepository) {
+			mu.Lock()
+			defer mu.Unlock()
+			paths = append(paths, repo.RelPath)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(paths)
+		return paths
+	}
+
+	expect := []string{"github.com/motemen/ghq", "github.com/x-motemen/gore"}
+	if got := walk(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("got: %v, expect: %v", got, expect)
+	}
+	if loadRepositoryIndex(_localRepositoryRoots) == nil {
+		t.Fatal("index should be saved")
+	}
+	if got := walk(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("got: %v, expect: %v", got, expect)
+	}
+
+	t.Run("cloned", func(t *testing.T) {
+		os.MkdirAll(filepath.Join(tmproot, "github.com", "motemen", "gobump", ".git"), 0755)
+		if loadRepositoryIndex(_localRepositoryRoots) != nil {
+			t.Error("index should be out of date")
+		}
+		expect := []string{"github.com/motemen/ghq", "github.com/motemen/gobump", "github.com/x-motemen/gore"}
+		if got := walk(); !reflect.DeepEqual(got, expect) {
+			t.Errorf("got: %v, expect: %v", got, expect)
+		}
+		backdateDirs(t, tmproot)
+		walk()
+	})
+
+	t.Run("VCS directory removed", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(tmproot, "github.com", "motemen", "gobump", ".git"))
+		if loadRepositoryIndex(_localRepositoryRoots) != nil {
+			t.Error("index should be out of date")
+		}
+		if got := walk(); !reflect.DeepEqual(got, expect) {
+			t.Errorf("got: %v, expect: %v", got, expect)
+		}
+	})
+
+	t.Run("roots changed", func(t *testing.T) {
+		backdateDirs(t, tmproot)
+		walk()
+		if loadRepositoryIndex([]string{tmproot, filepath.Join(tmproot, "other")}) != nil {
+			t.Error("index should not be used for other roots")
+		}
+	})
+}
+
+func TestRepositoryIndex_save_recentlyModified(t *testing.T) {
+	tmproot := newTempDir(t)
+	defer os.RemoveAll(tmproot)
+
+	p, _ := repositoryIndexPath()
+	os.Remove(p)
+	idx := newRepositoryIndex([]string{tmproot})
+	if err := idx.save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Errorf("index should not be saved for the directory modified just now: %v", err)
+	}
+}